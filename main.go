@@ -1,89 +1,299 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"log/slog"
 	"os"
+	"strings"
+	"sync"
 
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
 	"github.com/raikerian/go-remai-bot-discord/pkg/commands"
 	"github.com/raikerian/go-remai-bot-discord/pkg/commands/gpt"
+	"github.com/raikerian/go-remai-bot-discord/pkg/config"
 	"github.com/raikerian/go-remai-bot-discord/pkg/constants"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/logging"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/ratelimit"
+	"github.com/raikerian/go-remai-bot-discord/pkg/rules"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
 	"github.com/sashabaranov/go-openai"
-	"gopkg.in/yaml.v2"
 )
 
-type Config struct {
-	Discord struct {
-		Token          string `yaml:"token"`
-		Guild          string `yaml:"guild"`
-		RemoveCommands bool   `yaml:"removeCommands"`
-	} `yaml:"discord"`
-	OpenAI struct {
-		APIKey           string   `yaml:"apiKey"`
-		CompletionModels []string `yaml:"completionModels"`
-	} `yaml:"openAI"`
-}
-
-func (c *Config) ReadFromFile(file string) error {
-	data, err := os.ReadFile(file)
-	if err != nil {
-		return err
-	}
-	err = yaml.Unmarshal(data, c)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func init() {
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	// Config is not loaded yet, log with defaults until it is
+	logging.Setup(logging.Config{})
 }
 
 var (
 	discordBot   *bot.Bot
 	openaiClient *openai.Client
 
+	settingsStore *settings.Store
+	rateLimiter   *ratelimit.Limiter
+	requestQueue  *queue.Queue
+
 	gptMessagesCache     *gpt.MessagesCache
-	ignoredChannelsCache = make(gpt.IgnoredChannelsCache)
+	ignoredChannelsCache = gpt.NewIgnoredChannelsCache()
+	knowledgeBases       *kb.Store
+	memories             *memory.Store
 )
 
 func main() {
-	// Read config from file
-	config := &Config{}
-	err := config.ReadFromFile("credentials.yaml")
+	configFile := flag.String("config", os.Getenv("REMAI_CONFIG"), "Path to the config file (default \""+config.DefaultFile+"\", env REMAI_CONFIG)")
+	checkConfig := flag.Bool("check-config", false, "Validate configuration and exit")
+	syncDryRun := flag.Bool("sync-dry-run", false, "Print changes that would be made to registered commands and exit")
+	flag.Parse()
+
+	// Read config from file and environment. Default config file is optional,
+	// so the whole configuration can come from environment variables
+	configRequired := *configFile != ""
+	if !configRequired {
+		*configFile = config.DefaultFile
+	}
+	cfg, err := config.Load(*configFile, configRequired)
 	if err != nil {
-		log.Fatalf("Error reading credentials.yaml: %v", err)
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if *checkConfig {
+		slog.Info("Configuration is valid")
+		return
+	}
+	if err = logging.Setup(cfg.Logging); err != nil {
+		slog.Error("Invalid logging config", "error", err)
+		os.Exit(1)
 	}
+	stopTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		slog.Error("Cannot set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer stopTracing(context.Background())
 
 	// Initialize cache
 	gptMessagesCache, err = gpt.NewMessagesCache(constants.DiscordThreadsCacheSize)
 	if err != nil {
-		log.Fatalf("Error initializing GPTMessagesCache: %v", err)
+		slog.Error("Error initializing GPTMessagesCache", "error", err)
+		os.Exit(1)
+	}
+
+	// Load per-guild settings
+	settingsStore, err = settings.NewStore(cfg.Settings.File)
+	if err != nil {
+		slog.Error("Error loading guild settings", "error", err)
+		os.Exit(1)
 	}
 
+	rateLimiter = ratelimit.New(cfg.RateLimits)
+	requestQueue = queue.New(cfg.Queue)
+
 	// Initialize discord bot
-	discordBot, err = bot.NewBot(config.Discord.Token)
+	discordBot, err = bot.NewBot(cfg.Discord.Token, cfg.Discord.Shards)
 	if err != nil {
-		log.Fatalf("Invalid bot parameters: %v", err)
+		slog.Error("Invalid bot parameters", "error", err)
+		os.Exit(1)
 	}
 
+	// Count interactions and messages before anything else can stop them
+	discordBot.Router.Use(monitoring.Middleware())
+	discordBot.Router.UseMessage(monitoring.MessageMiddleware())
+
 	// Register commands
-	if config.OpenAI.APIKey != "" {
-		openaiClient = openai.NewClient(config.OpenAI.APIKey) // initialize OpenAI client first
-
-		discordBot.Router.Register(commands.ChatCommand(&commands.ChatCommandParams{
-			OpenAIClient:           openaiClient,
-			OpenAICompletionModels: config.OpenAI.CompletionModels,
-			GPTMessagesCache:       gptMessagesCache,
-			IgnoredChannelsCache:   &ignoredChannelsCache,
-		}))
+	var moderator *moderation.Moderator
+	if cfg.OpenAI.APIKey != "" {
+		// initialize OpenAI client first
+		openaiConfig := openai.DefaultConfig(cfg.OpenAI.APIKey)
+		openaiConfig.HTTPClient = tracing.Client(openaiConfig.HTTPClient)
+		openaiClient = openai.NewClientWithConfig(openaiConfig)
+
+		moderator, err = moderation.New(cfg.Moderation, openaiClient)
+		if err != nil {
+			slog.Error("Invalid moderation config", "error", err)
+			os.Exit(1)
+		}
+		moderator.SetPolicyOverride(func(guildID string, policy moderation.Policy) moderation.Policy {
+			guild := settingsStore.Get(guildID)
+			return guild.ApplyModeration(policy)
+		})
+
+		knowledgeBases, err = kb.New(cfg.KnowledgeBases, openaiClient)
+		if err != nil {
+			slog.Error("Invalid knowledge bases config", "error", err)
+			os.Exit(1)
+		}
+
+		memories, err = memory.New(cfg.Memory)
+		if err != nil {
+			slog.Error("Cannot load memory", "error", err)
+			os.Exit(1)
+		}
+
+		discordBot.Router.Register(chatCommand(cfg, moderator))
+		if knowledgeBases != nil {
+			discordBot.Router.Register(commands.KBCommand(&commands.KBCommandParams{
+				KnowledgeBases: knowledgeBases,
+				RequestQueue:   requestQueue,
+				RateLimiter:    rateLimiter,
+			}))
+		}
+		if memories != nil {
+			discordBot.Router.Register(commands.MemoryCommand(&commands.MemoryCommandParams{
+				Memory:      memories,
+				RateLimiter: rateLimiter,
+			}))
+		}
 
-		discordBot.Router.Register(commands.ImageCommand(openaiClient))
+		// Unlock threads a previous run did not finish answering in, when each shard connects for the first time
+		var recoveredShards sync.Map
+		discordBot.AddHandler(func(s *discord.Session, r *discord.Ready) {
+			if _, recovered := recoveredShards.LoadOrStore(s.ShardID, struct{}{}); !recovered {
+				commands.RecoverChatThreads(s, r.Guilds)
+			}
+		})
+
+		archiver, err := archive.New(cfg.Images.Archive)
+		if err != nil {
+			slog.Error("Invalid images archive config", "error", err)
+			os.Exit(1)
+		}
+
+		discordBot.Router.Register(commands.ImageCommand(&commands.ImageCommandParams{
+			OpenAIClient: openaiClient,
+			RequestQueue: requestQueue,
+			Moderator:    moderator,
+			Archiver:     archiver,
+			Settings:     settingsStore,
+			RateLimiter:  rateLimiter,
+		}))
 	}
 	discordBot.Router.Register(commands.InfoCommand())
+	discordBot.Router.Register(adminCommand(cfg)) // last one, so it can restrict all other commands
+
+	// Restrict commands according to guild rules
+	guildRules := func(guildID string) []rules.Rule {
+		return settingsStore.Get(guildID).Rules
+	}
+	discordBot.Router.Use(rules.Middleware(guildRules))
+	discordBot.Router.UseMessage(rules.MessageMiddleware(guildRules))
+
+	if *syncDryRun {
+		if err = discordBot.DryRunSync(cfg.Discord.CommandGuilds()); err != nil {
+			slog.Error("Cannot compare commands", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Reload config on file changes and SIGHUP
+	reload := configReloader(*configFile, configRequired, cfg, moderator)
+	discordBot.AddReloadHandler(reload)
+	stopWatch, err := config.Watch(*configFile, reload)
+	if err != nil {
+		slog.Warn("Config file is not watched for changes", "error", err)
+	} else {
+		defer stopWatch()
+	}
+
+	// Serve metrics and health checks
+	monitoringServer := monitoring.NewServer(cfg.Monitoring, discordBot.Shards, discordBot.Router)
+	if err = monitoringServer.Start(); err != nil {
+		slog.Error("Cannot start monitoring server", "error", err)
+		os.Exit(1)
+	}
+	defer monitoringServer.Shutdown(context.Background())
 
 	// Run the bot
-	discordBot.Run(config.Discord.Guild, config.Discord.RemoveCommands)
+	discordBot.Run(cfg.Discord.CommandGuilds(), cfg.Discord.RemoveCommands, cfg.Discord.ShutdownTimeout)
+}
+
+func chatCommand(cfg *config.Config, moderator *moderation.Moderator) *bot.Command {
+	return commands.ChatCommand(&commands.ChatCommandParams{
+		OpenAIClient:           openaiClient,
+		RequestQueue:           requestQueue,
+		Moderator:              moderator,
+		Settings:               settingsStore,
+		RateLimiter:            rateLimiter,
+		OpenAICompletionModels: cfg.OpenAI.CompletionModels,
+		GPTMessagesCache:       gptMessagesCache,
+		IgnoredChannelsCache:   ignoredChannelsCache,
+		KnowledgeBases:         knowledgeBases,
+		Memory:                 memories,
+	})
+}
+
+func adminCommand(cfg *config.Config) *bot.Command {
+	return commands.AdminCommand(&commands.AdminCommandParams{
+		Settings:               settingsStore,
+		OpenAICompletionModels: cfg.OpenAI.CompletionModels,
+		CommandPaths:           restrictableCommandPaths(),
+	})
+}
+
+// restrictableCommandPaths lists registered commands except admin one, admins must not lock themselves out
+func restrictableCommandPaths() (paths []string) {
+	for _, path := range discordBot.Router.Paths() {
+		if path != commands.AdminCommandName && !strings.HasPrefix(path, commands.AdminCommandName+" ") {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// configReloader returns a function that re-reads config and applies reloadable settings: commands are
+// rebuilt and changed ones re-synced with Discord, moderation policies are swapped. Settings that require
+// a restart keep their running values until then. Invalid config is reported and ignored
+func configReloader(file string, required bool, cfg *config.Config, moderator *moderation.Moderator) func() {
+	var mu sync.Mutex
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		newCfg, err := config.Load(file, required)
+		if err != nil {
+			slog.Error("Config reload failed, keeping the running configuration", "error", err)
+			return
+		}
+		if fields := config.RestartRequired(cfg, newCfg); len(fields) > 0 {
+			slog.Warn("Config changes require a restart to take effect", "fields", strings.Join(fields, ", "))
+		}
+		newCfg.Discord = cfg.Discord
+		newCfg.OpenAI.APIKey = cfg.OpenAI.APIKey
+		newCfg.Images.Archive = cfg.Images.Archive
+		newCfg.Settings = cfg.Settings
+		newCfg.Logging.Format = cfg.Logging.Format
+		newCfg.Monitoring = cfg.Monitoring
+		newCfg.Tracing = cfg.Tracing
+		newCfg.KnowledgeBases = cfg.KnowledgeBases
+		newCfg.Memory = cfg.Memory
+
+		if openaiClient != nil {
+			if err := moderator.Update(newCfg.Moderation, openaiClient); err != nil {
+				slog.Error("Config reload failed, keeping the running configuration", "error", err)
+				return
+			}
+			discordBot.Router.Replace(chatCommand(newCfg, moderator))
+		}
+		discordBot.Router.Replace(adminCommand(newCfg))
+		rateLimiter.Update(newCfg.RateLimits)
+		requestQueue.Update(newCfg.Queue)
+		logging.SetLevel(newCfg.Logging)
+		*cfg = *newCfg
+
+		if discordBot.OwnsCommands() {
+			if err := discordBot.Router.Sync(discordBot.Session, cfg.Discord.CommandGuilds()); err != nil {
+				slog.Error("Failed to sync commands after config reload", "error", err)
+				return
+			}
+		}
+		slog.Info("Config reloaded")
+	}
 }
@@ -0,0 +1,68 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+const (
+	TypeLocal = "local"
+	TypeS3    = "s3"
+)
+
+// Archiver stores generated content outside of Discord, so it survives message deletion
+type Archiver interface {
+	Store(ctx context.Context, key string, contentType string, data []byte) error
+}
+
+type Config struct {
+	// Archive type: local or s3. Archival is disabled if empty
+	Type string `yaml:"type"`
+	// Directory for local archive
+	Dir string `yaml:"dir"`
+	// S3-compatible store settings
+	S3 S3Config `yaml:"s3"`
+}
+
+// New creates an archiver for the given config, returns nil archiver if archival is disabled
+func New(config Config) (Archiver, error) {
+	switch config.Type {
+	case "":
+		return nil, nil
+	case TypeLocal:
+		return NewLocal(config.Dir)
+	case TypeS3:
+		return NewS3(config.S3)
+	}
+	return nil, fmt.Errorf("unknown archive type: %s", config.Type)
+}
+
+// Validate checks config without touching the archive
+func (c *Config) Validate() error {
+	switch c.Type {
+	case "":
+		return nil
+	case TypeLocal:
+		if c.Dir == "" {
+			return errors.New("dir: is required for local archive")
+		}
+		return nil
+	case TypeS3:
+		var errs []error
+		if c.S3.Endpoint == "" {
+			errs = append(errs, errors.New("s3.endpoint: is required for s3 archive"))
+		} else if u, err := url.Parse(c.S3.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("s3.endpoint: %q is not a valid URL", c.S3.Endpoint))
+		}
+		if c.S3.Bucket == "" {
+			errs = append(errs, errors.New("s3.bucket: is required for s3 archive"))
+		}
+		if c.S3.AccessKey == "" || c.S3.SecretKey == "" {
+			errs = append(errs, errors.New("s3: accessKey and secretKey are required for s3 archive"))
+		}
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("type: unknown archive type %q, expected %s or %s", c.Type, TypeLocal, TypeS3)
+}
@@ -0,0 +1,30 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	if dir == "" {
+		return nil, errors.New("local archive requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+func (a *Local) Store(_ context.Context, key string, _ string, data []byte) error {
+	path := filepath.Join(a.dir, filepath.FromSlash(filepath.Clean("/"+key)))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
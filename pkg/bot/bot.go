@@ -1,65 +1,158 @@
 package bot
 
 import (
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
+	"time"
 
 	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
 )
 
 type Bot struct {
+	// Session of the first shard of this process, also used for requests that are not bound to a shard,
+	// e.g. command sync
 	*discord.Session
+	// Sessions of every shard run by this process
+	Shards []*discord.Session
 
 	Router *Router
+
+	reloadHandlers []func()
+}
+
+// AddReloadHandler adds a handler that is called when the process receives SIGHUP
+func (b *Bot) AddReloadHandler(handler func()) {
+	b.reloadHandlers = append(b.reloadHandlers, handler)
+}
+
+// AddHandler adds the event handler to every shard, see discord.Session.AddHandler
+func (b *Bot) AddHandler(handler any) {
+	for _, shard := range b.Shards {
+		shard.AddHandler(handler)
+	}
 }
 
-func NewBot(token string) (*Bot, error) {
+func NewBot(token string, shards ShardsConfig) (*Bot, error) {
 	session, err := discord.New("Bot " + token)
 	if err != nil {
 		return nil, err
 	}
+	session.Client = tracing.Client(session.Client)
+	sessions, err := newShards(session, shards)
+	if err != nil {
+		return nil, err
+	}
 	return &Bot{
 		Session: session,
+		Shards:  sessions,
 		Router:  NewRouter(nil),
 	}, nil
 }
 
-func (b *Bot) Run(guildID string, removeCommands bool) {
-	// IntentMessageContent is required for us to have a conversation in threads without typing any commands
-	b.Identify.Intents = discord.MakeIntent(discord.IntentsAllWithoutPrivileged | discord.IntentMessageContent)
+// OwnsCommands reports whether this process syncs and removes commands of the application. Only the process
+// that runs shard 0 does, so that processes of a sharded bot do not race each other to change them
+func (b *Bot) OwnsCommands() bool {
+	return slices.ContainsFunc(b.Shards, func(shard *discord.Session) bool { return shard.ShardID == 0 })
+}
+
+// Run connects to Discord, syncs commands in the guilds if this process owns them, see Router.Sync and
+// OwnsCommands, and handles events until the process is stopped. On shutdown it waits up to shutdownTimeout
+// for interactions and messages in progress, see Router.Shutdown
+func (b *Bot) Run(guilds []string, removeCommands bool, shutdownTimeout time.Duration) {
+	for _, shard := range b.Shards {
+		// IntentMessageContent is required for us to have a conversation in threads without typing any commands
+		shard.Identify.Intents = discord.MakeIntent(discord.IntentsAllWithoutPrivileged | discord.IntentMessageContent)
+	}
 
 	// Add handlers
 	b.AddHandler(func(s *discord.Session, r *discord.Ready) {
-		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
+		slog.Info("Logged in", "user", s.State.User.Username+"#"+s.State.User.Discriminator, "shard", s.ShardID, "guilds", len(r.Guilds))
 	})
 	b.AddHandler(b.Router.HandleInteraction)
 	b.AddHandler(b.Router.HandleMessage)
 
 	// Run the bot
-	err := b.Open()
+	err := openShards(b.Shards)
 	if err != nil {
-		log.Fatalf("Cannot open the session: %v", err)
+		slog.Error("Cannot open the session", "error", err)
+		b.close()
+		os.Exit(1)
 	}
 
 	// Sync commands
-	err = b.Router.Sync(b.Session, guildID)
-	if err != nil {
-		panic(err)
+	if b.OwnsCommands() {
+		err = b.Router.Sync(b.Session, guilds)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		slog.Info("Commands are synced by the process that runs shard 0")
+		b.Router.MarkSynced()
 	}
 
-	defer b.Close()
+	defer b.close()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-	<-stop
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+wait:
+	for {
+		select {
+		case <-reload:
+			slog.Info("Received SIGHUP, reloading")
+			for _, handler := range b.reloadHandlers {
+				handler()
+			}
+		case <-stop:
+			break wait
+		}
+	}
+
+	b.Router.Shutdown(shutdownTimeout)
+
+	// Unregister commands if requested, other processes may still be serving them otherwise
+	if removeCommands && b.OwnsCommands() {
+		slog.Info("Removing commands")
+		if err := b.Router.ClearCommands(b.Session, guilds); err != nil {
+			slog.Error("Cannot remove commands", "error", err)
+		}
+	}
+
+	slog.Info("Gracefully shutting down")
+}
+
+// DryRunSync prints changes that Run would make to registered commands, without connecting to the gateway
+func (b *Bot) DryRunSync(guilds []string) error {
+	if b.State.User == nil {
+		user, err := b.User("@me")
+		if err != nil {
+			return err
+		}
+		b.State.User = user
+	}
 
-	// Unregister commands if requested
-	if removeCommands {
-		log.Println("Removing commands...")
-		b.Router.ClearCommands(b.Session, guildID)
+	changes, err := b.Router.Diff(b.Session, guilds)
+	for _, change := range changes {
+		fmt.Println(change)
 	}
+	if err == nil && len(changes) == 0 {
+		fmt.Println("Commands are up to date")
+	}
+	return err
+}
 
-	log.Println("Gracefully shutting down.")
+// close disconnects every shard from the gateway
+func (b *Bot) close() {
+	for _, shard := range b.Shards {
+		if err := shard.Close(); err != nil {
+			slog.Error("Cannot close the session", "shard", shard.ShardID, "error", err)
+		}
+	}
 }
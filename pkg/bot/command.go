@@ -27,10 +27,19 @@ type Command struct {
 	DefaultMemberPermissions int64
 	Options                  []*discord.ApplicationCommandOption
 	Type                     discord.ApplicationCommandType
+	// Name and description in other languages, keyed by locale
+	NameLocalizations        map[discord.Locale]string
+	DescriptionLocalizations map[discord.Locale]string
 
 	Handler        Handler
 	Middlewares    []Handler
 	MessageHandler MessageHandler
+	// MessageMiddlewares run before message handlers of the command and all of its subcommands
+	MessageMiddlewares []MessageHandler
+	// ComponentHandler handles message components, e.g. buttons, with a custom ID made by ComponentCustomID.
+	// It runs before command middlewares and handler, and is expected to fill Options from the custom ID
+	// arguments and call Next, or respond on its own
+	ComponentHandler Handler
 
 	SubCommands *Router
 }
@@ -44,6 +53,12 @@ func (cmd Command) ApplicationCommand() *discord.ApplicationCommand {
 		Options:                  cmd.Options,
 		Type:                     cmd.Type,
 	}
+	if len(cmd.NameLocalizations) != 0 {
+		applicationCommand.NameLocalizations = &cmd.NameLocalizations
+	}
+	if len(cmd.DescriptionLocalizations) != 0 {
+		applicationCommand.DescriptionLocalizations = &cmd.DescriptionLocalizations
+	}
 	for _, subcommand := range cmd.SubCommands.List() {
 		applicationCommand.Options = append(applicationCommand.Options, subcommand.ApplicationCommandOption())
 	}
@@ -59,9 +74,11 @@ func (cmd Command) ApplicationCommandOption() *discord.ApplicationCommandOption
 	}
 
 	return &discord.ApplicationCommandOption{
-		Name:        applicationCommand.Name,
-		Description: applicationCommand.Description,
-		Options:     applicationCommand.Options,
-		Type:        typ,
+		Name:                     applicationCommand.Name,
+		NameLocalizations:        cmd.NameLocalizations,
+		Description:              applicationCommand.Description,
+		DescriptionLocalizations: cmd.DescriptionLocalizations,
+		Options:                  applicationCommand.Options,
+		Type:                     typ,
 	}
 }
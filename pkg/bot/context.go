@@ -1,7 +1,15 @@
 package bot
 
 import (
+	"context"
+	"log/slog"
+	"strings"
+
 	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type OptionsMap = map[string]*discord.ApplicationCommandInteractionDataOption
@@ -11,8 +19,16 @@ type Context struct {
 	Caller      *Command
 	Interaction *discord.Interaction
 	Options     OptionsMap
+	// Names of the invoked command and its subcommands, e.g. [image dalle]
+	Path []string
+	// Arguments of the message component custom ID that follow the command path
+	ComponentArgs []string
+	// Logger with guild, channel, user, interaction and command of the context
+	Logger *slog.Logger
 
-	handlers []Handler
+	handlers       []Handler
+	requestContext context.Context
+	request        *request
 }
 
 func makeOptionMap(options []*discord.ApplicationCommandInteractionDataOption) (m OptionsMap) {
@@ -26,33 +42,125 @@ func makeOptionMap(options []*discord.ApplicationCommandInteractionDataOption) (
 }
 
 func NewContext(s *discord.Session, caller *Command, i *discord.Interaction, parent *discord.ApplicationCommandInteractionDataOption, handlers []Handler) *Context {
-	options := i.ApplicationCommandData().Options
+	var options []*discord.ApplicationCommandInteractionDataOption
+	if i.Type == discord.InteractionApplicationCommand {
+		options = i.ApplicationCommandData().Options
+	}
 	if parent != nil {
 		options = parent.Options
 	}
+	logger := slog.Default().With("guild", i.GuildID, "channel", i.ChannelID, "interaction", i.ID)
+	if user := interactionUser(i); user != nil {
+		logger = logger.With("user", user.ID)
+	}
 	return &Context{
 		Session:     s,
 		Caller:      caller,
 		Interaction: i,
 		Options:     makeOptionMap(options),
+		Logger:      logger,
 
 		handlers: handlers,
 	}
 }
 
+func NewComponentContext(s *discord.Session, caller *Command, i *discord.Interaction, path []string, args []string, handlers []Handler) *Context {
+	ctx := NewContext(s, caller, i, nil, handlers)
+	ctx.setPath(path)
+	ctx.ComponentArgs = args
+	return ctx
+}
+
+// Locale is the language of the user, or of the guild if it is unknown
+func (ctx *Context) Locale() discord.Locale {
+	if ctx.Interaction.Locale != "" {
+		return ctx.Interaction.Locale
+	}
+	if ctx.Interaction.GuildLocale != nil {
+		return *ctx.Interaction.GuildLocale
+	}
+	return i18n.Default
+}
+
+// T translates the message to the language of the user, see i18n.T
+func (ctx *Context) T(key string, args ...any) string {
+	return i18n.T(ctx.Locale(), key, args...)
+}
+
+func (ctx *Context) setPath(path []string) {
+	ctx.Path = path
+	ctx.Logger = ctx.Logger.With("command", strings.Join(path, " "))
+}
+
+// run handles the interaction within its span
+func (ctx *Context) run(name string, req *request) {
+	attributes := []attribute.KeyValue{
+		attribute.String("discord.guild", ctx.Interaction.GuildID),
+		attribute.String("discord.channel", ctx.Interaction.ChannelID),
+		attribute.String("discord.interaction", ctx.Interaction.ID),
+		attribute.String("command", strings.Join(ctx.Path, " ")),
+	}
+	if user := interactionUser(ctx.Interaction); user != nil {
+		attributes = append(attributes, attribute.String("discord.user", user.ID))
+	}
+	requestContext, span := tracing.Start(req.ctx, name, attributes...)
+	defer span.End()
+
+	ctx.requestContext = requestContext
+	ctx.request = req
+	ctx.Logger = withTrace(ctx.Logger, span)
+	ctx.Next()
+}
+
+// withTrace adds trace ID to the logger, so logs of sampled interactions and messages can be found by their traces
+func withTrace(logger *slog.Logger, span trace.Span) *slog.Logger {
+	if !span.SpanContext().IsSampled() {
+		return logger
+	}
+	return logger.With("trace", span.SpanContext().TraceID().String())
+}
+
+// interactionUser returns the user of guild and DM interactions
+func interactionUser(i *discord.Interaction) *discord.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+// Context carries the span of the interaction, pass it to providers and Discord REST calls
+// (with discordgo.WithContext) so they are traced as its children. It is cancelled when the bot
+// shuts down before the interaction is handled
+func (ctx *Context) Context() context.Context {
+	if ctx.requestContext == nil {
+		return context.Background()
+	}
+	return ctx.requestContext
+}
+
+// OnInterrupt adds a function that runs if the bot shuts down before the interaction is handled,
+// e.g. to replace a pending message with the "bot.interrupted" message. The context is cancelled by then, so
+// the function must not use it. Remove the function once there is nothing to clean up anymore
+func (ctx *Context) OnInterrupt(f func()) (remove func()) {
+	if ctx.request == nil {
+		return func() {}
+	}
+	return ctx.request.onInterrupt(f)
+}
+
 func (ctx *Context) Respond(response *discord.InteractionResponse) error {
-	return ctx.Session.InteractionRespond(ctx.Interaction, response)
+	return ctx.Session.InteractionRespond(ctx.Interaction, response, discord.WithContext(ctx.Context()))
 }
 
 func (ctx *Context) Edit(content string) error {
 	_, err := ctx.Session.InteractionResponseEdit(ctx.Interaction, &discord.WebhookEdit{
 		Content: &content,
-	})
+	}, discord.WithContext(ctx.Context()))
 	return err
 }
 
 func (ctx *Context) Response() (*discord.Message, error) {
-	return ctx.Session.InteractionResponse(ctx.Interaction)
+	return ctx.Session.InteractionResponse(ctx.Interaction, discord.WithContext(ctx.Context()))
 }
 
 func (ctx *Context) Next() {
@@ -70,25 +178,97 @@ type MessageContext struct {
 	*discord.Session
 	Caller  *Command
 	Message *discord.Message
+	// Names of the command and its subcommands that own the message handler, e.g. [chat gpt]
+	Path []string
+	// Logger with guild, channel, user, message and command of the context
+	Logger *slog.Logger
 
-	handlers []MessageHandler
+	handlers       []MessageHandler
+	requestContext context.Context
+	request        *request
 }
 
 func NewMessageContext(s *discord.Session, caller *Command, m *discord.Message, handlers []MessageHandler) *MessageContext {
+	logger := slog.Default().With("guild", m.GuildID, "channel", m.ChannelID, "message", m.ID)
+	if m.Author != nil {
+		logger = logger.With("user", m.Author.ID)
+	}
 	return &MessageContext{
 		Session: s,
 		Caller:  caller,
 		Message: m,
+		Logger:  logger,
 
 		handlers: handlers,
 	}
 }
 
+// Locale is the language of the guild, messages do not tell the language of their authors
+func (ctx *MessageContext) Locale() discord.Locale {
+	return GuildLocale(ctx.Session, ctx.Message.GuildID)
+}
+
+// T translates the message to the language of the guild, see i18n.T
+func (ctx *MessageContext) T(key string, args ...any) string {
+	return i18n.T(ctx.Locale(), key, args...)
+}
+
+// GuildLocale is the preferred language of the guild, for responses that are not made to a user
+func GuildLocale(s *discord.Session, guildID string) discord.Locale {
+	guild, err := s.State.Guild(guildID)
+	if err != nil || guild.PreferredLocale == "" {
+		return i18n.Default
+	}
+	return discord.Locale(guild.PreferredLocale)
+}
+
+func (ctx *MessageContext) setPath(path []string) {
+	ctx.Path = path
+	ctx.Logger = ctx.Logger.With("command", strings.Join(path, " "))
+}
+
+// run handles the message within its span
+func (ctx *MessageContext) run(name string, req *request) {
+	attributes := []attribute.KeyValue{
+		attribute.String("discord.guild", ctx.Message.GuildID),
+		attribute.String("discord.channel", ctx.Message.ChannelID),
+		attribute.String("discord.message", ctx.Message.ID),
+		attribute.String("command", strings.Join(ctx.Path, " ")),
+	}
+	if ctx.Message.Author != nil {
+		attributes = append(attributes, attribute.String("discord.user", ctx.Message.Author.ID))
+	}
+	requestContext, span := tracing.Start(req.ctx, name, attributes...)
+	defer span.End()
+
+	ctx.requestContext = requestContext
+	ctx.request = req
+	ctx.Logger = withTrace(ctx.Logger, span)
+	ctx.Next()
+}
+
+// Context carries the span of the message, see Context.Context
+func (ctx *MessageContext) Context() context.Context {
+	if ctx.requestContext == nil {
+		return context.Background()
+	}
+	return ctx.requestContext
+}
+
+// OnInterrupt adds a function that runs if the bot shuts down before the message is handled, see Context.OnInterrupt
+func (ctx *MessageContext) OnInterrupt(f func()) (remove func()) {
+	if ctx.request == nil {
+		return func() {}
+	}
+	return ctx.request.onInterrupt(f)
+}
+
 func (ctx *MessageContext) Reply(content string) (m *discord.Message, err error) {
 	m, err = ctx.Session.ChannelMessageSendReply(
 		ctx.Message.ChannelID,
 		content,
 		ctx.Message.Reference(),
+		discord.WithContext(ctx.Context()),
 	)
 	return
 }
@@ -98,20 +278,21 @@ func (ctx *MessageContext) EmbedReply(embed *discord.MessageEmbed) (m *discord.M
 		ctx.Message.ChannelID,
 		embed,
 		ctx.Message.Reference(),
+		discord.WithContext(ctx.Context()),
 	)
 	return
 }
 
 func (ctx *MessageContext) AddReaction(emojiID string) error {
-	return ctx.Session.MessageReactionAdd(ctx.Message.ChannelID, ctx.Message.ID, emojiID)
+	return ctx.Session.MessageReactionAdd(ctx.Message.ChannelID, ctx.Message.ID, emojiID, discord.WithContext(ctx.Context()))
 }
 
 func (ctx *MessageContext) RemoveReaction(emojiID string) error {
-	return ctx.Session.MessageReactionsRemoveEmoji(ctx.Message.ChannelID, ctx.Message.ID, emojiID)
+	return ctx.Session.MessageReactionsRemoveEmoji(ctx.Message.ChannelID, ctx.Message.ID, emojiID, discord.WithContext(ctx.Context()))
 }
 
 func (ctx *MessageContext) ChannelTyping() error {
-	return ctx.Session.ChannelTyping(ctx.Message.ChannelID)
+	return ctx.Session.ChannelTyping(ctx.Message.ChannelID, discord.WithContext(ctx.Context()))
 }
 
 func (ctx *MessageContext) Next() {
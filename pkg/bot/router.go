@@ -1,16 +1,30 @@
 package bot
 
 import (
-	"fmt"
-	"log"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
 	discord "github.com/bwmarrin/discordgo"
 )
 
 type Router struct {
-	commands           map[string]*Command
-	registeredCommands []*discord.ApplicationCommand
+	mu       sync.RWMutex
+	commands map[string]*Command
+
+	// Handlers that run before handlers of every command, see Use and UseMessage
+	middlewares        []Handler
+	messageMiddlewares []MessageHandler
+
+	syncMu sync.Mutex
+	synced bool
+
+	// Interactions and messages in progress, see Shutdown
+	requestsMu sync.Mutex
+	requests   map[*request]struct{}
+	inflight   sync.WaitGroup
+	draining   bool
 }
 
 func NewRouter(initial []*Command) (r *Router) {
@@ -23,15 +37,51 @@ func NewRouter(initial []*Command) (r *Router) {
 }
 
 func (r *Router) Register(cmd *Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if _, ok := r.commands[cmd.Name]; !ok {
 		r.commands[cmd.Name] = cmd
 	}
 }
 
+// Replace registers the command, or atomically swaps the already registered one with the same name.
+// Changed definitions reach Discord on the next Sync
+func (r *Router) Replace(cmd *Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.commands[cmd.Name] = cmd
+}
+
+// Unregister removes the command, it is removed from Discord on the next Sync
+func (r *Router) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.commands, name)
+}
+
+// Use adds middlewares that run before middlewares of every command and message component.
+// Must be called before the router handles interactions
+func (r *Router) Use(middlewares ...Handler) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// UseMessage adds middlewares that run before every message handler.
+// Must be called before the router handles messages
+func (r *Router) UseMessage(middlewares ...MessageHandler) {
+	r.messageMiddlewares = append(r.messageMiddlewares, middlewares...)
+}
+
 func (r *Router) Get(name string) *Command {
 	if r == nil {
 		return nil
 	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	return r.commands[name]
 }
 
@@ -40,9 +90,15 @@ func (r *Router) List() (list []*Command) {
 		return nil
 	}
 
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	for _, c := range r.commands {
 		list = append(list, c)
 	}
+	// Commands are kept in a map, sorting makes subcommand options of the application command stable,
+	// so that unchanged commands are not registered again
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
 	return
 }
 
@@ -50,9 +106,26 @@ func (r *Router) Count() (c int) {
 	if r == nil {
 		return 0
 	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	return len(r.commands)
 }
 
+// Paths lists sorted paths of registered commands and all their subcommands, e.g. "image" and "image dalle"
+func (r *Router) Paths() []string {
+	var paths []string
+	for _, cmd := range r.List() {
+		paths = append(paths, cmd.Name)
+		for _, path := range cmd.SubCommands.Paths() {
+			paths = append(paths, cmd.Name+" "+path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
 func (r *Router) getSubcommand(cmd *Command, opt *discord.ApplicationCommandInteractionDataOption, parent []Handler) (*Command, *discord.ApplicationCommandInteractionDataOption, []Handler) {
 	if cmd == nil {
 		return nil, nil, nil
@@ -69,84 +142,133 @@ func (r *Router) getSubcommand(cmd *Command, opt *discord.ApplicationCommandInte
 	return cmd, nil, append(parent, cmd.Handler)
 }
 
-func (r *Router) getMessageHandlers(cmd *Command) []MessageHandler {
-	var handlers []MessageHandler
+type messageHandlerPath struct {
+	path     []string
+	handlers []MessageHandler
+}
+
+// getMessageHandlers returns message handlers of the command and its subcommands, each one preceded by
+// message middlewares of its parent commands
+func (r *Router) getMessageHandlers(cmd *Command, parentPath []string, parent []MessageHandler) []messageHandlerPath {
+	var handlers []messageHandlerPath
+	path := append(append([]string{}, parentPath...), cmd.Name)
+	middlewares := append(append([]MessageHandler{}, parent...), cmd.MessageMiddlewares...)
 
 	if cmd.MessageHandler != nil {
-		handlers = append(handlers, cmd.MessageHandler)
+		handlers = append(handlers, messageHandlerPath{path: path, handlers: append(middlewares, cmd.MessageHandler)})
 	}
 
 	if cmd.SubCommands != nil {
 		for _, cmd := range cmd.SubCommands.List() {
-			handlers = append(handlers, r.getMessageHandlers(cmd)...)
+			handlers = append(handlers, r.getMessageHandlers(cmd, path, middlewares)...)
 		}
 	}
 
 	return handlers
 }
 
+const componentCustomIDSeparator = ":"
+
+// ComponentCustomID makes a message component custom ID that is routed to the ComponentHandler of the command
+// at the given path, e.g. ComponentCustomID([]string{"image", "dalle"}, "reroll")
+func ComponentCustomID(path []string, args ...string) string {
+	return strings.Join(append(append([]string{}, path...), args...), componentCustomIDSeparator)
+}
+
 func (r *Router) HandleInteraction(s *discord.Session, i *discord.InteractionCreate) {
-	if i.Type != discord.InteractionApplicationCommand {
-		return
+	switch i.Type {
+	case discord.InteractionApplicationCommand:
+		r.handleApplicationCommand(s, i)
+	case discord.InteractionMessageComponent:
+		r.handleComponent(s, i)
 	}
+}
 
-	data := i.ApplicationCommandData()
-	cmd := r.Get(data.Name)
+func (r *Router) handleComponent(s *discord.Session, i *discord.InteractionCreate) {
+	args := strings.Split(i.MessageComponentData().CustomID, componentCustomIDSeparator)
+	cmd := r.Get(args[0])
 	if cmd == nil {
 		return
 	}
+	path := []string{args[0]}
+	args = args[1:]
 
-	var parent *discord.ApplicationCommandInteractionDataOption
-	handlers := append(cmd.Middlewares, cmd.Handler)
-	if len(data.Options) != 0 {
-		cmd, parent, handlers = r.getSubcommand(cmd, data.Options[0], cmd.Middlewares)
+	middlewares := append([]Handler{}, r.middlewares...)
+	handlers := append([]Handler{}, cmd.Middlewares...)
+	for len(args) > 0 {
+		subcommand := cmd.SubCommands.Get(args[0])
+		if subcommand == nil {
+			break
+		}
+		cmd = subcommand
+		path = append(path, args[0])
+		args = args[1:]
+		handlers = append(handlers, cmd.Middlewares...)
 	}
 
-	if cmd != nil {
-		ctx := NewContext(s, cmd, i.Interaction, parent, handlers)
-		ctx.Next()
+	if cmd.ComponentHandler == nil {
+		return
 	}
-}
+	handlers = append(append(append(middlewares, cmd.ComponentHandler), handlers...), cmd.Handler)
 
-func (r *Router) HandleMessage(s *discord.Session, m *discord.MessageCreate) {
-	for _, cmd := range r.commands {
-		handlers := r.getMessageHandlers(cmd)
-		if len(handlers) > 0 {
-			ctx := NewMessageContext(s, cmd, m.Message, handlers)
-			ctx.Next()
-		}
+	req, ok := r.begin()
+	if !ok {
+		respondRestarting(s, i.Interaction)
+		return
 	}
+	defer r.end(req)
+
+	ctx := NewComponentContext(s, cmd, i.Interaction, path, args, handlers)
+	ctx.run("component /"+strings.Join(path, " "), req)
 }
 
-func (r *Router) Sync(s *discord.Session, guild string) (err error) {
-	if s.State.User == nil {
-		return fmt.Errorf("cannot determine application id")
+func (r *Router) handleApplicationCommand(s *discord.Session, i *discord.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	cmd := r.Get(data.Name)
+	if cmd == nil {
+		return
 	}
 
-	var commands []*discord.ApplicationCommand
-	for _, c := range r.commands {
-		commands = append(commands, c.ApplicationCommand())
+	var parent *discord.ApplicationCommandInteractionDataOption
+	middlewares := append(append([]Handler{}, r.middlewares...), cmd.Middlewares...)
+	handlers := append(middlewares, cmd.Handler)
+	if len(data.Options) != 0 {
+		cmd, parent, handlers = r.getSubcommand(cmd, data.Options[0], middlewares)
 	}
 
-	r.registeredCommands, err = s.ApplicationCommandBulkOverwrite(s.State.User.ID, guild, commands)
-	return
-}
-
-func (r *Router) ClearCommands(s *discord.Session, guild string) (errors []error) {
-	if s.State.User == nil {
-		return []error{fmt.Errorf("cannot determine application id")}
-	}
+	if cmd != nil {
+		req, ok := r.begin()
+		if !ok {
+			respondRestarting(s, i.Interaction)
+			return
+		}
+		defer r.end(req)
 
-	for _, v := range r.registeredCommands {
-		err := s.ApplicationCommandDelete(s.State.User.ID, guild, v.ID)
-		if err != nil {
-			log.Panicf("Cannot delete '%v' command: %v", v.Name, err)
+		ctx := NewContext(s, cmd, i.Interaction, parent, handlers)
+		path := []string{data.Name}
+		for options := data.Options; len(options) > 0; options = options[0].Options {
+			if options[0].Type != discord.ApplicationCommandOptionSubCommand && options[0].Type != discord.ApplicationCommandOptionSubCommandGroup {
+				break
+			}
+			path = append(path, options[0].Name)
 		}
+		ctx.setPath(path)
+		ctx.run("interaction /"+strings.Join(path, " "), req)
 	}
+}
 
-	if len(errors) == 0 {
-		return nil
+func (r *Router) HandleMessage(s *discord.Session, m *discord.MessageCreate) {
+	req, ok := r.begin()
+	if !ok {
+		return
 	}
+	defer r.end(req)
 
-	return errors
+	for _, cmd := range r.List() {
+		for _, h := range r.getMessageHandlers(cmd, nil, r.messageMiddlewares) {
+			ctx := NewMessageContext(s, cmd, m.Message, h.handlers)
+			ctx.setPath(h.path)
+			ctx.run("message /"+strings.Join(h.path, " "), req)
+		}
+	}
 }
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+// Discord allows one identify per 5 seconds for bots without large bot sharding
+const identifyInterval = 5 * time.Second
+
+type ShardsConfig struct {
+	// Total number of shards of the bot. If 0 - the number recommended by Discord gateway
+	Count int `yaml:"count"`
+	// Shards run by this process, the other ones are run by other processes. If empty - all of them
+	IDs []int `yaml:"ids"`
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *ShardsConfig) Validate() error {
+	var errs []error
+	if c.Count < 0 {
+		errs = append(errs, fmt.Errorf("count: %d must not be negative", c.Count))
+	}
+	if len(c.IDs) > 0 && c.Count == 0 {
+		errs = append(errs, fmt.Errorf("ids: shard count is required to split shards between processes"))
+	}
+	seen := make(map[int]struct{}, len(c.IDs))
+	for i, id := range c.IDs {
+		if id < 0 || (c.Count > 0 && id >= c.Count) {
+			errs = append(errs, fmt.Errorf("ids[%d]: %d is out of range of %d shards", i, id, c.Count))
+		}
+		if _, ok := seen[id]; ok {
+			errs = append(errs, fmt.Errorf("ids[%d]: duplicate shard %d", i, id))
+		}
+		seen[id] = struct{}{}
+	}
+	return errors.Join(errs...)
+}
+
+// shardIDs resolves shards of this process, asking the gateway for the shard count if it is not configured
+func (c *ShardsConfig) shardIDs(s *discord.Session) (ids []int, count int, err error) {
+	count = c.Count
+	if count == 0 {
+		gateway, err := s.GatewayBot()
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot get recommended shard count: %w", err)
+		}
+		count = max(gateway.Shards, 1)
+	}
+	if len(c.IDs) > 0 {
+		return c.IDs, count, nil
+	}
+	for id := 0; id < count; id++ {
+		ids = append(ids, id)
+	}
+	return ids, count, nil
+}
+
+// newShards makes a session for every shard of this process. The first session is s itself, the other ones
+// share its HTTP client and REST rate limits
+func newShards(s *discord.Session, config ShardsConfig) ([]*discord.Session, error) {
+	ids, count, err := config.shardIDs(s)
+	if err != nil {
+		return nil, err
+	}
+	shards := make([]*discord.Session, 0, len(ids))
+	for i, id := range ids {
+		shard := s
+		if i > 0 {
+			if shard, err = discord.New(s.Token); err != nil {
+				return nil, err
+			}
+			shard.Client = s.Client
+			shard.Ratelimiter = s.Ratelimiter
+		}
+		shard.ShardID = id
+		shard.ShardCount = count
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
+
+// openShards connects shards to the gateway one by one, as Discord rate limits identifying
+func openShards(shards []*discord.Session) error {
+	for i, shard := range shards {
+		if i > 0 {
+			time.Sleep(identifyInterval)
+		}
+		slog.Info("Connecting shard", "shard", shard.ShardID, "shards", shard.ShardCount)
+		if err := shard.Open(); err != nil {
+			return fmt.Errorf("cannot open shard %d: %w", shard.ShardID, err)
+		}
+	}
+	return nil
+}
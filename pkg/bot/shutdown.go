@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+)
+
+// Interrupt handlers only edit messages and unlock threads, they do not need much time
+const interruptTimeout = 10 * time.Second
+
+// request is an interaction or message that is being handled
+type request struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu          sync.Mutex
+	interrupts  []func()
+	interrupted bool
+}
+
+// onInterrupt adds a function that runs if the request is cancelled by shutdown
+func (r *request) onInterrupt(f func()) (remove func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.interrupted {
+		// the request is cancelled already, whatever it is about to do will not happen
+		return func() {}
+	}
+	i := len(r.interrupts)
+	r.interrupts = append(r.interrupts, f)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if i < len(r.interrupts) {
+			r.interrupts[i] = nil
+		}
+	}
+}
+
+// interrupt cancels the request and runs its interrupt functions, the latest added first
+func (r *request) interrupt() {
+	r.cancel()
+
+	r.mu.Lock()
+	interrupts := r.interrupts
+	r.interrupts = nil
+	r.interrupted = true
+	r.mu.Unlock()
+
+	for i := len(interrupts) - 1; i >= 0; i-- {
+		if interrupts[i] != nil {
+			interrupts[i]()
+		}
+	}
+}
+
+// begin starts tracking a request, unless the router is shutting down
+func (r *Router) begin() (*request, bool) {
+	r.requestsMu.Lock()
+	defer r.requestsMu.Unlock()
+
+	if r.draining {
+		return nil, false
+	}
+	req := &request{}
+	req.ctx, req.cancel = context.WithCancel(context.Background())
+	if r.requests == nil {
+		r.requests = make(map[*request]struct{})
+	}
+	r.requests[req] = struct{}{}
+	r.inflight.Add(1)
+	return req, true
+}
+
+func (r *Router) end(req *request) {
+	r.requestsMu.Lock()
+	delete(r.requests, req)
+	r.requestsMu.Unlock()
+
+	req.cancel()
+	r.inflight.Done()
+}
+
+// Shutdown stops handling new interactions and messages, and waits for the ones in progress until
+// the timeout. Requests that are still in progress then are cancelled and their interrupt functions
+// run, see Context.OnInterrupt
+func (r *Router) Shutdown(timeout time.Duration) {
+	r.requestsMu.Lock()
+	r.draining = true
+	count := len(r.requests)
+	r.requestsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+
+	if count > 0 {
+		slog.Info("Waiting for requests in progress", "requests", count, "timeout", timeout)
+	}
+	select {
+	case <-done:
+		return
+	case <-time.After(timeout):
+	}
+
+	r.requestsMu.Lock()
+	requests := make([]*request, 0, len(r.requests))
+	for req := range r.requests {
+		requests = append(requests, req)
+	}
+	r.requestsMu.Unlock()
+
+	slog.Warn("Cancelling requests in progress", "requests", len(requests))
+	var wg sync.WaitGroup
+	for _, req := range requests {
+		wg.Add(1)
+		go func(req *request) {
+			defer wg.Done()
+			req.interrupt()
+		}(req)
+	}
+	wg.Wait()
+
+	select {
+	case <-done:
+	case <-time.After(interruptTimeout):
+		slog.Warn("Requests did not stop after cancellation")
+	}
+}
+
+// respondRestarting tells the user that the interaction cannot be handled because the bot is shutting down
+func respondRestarting(s *discord.Session, i *discord.Interaction) {
+	err := s.InteractionRespond(i, &discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags:   discord.MessageFlagsEphemeral,
+			Content: i18n.T(i.Locale, "bot.restarting"),
+		},
+	})
+	if err != nil {
+		slog.Error("Failed to respond to interaction", "interaction", i.ID, "error", err)
+	}
+}
@@ -0,0 +1,299 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+type CommandAction string
+
+const (
+	CommandCreate CommandAction = "create"
+	CommandEdit   CommandAction = "edit"
+	CommandDelete CommandAction = "delete"
+)
+
+// CommandChange is a difference between a command of the router and the command registered in Discord
+type CommandChange struct {
+	Action CommandAction
+	// Guild the command is registered in, empty for global commands
+	Guild string
+	Name  string
+	// Changed fields of edited commands, e.g. `options[0].choices: ["a"] -> ["a" "b"]`
+	Fields []string
+
+	id      string
+	command *discord.ApplicationCommand
+}
+
+func (c CommandChange) String() string {
+	target := "globally"
+	if c.Guild != "" {
+		target = "in guild " + c.Guild
+	}
+	s := fmt.Sprintf("%s /%s %s", c.Action, c.Name, target)
+	for _, field := range c.Fields {
+		s += "\n    " + field
+	}
+	return s
+}
+
+func applicationID(s *discord.Session) (string, error) {
+	if s.State.User == nil {
+		return "", fmt.Errorf("cannot determine application id")
+	}
+	return s.State.User.ID, nil
+}
+
+// Diff compares commands of the router with commands registered in Discord, in every guild of the list.
+// Empty guild ID stands for global commands
+func (r *Router) Diff(s *discord.Session, guilds []string) ([]CommandChange, error) {
+	commands := make(map[string]*discord.ApplicationCommand)
+	for _, c := range r.List() {
+		commands[c.Name] = c.ApplicationCommand()
+	}
+	return diffRegistered(s, guilds, commands)
+}
+
+func diffRegistered(s *discord.Session, guilds []string, commands map[string]*discord.ApplicationCommand) ([]CommandChange, error) {
+	appID, err := applicationID(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []CommandChange
+	var errs []error
+	for _, guild := range guilds {
+		registered, err := s.ApplicationCommands(appID, guild)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot get commands of guild %q: %w", guild, err))
+			continue
+		}
+		changes = append(changes, diffCommands(guild, registered, commands)...)
+	}
+	return changes, errors.Join(errs...)
+}
+
+func diffCommands(guild string, registered []*discord.ApplicationCommand, commands map[string]*discord.ApplicationCommand) (changes []CommandChange) {
+	existing := make(map[string]*discord.ApplicationCommand, len(registered))
+	for _, c := range registered {
+		existing[c.Name] = c
+	}
+
+	for _, name := range sortedNames(commands) {
+		c := commands[name]
+		old, ok := existing[name]
+		if !ok {
+			changes = append(changes, CommandChange{Action: CommandCreate, Guild: guild, Name: name, command: c})
+			continue
+		}
+		if fields := diffCommand(old, c); len(fields) != 0 {
+			changes = append(changes, CommandChange{Action: CommandEdit, Guild: guild, Name: name, Fields: fields, id: old.ID, command: c})
+		}
+	}
+	for _, name := range sortedNames(existing) {
+		if _, ok := commands[name]; !ok {
+			changes = append(changes, CommandChange{Action: CommandDelete, Guild: guild, Name: name, id: existing[name].ID})
+		}
+	}
+	return
+}
+
+// Sync registers commands of the router in Discord, in every guild of the list. Empty guild ID stands
+// for global commands. Only commands that differ from the registered ones are created, edited or deleted
+func (r *Router) Sync(s *discord.Session, guilds []string) error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	changes, err := r.Diff(s, guilds)
+	errs := []error{err}
+	errs = append(errs, applyChanges(s, changes)...)
+	err = errors.Join(errs...)
+	if err == nil {
+		r.synced = true
+	}
+	return err
+}
+
+// MarkSynced marks commands as registered without syncing them, for processes that leave it to another one
+func (r *Router) MarkSynced() {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	r.synced = true
+}
+
+// Synced reports whether commands were registered in Discord at least once
+func (r *Router) Synced() bool {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	return r.synced
+}
+
+// ClearCommands deletes all commands of the application in every guild of the list, see Sync
+func (r *Router) ClearCommands(s *discord.Session, guilds []string) error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	changes, err := diffRegistered(s, guilds, nil)
+	errs := []error{err}
+	errs = append(errs, applyChanges(s, changes)...)
+	r.synced = false
+	return errors.Join(errs...)
+}
+
+func applyChanges(s *discord.Session, changes []CommandChange) (errs []error) {
+	appID, err := applicationID(s)
+	if err != nil {
+		return []error{err}
+	}
+
+	for _, change := range changes {
+		slog.Info("Syncing command", "action", change.Action, "command", change.Name, "guild", change.Guild, "changes", change.Fields)
+		var err error
+		switch change.Action {
+		case CommandCreate:
+			_, err = s.ApplicationCommandCreate(appID, change.Guild, change.command)
+		case CommandEdit:
+			_, err = s.ApplicationCommandEdit(appID, change.Guild, change.id, change.command)
+		case CommandDelete:
+			err = s.ApplicationCommandDelete(appID, change.Guild, change.id)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot %s '%v' command: %w", change.Action, change.Name, err))
+		}
+	}
+	return
+}
+
+// diffCommand lists fields of the registered command that differ from the new definition.
+// Fields that Discord fills with defaults are compared with their defaults
+func diffCommand(old *discord.ApplicationCommand, new *discord.ApplicationCommand) (fields []string) {
+	diff := func(field string, old any, new any) {
+		if o, n := fmt.Sprint(old), fmt.Sprint(new); o != n {
+			fields = append(fields, fmt.Sprintf("%s: %s -> %s", field, o, n))
+		}
+	}
+
+	diff("type", commandType(old.Type), commandType(new.Type))
+	diff("description", quote(old.Description), quote(new.Description))
+	diff("nameLocalizations", localizations(old.NameLocalizations), localizations(new.NameLocalizations))
+	diff("descriptionLocalizations", localizations(old.DescriptionLocalizations), localizations(new.DescriptionLocalizations))
+	diff("dmPermission", dmPermission(old.DMPermission), dmPermission(new.DMPermission))
+	diff("defaultMemberPermissions", memberPermissions(old.DefaultMemberPermissions), memberPermissions(new.DefaultMemberPermissions))
+	diff("nsfw", old.NSFW != nil && *old.NSFW, new.NSFW != nil && *new.NSFW)
+	fields = append(fields, diffOptions("options", old.Options, new.Options)...)
+	return
+}
+
+func diffOptions(path string, old []*discord.ApplicationCommandOption, new []*discord.ApplicationCommandOption) (fields []string) {
+	if len(old) != len(new) {
+		return []string{fmt.Sprintf("%s: %s -> %s", path, optionNames(old), optionNames(new))}
+	}
+
+	for i := range old {
+		o, n := old[i], new[i]
+		field := fmt.Sprintf("%s[%d]", path, i)
+		diff := func(name string, old any, new any) {
+			if o, n := fmt.Sprint(old), fmt.Sprint(new); o != n {
+				fields = append(fields, fmt.Sprintf("%s.%s: %s -> %s", field, name, o, n))
+			}
+		}
+
+		diff("type", o.Type, n.Type)
+		diff("name", quote(o.Name), quote(n.Name))
+		diff("description", quote(o.Description), quote(n.Description))
+		diff("nameLocalizations", localizations(&o.NameLocalizations), localizations(&n.NameLocalizations))
+		diff("descriptionLocalizations", localizations(&o.DescriptionLocalizations), localizations(&n.DescriptionLocalizations))
+		diff("required", o.Required, n.Required)
+		diff("autocomplete", o.Autocomplete, n.Autocomplete)
+		diff("channelTypes", o.ChannelTypes, n.ChannelTypes)
+		diff("minValue", optionalNumber(o.MinValue), optionalNumber(n.MinValue))
+		diff("maxValue", o.MaxValue, n.MaxValue)
+		diff("minLength", optionalNumber(o.MinLength), optionalNumber(n.MinLength))
+		diff("maxLength", o.MaxLength, n.MaxLength)
+		diff("choices", choices(o.Choices), choices(n.Choices))
+		fields = append(fields, diffOptions(field+".options", o.Options, n.Options)...)
+	}
+	return
+}
+
+func sortedNames(commands map[string]*discord.ApplicationCommand) []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func quote(s string) string {
+	return fmt.Sprintf("%q", s)
+}
+
+func commandType(t discord.ApplicationCommandType) discord.ApplicationCommandType {
+	if t == 0 {
+		return discord.ChatApplicationCommand
+	}
+	return t
+}
+
+// localizations formats localizations in a stable order, missing ones are the same as empty
+func localizations(l *map[discord.Locale]string) string {
+	if l == nil || len(*l) == 0 {
+		return "{}"
+	}
+	pairs := make([]string, 0, len(*l))
+	for locale, s := range *l {
+		pairs = append(pairs, fmt.Sprintf("%s:%q", locale, s))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, " ") + "}"
+}
+
+// dmPermission is true by default
+func dmPermission(p *bool) bool {
+	return p == nil || *p
+}
+
+// memberPermissions is unset, i.e. everyone can use the command, by default
+func memberPermissions(p *int64) string {
+	if p == nil {
+		return "everyone"
+	}
+	return fmt.Sprint(*p)
+}
+
+func optionalNumber[T int | float64](n *T) string {
+	if n == nil {
+		return "none"
+	}
+	return fmt.Sprint(*n)
+}
+
+func optionNames(options []*discord.ApplicationCommandOption) string {
+	names := make([]string, 0, len(options))
+	for _, o := range options {
+		names = append(names, o.Name)
+	}
+	return "[" + strings.Join(names, " ") + "]"
+}
+
+// choices formats choices with values as JSON would, so numbers of new definitions match the decoded ones
+func choices(choices []*discord.ApplicationCommandOptionChoice) string {
+	formatted := make([]string, 0, len(choices))
+	for _, c := range choices {
+		value := fmt.Sprint(c.Value)
+		if s, ok := c.Value.(string); ok {
+			value = quote(s)
+		}
+		formatted = append(formatted, fmt.Sprintf("%q=%s%s", c.Name, value, localizations(&c.NameLocalizations)))
+	}
+	return "[" + strings.Join(formatted, " ") + "]"
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"encoding/json"
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+func testCommands(r *Router) map[string]*discord.ApplicationCommand {
+	commands := make(map[string]*discord.ApplicationCommand)
+	for _, c := range r.List() {
+		commands[c.Name] = c.ApplicationCommand()
+	}
+	return commands
+}
+
+func TestDiffSubCommands(t *testing.T) {
+	var subCommands []*Command
+	for _, name := range []string{"gpt", "import", "export", "search", "settings"} {
+		subCommands = append(subCommands, &Command{
+			Name:        name,
+			Description: "Subcommand " + name,
+			Options: []*discord.ApplicationCommandOption{
+				{Type: discord.ApplicationCommandOptionString, Name: "prompt", Description: "Prompt"},
+			},
+		})
+	}
+	chat := &Command{Name: "chat", Description: "Chat", SubCommands: NewRouter(subCommands)}
+	r := NewRouter([]*Command{chat})
+
+	// Registered commands are what Discord returns, decoded from JSON
+	data, err := json.Marshal(testCommands(r)["chat"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registered discord.ApplicationCommand
+	if err := json.Unmarshal(data, &registered); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subcommands are kept in a map, every diff must list them in the same order
+	for i := 0; i < 20; i++ {
+		if changes := diffCommands("", []*discord.ApplicationCommand{&registered}, testCommands(r)); len(changes) != 0 {
+			t.Fatalf("diff #%d of unchanged command = %v, want none", i+1, changes)
+		}
+	}
+
+	chat.SubCommands.Replace(&Command{Name: "search", Description: "Search messages"})
+	changes := diffCommands("", []*discord.ApplicationCommand{&registered}, testCommands(r))
+	if len(changes) != 1 || changes[0].Action != CommandEdit {
+		t.Fatalf("diff of changed subcommand = %v, want an edit", changes)
+	}
+	want := []string{`options[3].description: "Subcommand search" -> "Search messages"`, "options[3].options: [prompt] -> []"}
+	if got := changes[0].Fields; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("changed fields = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands/admin"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+const AdminCommandName = "admin"
+
+type AdminCommandParams struct {
+	Settings               *settings.Store
+	OpenAICompletionModels []string
+	// Paths of commands that can be restricted with rules, e.g. "image dalle"
+	CommandPaths []string
+}
+
+// adminPermissionMiddleware double checks permissions of the member, as guilds can override
+// default member permissions of the command
+func adminPermissionMiddleware(ctx *bot.Context) {
+	if ctx.Interaction.Member != nil && ctx.Interaction.Member.Permissions&discord.PermissionManageServer != 0 {
+		ctx.Next()
+		return
+	}
+
+	ctx.Logger.Info("Admin command was invoked without Manage Server permission")
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags: discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: "Manage Server permission is required",
+					Color:       0xff0000,
+				},
+			},
+		},
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+	}
+}
+
+func AdminCommand(params *AdminCommandParams) *bot.Command {
+	return &bot.Command{
+		Name:                     AdminCommandName,
+		Description:              "Manage Rem AI in this server",
+		DMPermission:             false,
+		DefaultMemberPermissions: discord.PermissionManageServer,
+		Type:                     discord.ChatApplicationCommand,
+		Middlewares: []bot.Handler{
+			bot.HandlerFunc(adminPermissionMiddleware),
+		},
+		SubCommands: bot.NewRouter([]*bot.Command{
+			admin.ConfigCommand(params.Settings, params.OpenAICompletionModels),
+			admin.RulesCommand(params.Settings, params.CommandPaths),
+		}),
+	}
+}
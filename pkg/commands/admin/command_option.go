@@ -0,0 +1,33 @@
+package admin
+
+import "fmt"
+
+type adminCommandOptionType uint8
+
+const (
+	adminCommandOptionKey   adminCommandOptionType = 1
+	adminCommandOptionValue adminCommandOptionType = 2
+
+	adminCommandOptionCommand adminCommandOptionType = 3
+	adminCommandOptionChannel adminCommandOptionType = 4
+	adminCommandOptionRole    adminCommandOptionType = 5
+	adminCommandOptionUser    adminCommandOptionType = 6
+)
+
+func (t adminCommandOptionType) String() string {
+	switch t {
+	case adminCommandOptionKey:
+		return "key"
+	case adminCommandOptionValue:
+		return "value"
+	case adminCommandOptionCommand:
+		return "command"
+	case adminCommandOptionChannel:
+		return "channel"
+	case adminCommandOptionRole:
+		return "role"
+	case adminCommandOptionUser:
+		return "user"
+	}
+	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+const (
+	configCommandName      = "config"
+	configGetCommandName   = "get"
+	configSetCommandName   = "set"
+	configResetCommandName = "reset"
+)
+
+func ConfigCommand(settingsStore *settings.Store, completionModels []string) *bot.Command {
+	return &bot.Command{
+		Name:        configCommandName,
+		Description: "Manage bot settings of this server",
+		SubCommands: bot.NewRouter([]*bot.Command{
+			{
+				Name:        configGetCommandName,
+				Description: "Show current settings",
+				Options: []*discord.ApplicationCommandOption{
+					keyOption("Setting to show, all settings if not specified", false),
+				},
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					configGetHandler(ctx, settingsStore)
+				}),
+			},
+			{
+				Name:        configSetCommandName,
+				Description: "Change a setting",
+				Options: []*discord.ApplicationCommandOption{
+					keyOption("Setting to change", true),
+					{
+						Type:        discord.ApplicationCommandOptionString,
+						Name:        adminCommandOptionValue.String(),
+						Description: "New value, lists are comma-separated",
+						Required:    true,
+					},
+				},
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					configSetHandler(ctx, settingsStore, completionModels)
+				}),
+			},
+			{
+				Name:        configResetCommandName,
+				Description: "Reset a setting to the bot configuration",
+				Options: []*discord.ApplicationCommandOption{
+					keyOption("Setting to reset, all settings if not specified", false),
+				},
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					configResetHandler(ctx, settingsStore)
+				}),
+			},
+		}),
+	}
+}
+
+func keyOption(description string, required bool) *discord.ApplicationCommandOption {
+	choices := make([]*discord.ApplicationCommandOptionChoice, 0, len(settings.Keys))
+	for _, key := range settings.Keys {
+		choices = append(choices, &discord.ApplicationCommandOptionChoice{
+			Name:  key.Name,
+			Value: key.Name,
+		})
+	}
+	return &discord.ApplicationCommandOption{
+		Type:        discord.ApplicationCommandOptionString,
+		Name:        adminCommandOptionKey.String(),
+		Description: description,
+		Required:    required,
+		Choices:     choices,
+	}
+}
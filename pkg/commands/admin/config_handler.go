@@ -0,0 +1,145 @@
+package admin
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+const (
+	configEmbedColor     = 0x00bfff
+	configNotSetValue    = "*Not set, bot configuration applies*"
+	configSettingsAuthor = "Server settings"
+)
+
+func configGetHandler(ctx *bot.Context, settingsStore *settings.Store) {
+	guild := settingsStore.Get(ctx.Interaction.GuildID)
+
+	keys := settings.Keys
+	if option, ok := ctx.Options[adminCommandOptionKey.String()]; ok {
+		key := settings.FindKey(option.StringValue())
+		if key == nil {
+			configFailed(ctx, "Unknown setting "+option.StringValue())
+			return
+		}
+		keys = []*settings.Key{key}
+	}
+
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:  "⚙️ " + configSettingsAuthor,
+		Color:  configEmbedColor,
+		Fields: configFields(&guild, keys),
+	})
+}
+
+func configSetHandler(ctx *bot.Context, settingsStore *settings.Store, completionModels []string) {
+	key, value := configKeyOption(ctx), ""
+	if option, ok := ctx.Options[adminCommandOptionValue.String()]; ok {
+		value = option.StringValue()
+	}
+	if key == nil || value == "" {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse key and value options")
+		configFailed(ctx, "Failed to parse key and value options")
+		return
+	}
+
+	var guild settings.Guild
+	err := settingsStore.Update(ctx.Interaction.GuildID, func(g *settings.Guild) error {
+		if err := key.Set(g, value, completionModels); err != nil {
+			return err
+		}
+		guild = *g
+		return nil
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to set setting", "key", key.Name, "error", err)
+		configFailed(ctx, err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Setting was changed", "key", key.Name)
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:  "✅ Setting changed",
+		Color:  configEmbedColor,
+		Fields: configFields(&guild, []*settings.Key{key}),
+	})
+}
+
+func configResetHandler(ctx *bot.Context, settingsStore *settings.Store) {
+	key := configKeyOption(ctx)
+	if _, ok := ctx.Options[adminCommandOptionKey.String()]; ok && key == nil {
+		configFailed(ctx, "Unknown setting "+ctx.Options[adminCommandOptionKey.String()].StringValue())
+		return
+	}
+
+	err := settingsStore.Update(ctx.Interaction.GuildID, func(g *settings.Guild) error {
+		if key == nil {
+			*g = settings.Guild{}
+		} else {
+			key.Reset(g)
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to reset settings", "error", err)
+		configFailed(ctx, err.Error())
+		return
+	}
+
+	description := "All settings were reset to the bot configuration"
+	if key != nil {
+		description = "Setting `" + key.Name + "` was reset to the bot configuration"
+	}
+	ctx.Logger.Info("Settings were reset", "description", description)
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:       "✅ Settings reset",
+		Description: description,
+		Color:       configEmbedColor,
+	})
+}
+
+func configKeyOption(ctx *bot.Context) *settings.Key {
+	option, ok := ctx.Options[adminCommandOptionKey.String()]
+	if !ok {
+		return nil
+	}
+	return settings.FindKey(option.StringValue())
+}
+
+func configFields(guild *settings.Guild, keys []*settings.Key) []*discord.MessageEmbedField {
+	fields := make([]*discord.MessageEmbedField, 0, len(keys))
+	for _, key := range keys {
+		value := key.Get(guild)
+		if value == "" {
+			value = configNotSetValue
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  key.Name,
+			Value: value + "\n" + key.Description,
+		})
+	}
+	return fields
+}
+
+// configRespond responds with an ephemeral message, settings are only visible to the admin who asked for them
+func configRespond(ctx *bot.Context, embed *discord.MessageEmbed) {
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags:  discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+	}
+}
+
+func configFailed(ctx *bot.Context, description string) {
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("error.title"),
+		Description: description,
+		Color:       0xff0000,
+	})
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+const (
+	rulesCommandName      = "rules"
+	rulesListCommandName  = "list"
+	rulesAllowCommandName = "allow"
+	rulesDenyCommandName  = "deny"
+	rulesClearCommandName = "clear"
+
+	// Discord limit of option choices
+	commandChoicesMaxCount = 25
+)
+
+// RulesCommand manages channel and role restrictions of the commands at the given paths, e.g. "image dalle"
+func RulesCommand(settingsStore *settings.Store, commandPaths []string) *bot.Command {
+	return &bot.Command{
+		Name:        rulesCommandName,
+		Description: "Restrict commands to channels and roles of this server",
+		SubCommands: bot.NewRouter([]*bot.Command{
+			{
+				Name:        rulesListCommandName,
+				Description: "Show command restrictions",
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					rulesListHandler(ctx, settingsStore)
+				}),
+			},
+			{
+				Name:        rulesAllowCommandName,
+				Description: "Allow a command only in the channel or category, or only for the role",
+				Options: []*discord.ApplicationCommandOption{
+					commandOption(commandPaths, "Command to restrict, applies to its subcommands too", true),
+					channelOption("Channel or category where the command is allowed"),
+					roleOption("Role that is allowed to use the command"),
+				},
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					rulesChangeHandler(ctx, settingsStore, true)
+				}),
+			},
+			{
+				Name:        rulesDenyCommandName,
+				Description: "Deny a command in the channel or category, for the role or the user",
+				Options: []*discord.ApplicationCommandOption{
+					commandOption(commandPaths, "Command to restrict, applies to its subcommands too", true),
+					channelOption("Channel or category where the command is denied"),
+					roleOption("Role that is denied to use the command"),
+					{
+						Type:        discord.ApplicationCommandOptionUser,
+						Name:        adminCommandOptionUser.String(),
+						Description: "User that is denied to use the command",
+						Required:    false,
+					},
+				},
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					rulesChangeHandler(ctx, settingsStore, false)
+				}),
+			},
+			{
+				Name:        rulesClearCommandName,
+				Description: "Remove command restrictions",
+				Options: []*discord.ApplicationCommandOption{
+					commandOption(commandPaths, "Command to remove restrictions of, all commands if not specified", false),
+				},
+				Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+					rulesClearHandler(ctx, settingsStore)
+				}),
+			},
+		}),
+	}
+}
+
+func commandOption(commandPaths []string, description string, required bool) *discord.ApplicationCommandOption {
+	choices := make([]*discord.ApplicationCommandOptionChoice, 0, len(commandPaths))
+	for _, path := range commandPaths {
+		if len(choices) == commandChoicesMaxCount {
+			break
+		}
+		choices = append(choices, &discord.ApplicationCommandOptionChoice{
+			Name:  "/" + path,
+			Value: path,
+		})
+	}
+	return &discord.ApplicationCommandOption{
+		Type:        discord.ApplicationCommandOptionString,
+		Name:        adminCommandOptionCommand.String(),
+		Description: description,
+		Required:    required,
+		Choices:     choices,
+	}
+}
+
+func channelOption(description string) *discord.ApplicationCommandOption {
+	return &discord.ApplicationCommandOption{
+		Type:         discord.ApplicationCommandOptionChannel,
+		Name:         adminCommandOptionChannel.String(),
+		Description:  description,
+		Required:     false,
+		ChannelTypes: []discord.ChannelType{discord.ChannelTypeGuildText, discord.ChannelTypeGuildCategory, discord.ChannelTypeGuildForum},
+	}
+}
+
+func roleOption(description string) *discord.ApplicationCommandOption {
+	return &discord.ApplicationCommandOption{
+		Type:        discord.ApplicationCommandOptionRole,
+		Name:        adminCommandOptionRole.String(),
+		Description: description,
+		Required:    false,
+	}
+}
@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"slices"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/rules"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+func rulesListHandler(ctx *bot.Context, settingsStore *settings.Store) {
+	guild := settingsStore.Get(ctx.Interaction.GuildID)
+	if len(guild.Rules) == 0 {
+		configRespond(ctx, &discord.MessageEmbed{
+			Title:       "🔒 Command rules",
+			Description: "Commands are not restricted",
+			Color:       configEmbedColor,
+		})
+		return
+	}
+
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:  "🔒 Command rules",
+		Color:  configEmbedColor,
+		Fields: rulesFields(guild.Rules),
+	})
+}
+
+func rulesChangeHandler(ctx *bot.Context, settingsStore *settings.Store, allow bool) {
+	option, ok := ctx.Options[adminCommandOptionCommand.String()]
+	if !ok {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse command option")
+		configFailed(ctx, "Failed to parse command option")
+		return
+	}
+	command := option.StringValue()
+	channel, role, user := optionID(ctx, adminCommandOptionChannel), optionID(ctx, adminCommandOptionRole), optionID(ctx, adminCommandOptionUser)
+	if channel == "" && role == "" && user == "" {
+		configFailed(ctx, "Please specify a channel, a role or a user")
+		return
+	}
+
+	var rule rules.Rule
+	err := settingsStore.Update(ctx.Interaction.GuildID, func(g *settings.Guild) error {
+		r := g.Rule(command)
+		if allow {
+			r.AllowChannels = appendUnique(r.AllowChannels, channel)
+			r.AllowRoles = appendUnique(r.AllowRoles, role)
+		} else {
+			r.DenyChannels = appendUnique(r.DenyChannels, channel)
+			r.DenyRoles = appendUnique(r.DenyRoles, role)
+			r.DenyUsers = appendUnique(r.DenyUsers, user)
+		}
+		rule = r.Clone()
+		return nil
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to change rules", "error", err)
+		configFailed(ctx, err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Rules were changed", "rules", command)
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:  "✅ Command rules changed",
+		Color:  configEmbedColor,
+		Fields: rulesFields([]rules.Rule{rule}),
+	})
+}
+
+func rulesClearHandler(ctx *bot.Context, settingsStore *settings.Store) {
+	var command string
+	if option, ok := ctx.Options[adminCommandOptionCommand.String()]; ok {
+		command = option.StringValue()
+	}
+
+	err := settingsStore.Update(ctx.Interaction.GuildID, func(g *settings.Guild) error {
+		g.RemoveRules(command)
+		return nil
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to clear rules", "error", err)
+		configFailed(ctx, err.Error())
+		return
+	}
+
+	description := "Restrictions of all commands were removed"
+	if command != "" {
+		description = "Restrictions of `/" + command + "` were removed"
+	}
+	ctx.Logger.Info("Rules were cleared", "description", description)
+	configRespond(ctx, &discord.MessageEmbed{
+		Title:       "✅ Command rules cleared",
+		Description: description,
+		Color:       configEmbedColor,
+	})
+}
+
+// optionID returns ID of the mentionable option, e.g. channel, role or user
+func optionID(ctx *bot.Context, t adminCommandOptionType) string {
+	option, ok := ctx.Options[t.String()]
+	if !ok {
+		return ""
+	}
+	id, _ := option.Value.(string)
+	return id
+}
+
+func appendUnique(list []string, id string) []string {
+	if id == "" || slices.Contains(list, id) {
+		return list
+	}
+	return append(list, id)
+}
+
+func rulesFields(list []rules.Rule) []*discord.MessageEmbedField {
+	fields := make([]*discord.MessageEmbedField, 0, len(list))
+	for _, rule := range list {
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  "/" + rule.Command,
+			Value: rule.String(),
+		})
+	}
+	return fields
+}
@@ -4,6 +4,13 @@ import (
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
 	"github.com/raikerian/go-remai-bot-discord/pkg/commands/gpt"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/ratelimit"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -11,20 +18,35 @@ const chatCommandName = "chat"
 
 type ChatCommandParams struct {
 	OpenAIClient           *openai.Client
+	RequestQueue           *queue.Queue
+	Moderator              *moderation.Moderator
+	Settings               *settings.Store
+	RateLimiter            *ratelimit.Limiter
 	OpenAICompletionModels []string
 	GPTMessagesCache       *gpt.MessagesCache
 	IgnoredChannelsCache   *gpt.IgnoredChannelsCache
+	KnowledgeBases         *kb.Store
+	Memory                 *memory.Store
+}
+
+// RecoverChatThreads unlocks chat threads left unfinished by a previous run of the bot, see gpt.RecoverThreads
+func RecoverChatThreads(s *discord.Session, guilds []*discord.Guild) {
+	gpt.RecoverThreads(s, []string{chatCommandName}, guilds)
 }
 
 func ChatCommand(params *ChatCommandParams) *bot.Command {
 	return &bot.Command{
 		Name:                     chatCommandName,
-		Description:              "Start conversation with LLM",
+		Description:              i18n.T(i18n.Default, "chat.description"),
+		DescriptionLocalizations: i18n.Localizations("chat.description"),
 		DMPermission:             false,
 		DefaultMemberPermissions: discord.PermissionViewChannel,
 		Type:                     discord.ChatApplicationCommand,
+		Middlewares:              []bot.Handler{params.RateLimiter},
+		MessageMiddlewares:       []bot.MessageHandler{params.RateLimiter},
 		SubCommands: bot.NewRouter([]*bot.Command{
-			gpt.Command(params.OpenAIClient, params.OpenAICompletionModels, params.GPTMessagesCache, params.IgnoredChannelsCache),
+			gpt.Command(params.OpenAIClient, params.RequestQueue, params.Moderator, params.Settings, params.OpenAICompletionModels, params.GPTMessagesCache, params.IgnoredChannelsCache, params.KnowledgeBases, params.Memory),
+			gpt.ImportCommand(params.Moderator, params.Settings, params.OpenAICompletionModels, params.GPTMessagesCache),
 		}),
 	}
 }
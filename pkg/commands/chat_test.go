@@ -0,0 +1,270 @@
+package commands_test
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands/gpt"
+	"github.com/raikerian/go-remai-bot-discord/pkg/fake"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/sashabaranov/go-openai"
+)
+
+func newChatBot(t *testing.T, knowledgeBases *kb.Store, memories *memory.Store) (*testBot, *gpt.MessagesCache) {
+	b := newTestBot(t)
+	cache, err := gpt.NewMessagesCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.router.Register(commands.ChatCommand(&commands.ChatCommandParams{
+		OpenAIClient:           b.openai.Client(),
+		Moderator:              b.moderator,
+		Settings:               b.settings,
+		OpenAICompletionModels: []string{openai.GPT4o},
+		GPTMessagesCache:       cache,
+		IgnoredChannelsCache:   gpt.NewIgnoredChannelsCache(),
+		KnowledgeBases:         knowledgeBases,
+		Memory:                 memories,
+	}))
+	return b, cache
+}
+
+// botMessages lists contents of bot messages in the channel, except the first message of a thread
+func botMessages(d *fake.Discord, channelID string) (contents []string) {
+	for _, m := range d.Messages(channelID) {
+		if m.Author.ID == d.Bot.ID && m.Type != discord.MessageTypeThreadStarterMessage {
+			contents = append(contents, m.Content)
+		}
+	}
+	return
+}
+
+func chatRoles(messages []openai.ChatCompletionMessage) (roles []string) {
+	for _, m := range messages {
+		roles = append(roles, m.Role+": "+m.Content)
+	}
+	return
+}
+
+func TestChatConversation(t *testing.T) {
+	tests := []struct {
+		name string
+		// Conversation is not cached, e.g. after restart, and is rebuilt from the thread and its metadata
+		rebuild bool
+	}{
+		{name: "cached conversation"},
+		{name: "rebuilt conversation", rebuild: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, cache := newChatBot(t, nil, nil)
+			b.openai.Chat = func(request openai.ChatCompletionRequest) (string, error) {
+				return "Answer to " + request.Messages[len(request.Messages)-1].Content, nil
+			}
+
+			b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "Hello"), fake.Option("context", "Be brief"), fake.Option("temperature", 0.5)))
+
+			threads := b.discord.Threads(b.channel.ID)
+			if len(threads) != 1 {
+				t.Fatalf("got %d threads, want 1", len(threads))
+			}
+			thread := threads[0]
+			if got, want := botMessages(b.discord, thread.ID), []string{"Answer to Hello"}; !slices.Equal(got, want) {
+				t.Errorf("thread messages = %q, want %q", got, want)
+			}
+			if got, want := b.discord.Locks(thread.ID), []bool{true, false}; !slices.Equal(got, want) {
+				t.Errorf("thread locks = %v, want %v", got, want)
+			}
+			if name := b.discord.Channel(thread.ID).Name; name != "Title" {
+				t.Errorf("thread name = %q, want generated title", name)
+			}
+
+			if tt.rebuild {
+				cache.Purge()
+			}
+			message := b.discord.Send(thread.ID, b.user, "How are you?")
+
+			if got, want := botMessages(b.discord, thread.ID), []string{"Answer to Hello", "Answer to How are you?"}; !slices.Equal(got, want) {
+				t.Errorf("thread messages = %q, want %q", got, want)
+			}
+			requests := b.openai.ChatRequests()
+			if len(requests) != 2 {
+				t.Fatalf("got %d chat requests, want 2", len(requests))
+			}
+			want := []string{"system: Be brief", "user: Hello", "assistant: Answer to Hello", "user: How are you?"}
+			if got := chatRoles(requests[1].Messages); !slices.Equal(got, want) {
+				t.Errorf("follow up request messages = %q, want %q", got, want)
+			}
+			if requests[1].Model != openai.GPT4o || requests[1].Temperature != 0.5 {
+				t.Errorf("follow up request model %s, temperature %g, want %s, 0.5", requests[1].Model, requests[1].Temperature, openai.GPT4o)
+			}
+			for _, m := range b.discord.Messages(thread.ID) {
+				if m.ID == message.ID && len(m.Reactions) != 0 {
+					t.Errorf("message has reactions %v after the answer", m.Reactions[0].Emoji.Name)
+				}
+			}
+			if locked := b.discord.Channel(thread.ID).ThreadMetadata.Locked; locked {
+				t.Errorf("thread is locked after the answer")
+			}
+		})
+	}
+}
+
+func TestChatFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		chat       func(openai.ChatCompletionRequest) (string, error)
+		moderation func(string) []string
+		// Error title in the thread, or in the interaction response if there is no thread
+		wantError   string
+		wantThreads int
+	}{
+		{
+			name: "OpenAI error",
+			chat: func(openai.ChatCompletionRequest) (string, error) {
+				return "", &fake.APIError{Status: http.StatusInternalServerError, Message: "overloaded"}
+			},
+			wantError:   "❌ OpenAI API failed",
+			wantThreads: 1,
+		},
+		{
+			name: "flagged prompt",
+			moderation: func(input string) []string {
+				if strings.Contains(input, "Hello") {
+					return []string{"violence"}
+				}
+				return nil
+			},
+			wantError: "❌ Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newChatBot(t, nil, nil)
+			if tt.chat != nil {
+				b.openai.Chat = tt.chat
+			}
+			if tt.moderation != nil {
+				b.openai.Moderation = tt.moderation
+			}
+
+			i := b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "Hello")))
+
+			threads := b.discord.Threads(b.channel.ID)
+			if len(threads) != tt.wantThreads {
+				t.Fatalf("got %d threads, want %d", len(threads), tt.wantThreads)
+			}
+			var errorMessage *discord.Message
+			if len(threads) > 0 {
+				messages := b.discord.Messages(threads[0].ID)
+				errorMessage = messages[len(messages)-1]
+			} else {
+				errorMessage = b.discord.Response(i)
+			}
+			if errorMessage == nil || len(errorMessage.Embeds) == 0 || errorMessage.Embeds[0].Title != tt.wantError {
+				t.Errorf("got message %+v, want error %q", errorMessage, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestChatModeration(t *testing.T) {
+	tests := []struct {
+		name    string
+		rebuild bool
+	}{
+		{name: "cached conversation"},
+		{name: "rebuilt conversation", rebuild: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, cache := newChatBot(t, nil, nil)
+			if err := b.moderator.Update(moderation.Config{Default: moderation.Policy{CheckOutputs: true}}, b.openai.Client()); err != nil {
+				t.Fatal(err)
+			}
+			b.openai.Moderation = func(input string) []string {
+				if strings.Contains(input, "forbidden") {
+					return []string{"violence"}
+				}
+				return nil
+			}
+			b.openai.Chat = func(request openai.ChatCompletionRequest) (string, error) {
+				last := request.Messages[len(request.Messages)-1].Content
+				if strings.Contains(last, "secret") {
+					return "Something forbidden", nil
+				}
+				return "Answer to " + last, nil
+			}
+
+			b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "Hello")))
+			thread := b.discord.Threads(b.channel.ID)[0]
+			b.discord.Send(thread.ID, b.user, "Tell me a secret")
+			blocked := b.discord.Send(thread.ID, b.user, "Do something forbidden")
+
+			if got := botMessages(b.discord, thread.ID); len(got) != 3 || !strings.HasPrefix(got[1], "🚫 The response was withheld by moderation") || got[2] != "" {
+				t.Errorf("thread messages = %q, want the answer, the withheld notice and the error embed", got)
+			}
+			if messages := b.discord.Messages(thread.ID); !slices.ContainsFunc(messages, func(m *discord.Message) bool {
+				return m.ID == blocked.ID && len(m.Reactions) == 1 && m.Reactions[0].Emoji.Name == "🚫"
+			}) {
+				t.Errorf("blocked message is not marked")
+			}
+
+			if tt.rebuild {
+				cache.Purge()
+			}
+			b.discord.Send(thread.ID, b.user, "How are you?")
+
+			requests := b.openai.ChatRequests()
+			want := []string{"user: Hello", "assistant: Answer to Hello", "user: Tell me a secret", "user: How are you?"}
+			if got := chatRoles(requests[len(requests)-1].Messages); !slices.Equal(got, want) {
+				t.Errorf("request messages = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestChatModerationWarning(t *testing.T) {
+	b, cache := newChatBot(t, nil, nil)
+	b.guild = b.discord.AddGuild(discord.German)
+	b.channel = b.discord.AddChannel(b.guild.ID, "general")
+	if err := b.moderator.Update(moderation.Config{Default: moderation.Policy{CheckOutputs: true, Action: moderation.ActionWarn}}, b.openai.Client()); err != nil {
+		t.Fatal(err)
+	}
+	b.openai.Moderation = func(input string) []string {
+		if strings.Contains(input, "forbidden") {
+			return []string{"violence"}
+		}
+		return nil
+	}
+	b.openai.Chat = func(request openai.ChatCompletionRequest) (string, error) {
+		last := request.Messages[len(request.Messages)-1].Content
+		if strings.Contains(last, "secret") {
+			return "Something forbidden", nil
+		}
+		return "Answer to " + last, nil
+	}
+
+	b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "Hello")))
+	thread := b.discord.Threads(b.channel.ID)[0]
+	b.discord.Send(thread.ID, b.user, "Tell me a secret")
+	want := "⚠️ Der Inhalt verstößt gegen die Nutzungsrichtlinien und wird vom Sicherheitssystem nicht zugelassen (violence)\n\nSomething forbidden"
+	if got := botMessages(b.discord, thread.ID); len(got) != 2 || got[1] != want {
+		t.Errorf("thread messages = %q, want the answer and the warned answer in the guild language", got)
+	}
+
+	// The warning is not part of the conversation once it is rebuilt from the thread
+	cache.Purge()
+	b.discord.Send(thread.ID, b.user, "How are you?")
+	requests := b.openai.ChatRequests()
+	wantMessages := []string{"user: Hello", "assistant: Answer to Hello", "user: Tell me a secret", "assistant: Something forbidden", "user: How are you?"}
+	if got := chatRoles(requests[len(requests)-1].Messages); !slices.Equal(got, wantMessages) {
+		t.Errorf("request messages = %q, want %q", got, wantMessages)
+	}
+}
@@ -0,0 +1,54 @@
+package commands_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/fake"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+// testBot wires commands to fake Discord and OpenAI
+type testBot struct {
+	discord  *fake.Discord
+	openai   *fake.OpenAI
+	router   *bot.Router
+	settings *settings.Store
+
+	moderator *moderation.Moderator
+	guild     *discord.Guild
+	channel   *discord.Channel
+	user      *discord.User
+}
+
+func newTestBot(t *testing.T) *testBot {
+	b := &testBot{
+		discord: fake.NewDiscord(t),
+		openai:  fake.NewOpenAI(t),
+		router:  bot.NewRouter(nil),
+	}
+	var err error
+	b.settings, err = settings.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.moderator, err = moderation.New(moderation.Config{}, b.openai.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.guild = b.discord.AddGuild(discord.EnglishUS)
+	b.channel = b.discord.AddChannel(b.guild.ID, "general")
+	b.user = b.discord.AddUser("user")
+	b.discord.AddHandler(b.router.HandleInteraction)
+	b.discord.AddHandler(b.router.HandleMessage)
+	return b
+}
+
+// command runs the slash command in the channel of the test guild
+func (b *testBot) command(name string, options ...*discord.ApplicationCommandInteractionDataOption) *discord.Interaction {
+	return b.discord.Command(b.guild.ID, b.channel.ID, b.user, fake.CommandData(name, options...))
+}
@@ -2,33 +2,41 @@ package dalle
 
 import (
 	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
 	"github.com/sashabaranov/go-openai"
 )
 
 const commandName = "dalle"
 
-func Command(client *openai.Client) *bot.Command {
-	// numberOptionMinValue := 1.0
+func Command(client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, archiver archive.Archiver) *bot.Command {
+	numberOptionMinValue := 1.0
 	return &bot.Command{
-		Name:        commandName,
-		Description: "Generate creative images from textual descriptions using OpenAI Dalle 2",
+		Name:                     commandName,
+		Description:              i18n.T(i18n.Default, "dalle.description"),
+		DescriptionLocalizations: i18n.Localizations("dalle.description"),
 		Options: []*discord.ApplicationCommandOption{
 			{
-				Type:        discord.ApplicationCommandOptionString,
-				Name:        imageCommandOptionPrompt.String(),
-				Description: "A text description of the desired image",
-				Required:    true,
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     imageCommandOptionPrompt.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.prompt"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.prompt"),
+				Required:                 true,
 			},
 			{
-				Type:        discord.ApplicationCommandOptionString,
-				Name:        imageCommandOptionModel.String(),
-				Description: "Dall-e model",
-				Required:    false,
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     imageCommandOptionModel.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.model"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.model"),
+				Required:                 false,
 				Choices: []*discord.ApplicationCommandOptionChoice{
 					{
-						Name:  openai.CreateImageModelDallE3 + " (Default)",
-						Value: openai.CreateImageModelDallE3,
+						Name:              i18n.T(i18n.Default, "choice.default", openai.CreateImageModelDallE3),
+						NameLocalizations: i18n.Localizations("choice.default", openai.CreateImageModelDallE3),
+						Value:             openai.CreateImageModelDallE3,
 					},
 					{
 						Name:  openai.CreateImageModelDallE2,
@@ -37,10 +45,11 @@ func Command(client *openai.Client) *bot.Command {
 				},
 			},
 			{
-				Type:        discord.ApplicationCommandOptionString,
-				Name:        imageCommandOptionSize.String(),
-				Description: "The size of the generated images",
-				Required:    false,
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     imageCommandOptionSize.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.size"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.size"),
+				Required:                 false,
 				Choices: []*discord.ApplicationCommandOptionChoice{
 					// Dall-e 2-only sizes
 					{
@@ -53,8 +62,9 @@ func Command(client *openai.Client) *bot.Command {
 					},
 					// Supported by both
 					{
-						Name:  openai.CreateImageSize1024x1024 + " (Default)",
-						Value: openai.CreateImageSize1024x1024,
+						Name:              i18n.T(i18n.Default, "choice.default", openai.CreateImageSize1024x1024),
+						NameLocalizations: i18n.Localizations("choice.default", openai.CreateImageSize1024x1024),
+						Value:             openai.CreateImageSize1024x1024,
 					},
 					// Dall-e 3-only sizes
 					{
@@ -68,14 +78,16 @@ func Command(client *openai.Client) *bot.Command {
 				},
 			},
 			{
-				Type:        discord.ApplicationCommandOptionString,
-				Name:        imageCommandOptionStyle.String(),
-				Description: "The style of the generated images (v3 only)",
-				Required:    false,
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     imageCommandOptionStyle.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.style"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.style"),
+				Required:                 false,
 				Choices: []*discord.ApplicationCommandOptionChoice{
 					{
-						Name:  openai.CreateImageStyleVivid + " (Default)",
-						Value: openai.CreateImageStyleVivid,
+						Name:              i18n.T(i18n.Default, "choice.default", openai.CreateImageStyleVivid),
+						NameLocalizations: i18n.Localizations("choice.default", openai.CreateImageStyleVivid),
+						Value:             openai.CreateImageStyleVivid,
 					},
 					{
 						Name:  openai.CreateImageStyleNatural,
@@ -84,14 +96,16 @@ func Command(client *openai.Client) *bot.Command {
 				},
 			},
 			{
-				Type:        discord.ApplicationCommandOptionString,
-				Name:        imageCommandOptionQuality.String(),
-				Description: "The quality of the generated images (v3 only)",
-				Required:    false,
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     imageCommandOptionQuality.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.quality"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.quality"),
+				Required:                 false,
 				Choices: []*discord.ApplicationCommandOptionChoice{
 					{
-						Name:  openai.CreateImageQualityStandard + " (Default)",
-						Value: openai.CreateImageQualityStandard,
+						Name:              i18n.T(i18n.Default, "choice.default", openai.CreateImageQualityStandard),
+						NameLocalizations: i18n.Localizations("choice.default", openai.CreateImageQualityStandard),
+						Value:             openai.CreateImageQualityStandard,
 					},
 					{
 						Name:  openai.CreateImageQualityHD,
@@ -99,23 +113,38 @@ func Command(client *openai.Client) *bot.Command {
 					},
 				},
 			},
-			// Temp hiding this as Dall-e 3 currently doesn't support more than 1 image in request
-			// {
-			// 	Type:        discord.ApplicationCommandOptionInteger,
-			// 	Name:        imageCommandOptionNumber.String(),
-			// 	Description: "The number of images to generate (default 1, max 4)",
-			// 	MinValue:    &numberOptionMinValue,
-			// 	MaxValue:    4,
-			// 	Required:    false,
-			// },
+			{
+				Type:                     discord.ApplicationCommandOptionBoolean,
+				Name:                     imageCommandOptionExact.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.exact"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.exact"),
+				Required:                 false,
+			},
+			{
+				Type:                     discord.ApplicationCommandOptionBoolean,
+				Name:                     imageCommandOptionEnhance.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.enhance"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.enhance"),
+				Required:                 false,
+			},
+			{
+				Type:                     discord.ApplicationCommandOptionInteger,
+				Name:                     imageCommandOptionNumber.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.number"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.number"),
+				MinValue:                 &numberOptionMinValue,
+				MaxValue:                 4,
+				Required:                 false,
+			},
 		},
 		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
-			imageHandler(ctx, client)
+			imageHandler(ctx, client, requestQueue, moderator, archiver)
 		}),
+		ComponentHandler: bot.HandlerFunc(imageComponentHandler),
 		Middlewares: []bot.Handler{
 			bot.HandlerFunc(imageInteractionResponseMiddleware),
 			bot.HandlerFunc(func(ctx *bot.Context) {
-				imageModerationMiddleware(ctx, client)
+				imageModerationMiddleware(ctx, moderator)
 			}),
 		},
 	}
@@ -11,6 +11,12 @@ const (
 	imageCommandOptionModel   imageCommandOptionType = 4
 	imageCommandOptionQuality imageCommandOptionType = 5
 	imageCommandOptionStyle   imageCommandOptionType = 6
+	imageCommandOptionImage   imageCommandOptionType = 7
+	imageCommandOptionMask    imageCommandOptionType = 8
+	imageCommandOptionExact   imageCommandOptionType = 9
+	imageCommandOptionEnhance imageCommandOptionType = 10
+	// Not registered in Discord, only set by component handlers
+	imageCommandOptionEnhancedPrompt imageCommandOptionType = 11
 )
 
 func (t imageCommandOptionType) String() string {
@@ -27,6 +33,26 @@ func (t imageCommandOptionType) String() string {
 		return "quality"
 	case imageCommandOptionStyle:
 		return "style"
+	case imageCommandOptionImage:
+		return "image"
+	case imageCommandOptionMask:
+		return "mask"
+	case imageCommandOptionExact:
+		return "exact"
+	case imageCommandOptionEnhance:
+		return "enhance"
+	case imageCommandOptionEnhancedPrompt:
+		return "enhanced-prompt"
+	}
+	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
+}
+
+func (t imageCommandOptionType) humanReadableString() string {
+	switch t {
+	case imageCommandOptionPrompt:
+		return "Prompt"
+	case imageCommandOptionEnhancedPrompt:
+		return "Enhanced prompt"
 	}
 	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
 }
@@ -0,0 +1,230 @@
+package dalle
+
+import (
+	"fmt"
+	"strconv"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	imageComponentActionReroll = "reroll"
+	imageComponentActionHD     = "hd"
+	imageComponentActionWide   = "wide"
+	imageComponentActionTall   = "tall"
+)
+
+func stringOption(optionType imageCommandOptionType, value string) *discord.ApplicationCommandInteractionDataOption {
+	return &discord.ApplicationCommandInteractionDataOption{
+		Name:  optionType.String(),
+		Type:  discord.ApplicationCommandOptionString,
+		Value: value,
+	}
+}
+
+func imageComponentFailed(ctx *bot.Context, description string) {
+	ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags: discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: description,
+					Color:       0xff0000,
+				},
+			},
+		},
+	})
+}
+
+// promptFromMessage recovers prompt from the embed built by imageResponseFollowup
+func promptFromMessage(message *discord.Message) string {
+	if message == nil || len(message.Embeds) == 0 {
+		return ""
+	}
+	embed := message.Embeds[0]
+	if embed.Description != "" {
+		return embed.Description
+	}
+	if embed.Author != nil {
+		return embed.Author.Name
+	}
+	return ""
+}
+
+// enhancedPromptFromMessage recovers prompt generated by the enhance option, so rerolls don't generate a new one
+func enhancedPromptFromMessage(message *discord.Message) string {
+	if message == nil || len(message.Embeds) == 0 {
+		return ""
+	}
+	for _, field := range message.Embeds[0].Fields {
+		if field.Name == imageCommandOptionEnhancedPrompt.humanReadableString() {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// imageActionsRow makes buttons that repeat generation with the same prompt and slightly different options.
+// Options are encoded in the custom ID as `<action>:<model>:<size>:<quality>:<style>:<number>:<exact>`
+func imageActionsRow(locale discord.Locale, path []string, model string, size string, quality string, style string, number int, exact bool) discord.ActionsRow {
+	customID := func(action string) string {
+		return bot.ComponentCustomID(path, action, model, size, quality, style, strconv.Itoa(number), strconv.FormatBool(exact))
+	}
+
+	row := discord.ActionsRow{
+		Components: []discord.MessageComponent{
+			&discord.Button{
+				Label:    i18n.T(locale, "dalle.reroll"),
+				Style:    discord.SecondaryButton,
+				Emoji:    &discord.ComponentEmoji{Name: "🔁"},
+				CustomID: customID(imageComponentActionReroll),
+			},
+		},
+	}
+	if model != openai.CreateImageModelDallE3 {
+		return row
+	}
+
+	if quality != openai.CreateImageQualityHD {
+		row.Components = append(row.Components, &discord.Button{
+			Label:    "HD",
+			Style:    discord.SecondaryButton,
+			CustomID: customID(imageComponentActionHD),
+		})
+	}
+	if size != openai.CreateImageSize1792x1024 {
+		row.Components = append(row.Components, &discord.Button{
+			Label:    i18n.T(locale, "dalle.wide"),
+			Style:    discord.SecondaryButton,
+			Emoji:    &discord.ComponentEmoji{Name: "⬌"},
+			CustomID: customID(imageComponentActionWide),
+		})
+	}
+	if size != openai.CreateImageSize1024x1792 {
+		row.Components = append(row.Components, &discord.Button{
+			Label:    i18n.T(locale, "dalle.tall"),
+			Style:    discord.SecondaryButton,
+			Emoji:    &discord.ComponentEmoji{Name: "⬍"},
+			CustomID: customID(imageComponentActionTall),
+		})
+	}
+	return row
+}
+
+// imageVariationsRow makes buttons that request Dall-e 2 variations of each image of the message.
+// Custom ID is `<index of the image>`
+func imageVariationsRow(locale discord.Locale, path []string, images []generatedImage) discord.ActionsRow {
+	row := discord.ActionsRow{}
+	for i := range images {
+		row.Components = append(row.Components, &discord.Button{
+			Label:    i18n.T(locale, "dalle.variations", i+1),
+			Style:    discord.SecondaryButton,
+			Emoji:    &discord.ComponentEmoji{Name: "🎨"},
+			CustomID: bot.ComponentCustomID(path, strconv.Itoa(i+1)),
+		})
+	}
+	return row
+}
+
+// imageComponentHandler turns action buttons of a generated image into options of the dalle command
+func imageComponentHandler(ctx *bot.Context) {
+	// Buttons made before `exact` option was introduced have 6 arguments
+	if len(ctx.ComponentArgs) != 6 && len(ctx.ComponentArgs) != 7 {
+		ctx.Logger.Error("Unexpected image component arguments", "args", ctx.ComponentArgs)
+		imageComponentFailed(ctx, ctx.T("component.unsupported"))
+		return
+	}
+	action, model, size, quality, style := ctx.ComponentArgs[0], ctx.ComponentArgs[1], ctx.ComponentArgs[2], ctx.ComponentArgs[3], ctx.ComponentArgs[4]
+	number, err := strconv.Atoi(ctx.ComponentArgs[5])
+	if err != nil {
+		number = 1
+	}
+
+	exact := false
+	if len(ctx.ComponentArgs) > 6 {
+		exact, _ = strconv.ParseBool(ctx.ComponentArgs[6])
+	}
+
+	prompt := promptFromMessage(ctx.Interaction.Message)
+	if prompt == "" {
+		imageComponentFailed(ctx, ctx.T("dalle.promptNotFound"))
+		return
+	}
+
+	switch action {
+	case imageComponentActionHD:
+		quality = openai.CreateImageQualityHD
+	case imageComponentActionWide:
+		size = openai.CreateImageSize1792x1024
+	case imageComponentActionTall:
+		size = openai.CreateImageSize1024x1792
+	}
+
+	ctx.Logger.Info("Image component invoked", "action", action)
+
+	ctx.Options = bot.OptionsMap{
+		imageCommandOptionPrompt.String(): stringOption(imageCommandOptionPrompt, prompt),
+		imageCommandOptionModel.String():  stringOption(imageCommandOptionModel, model),
+		imageCommandOptionSize.String():   stringOption(imageCommandOptionSize, size),
+		imageCommandOptionNumber.String(): {
+			Name:  imageCommandOptionNumber.String(),
+			Type:  discord.ApplicationCommandOptionInteger,
+			Value: float64(number),
+		},
+	}
+	if quality != "" {
+		ctx.Options[imageCommandOptionQuality.String()] = stringOption(imageCommandOptionQuality, quality)
+	}
+	if style != "" {
+		ctx.Options[imageCommandOptionStyle.String()] = stringOption(imageCommandOptionStyle, style)
+	}
+	if exact {
+		ctx.Options[imageCommandOptionExact.String()] = &discord.ApplicationCommandInteractionDataOption{
+			Name:  imageCommandOptionExact.String(),
+			Type:  discord.ApplicationCommandOptionBoolean,
+			Value: true,
+		}
+	}
+	if enhanced := enhancedPromptFromMessage(ctx.Interaction.Message); enhanced != "" {
+		ctx.Options[imageCommandOptionEnhancedPrompt.String()] = stringOption(imageCommandOptionEnhancedPrompt, enhanced)
+	}
+
+	ctx.Next()
+}
+
+// imageVariationComponentHandler turns variation buttons into options of the variation command
+func imageVariationComponentHandler(ctx *bot.Context) {
+	var attachment *discord.MessageAttachment
+	if len(ctx.ComponentArgs) == 1 && ctx.Interaction.Message != nil {
+		name := fmt.Sprintf("image_%s.png", ctx.ComponentArgs[0])
+		for _, a := range ctx.Interaction.Message.Attachments {
+			if a.Filename == name {
+				attachment = a
+				break
+			}
+		}
+	}
+	if attachment == nil {
+		ctx.Logger.Error("Failed to find image for variation component arguments", "args", ctx.ComponentArgs)
+		imageComponentFailed(ctx, ctx.T("dalle.imageNotFound"))
+		return
+	}
+
+	ctx.Logger.Info("Image variation component invoked")
+
+	// Attachment is looked up in the message by imageAttachmentOption
+	ctx.Options = bot.OptionsMap{
+		imageCommandOptionImage.String(): {
+			Name:  imageCommandOptionImage.String(),
+			Type:  discord.ApplicationCommandOptionAttachment,
+			Value: attachment.ID,
+		},
+	}
+
+	ctx.Next()
+}
@@ -0,0 +1,126 @@
+package dalle
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	editCommandName      = "edit"
+	variationCommandName = "variation"
+)
+
+// Edits and variations are only supported by Dall-e 2
+func dalle2SizeOption() *discord.ApplicationCommandOption {
+	return &discord.ApplicationCommandOption{
+		Type:                     discord.ApplicationCommandOptionString,
+		Name:                     imageCommandOptionSize.String(),
+		Description:              i18n.T(i18n.Default, "dalle.option.size"),
+		DescriptionLocalizations: i18n.Localizations("dalle.option.size"),
+		Required:                 false,
+		Choices: []*discord.ApplicationCommandOptionChoice{
+			{
+				Name:  openai.CreateImageSize256x256,
+				Value: openai.CreateImageSize256x256,
+			},
+			{
+				Name:  openai.CreateImageSize512x512,
+				Value: openai.CreateImageSize512x512,
+			},
+			{
+				Name:              i18n.T(i18n.Default, "choice.default", openai.CreateImageSize1024x1024),
+				NameLocalizations: i18n.Localizations("choice.default", openai.CreateImageSize1024x1024),
+				Value:             openai.CreateImageSize1024x1024,
+			},
+		},
+	}
+}
+
+func dalle2NumberOption() *discord.ApplicationCommandOption {
+	numberOptionMinValue := 1.0
+	return &discord.ApplicationCommandOption{
+		Type:                     discord.ApplicationCommandOptionInteger,
+		Name:                     imageCommandOptionNumber.String(),
+		Description:              i18n.T(i18n.Default, "dalle.option.number"),
+		DescriptionLocalizations: i18n.Localizations("dalle.option.number"),
+		MinValue:                 &numberOptionMinValue,
+		MaxValue:                 4,
+		Required:                 false,
+	}
+}
+
+func EditCommand(client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, archiver archive.Archiver) *bot.Command {
+	return &bot.Command{
+		Name:                     editCommandName,
+		Description:              i18n.T(i18n.Default, "dalle.edit.description"),
+		DescriptionLocalizations: i18n.Localizations("dalle.edit.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionAttachment,
+				Name:                     imageCommandOptionImage.String(),
+				Description:              i18n.T(i18n.Default, "dalle.edit.option.image"),
+				DescriptionLocalizations: i18n.Localizations("dalle.edit.option.image"),
+				Required:                 true,
+			},
+			{
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     imageCommandOptionPrompt.String(),
+				Description:              i18n.T(i18n.Default, "dalle.option.prompt"),
+				DescriptionLocalizations: i18n.Localizations("dalle.option.prompt"),
+				Required:                 true,
+			},
+			{
+				Type:                     discord.ApplicationCommandOptionAttachment,
+				Name:                     imageCommandOptionMask.String(),
+				Description:              i18n.T(i18n.Default, "dalle.edit.option.mask"),
+				DescriptionLocalizations: i18n.Localizations("dalle.edit.option.mask"),
+				Required:                 false,
+			},
+			dalle2SizeOption(),
+			dalle2NumberOption(),
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			imageEditHandler(ctx, client, requestQueue, archiver)
+		}),
+		Middlewares: []bot.Handler{
+			bot.HandlerFunc(imageInteractionResponseMiddleware),
+			bot.HandlerFunc(func(ctx *bot.Context) {
+				imageModerationMiddleware(ctx, moderator)
+			}),
+		},
+	}
+}
+
+func VariationCommand(client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, archiver archive.Archiver) *bot.Command {
+	return &bot.Command{
+		Name:                     variationCommandName,
+		Description:              i18n.T(i18n.Default, "dalle.variation.description"),
+		DescriptionLocalizations: i18n.Localizations("dalle.variation.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionAttachment,
+				Name:                     imageCommandOptionImage.String(),
+				Description:              i18n.T(i18n.Default, "dalle.variation.option.image"),
+				DescriptionLocalizations: i18n.Localizations("dalle.variation.option.image"),
+				Required:                 true,
+			},
+			dalle2SizeOption(),
+			dalle2NumberOption(),
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			imageVariationHandler(ctx, client, requestQueue, archiver)
+		}),
+		ComponentHandler: bot.HandlerFunc(imageVariationComponentHandler),
+		Middlewares: []bot.Handler{
+			bot.HandlerFunc(imageInteractionResponseMiddleware),
+			bot.HandlerFunc(func(ctx *bot.Context) {
+				imageModerationMiddleware(ctx, moderator)
+			}),
+		},
+	}
+}
@@ -0,0 +1,214 @@
+package dalle
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func imageFailed(ctx *bot.Context, title string, description string) {
+	ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+		Embeds: []*discord.MessageEmbed{
+			{
+				Title:       title,
+				Description: description,
+				Color:       0xff0000,
+			},
+		},
+	}, discord.WithContext(ctx.Context()))
+}
+
+func imageAttachmentOption(ctx *bot.Context, optionType imageCommandOptionType) *discord.MessageAttachment {
+	option, ok := ctx.Options[optionType.String()]
+	if !ok {
+		return nil
+	}
+	if ctx.Interaction.Type == discord.InteractionMessageComponent {
+		// option was recovered by a component handler from the message the component belongs to
+		for _, attachment := range ctx.Interaction.Message.Attachments {
+			if attachment.ID == option.Value.(string) {
+				return attachment
+			}
+		}
+		return nil
+	}
+	return ctx.Interaction.ApplicationCommandData().Resolved.Attachments[option.Value.(string)]
+}
+
+// dalle2SizeAndNumber parses options shared by edit and variation commands
+func dalle2SizeAndNumber(ctx *bot.Context) (size string, number int) {
+	size = imageDefaultSize
+	if option, ok := ctx.Options[imageCommandOptionSize.String()]; ok {
+		size = option.StringValue()
+	}
+	number = 1
+	if option, ok := ctx.Options[imageCommandOptionNumber.String()]; ok {
+		number = int(option.IntValue())
+	}
+	return
+}
+
+// preparedImageAttachment downloads attachment and converts it to an image accepted by OpenAI
+func preparedImageAttachment(ctx *bot.Context, attachment *discord.MessageAttachment) ([]byte, bool) {
+	img, format, err := downloadImage(ctx.Client, attachment.URL)
+	if err != nil {
+		ctx.Logger.Error("Failed to download image attachment", "error", err)
+		imageFailed(ctx, ctx.T("error.attachment"), fmt.Sprintf("`%s`: %v", attachment.Filename, err))
+		return nil, false
+	}
+
+	data, err := prepareImage(img)
+	if err != nil {
+		ctx.Logger.Error("Failed to prepare image attachment", "format", format, "error", err)
+		imageFailed(ctx, ctx.T("dalle.invalidImage"), fmt.Sprintf("`%s`: %v", attachment.Filename, err))
+		return nil, false
+	}
+	return data, true
+}
+
+func imageEditHandler(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, archiver archive.Archiver) {
+	var prompt string
+	if option, ok := ctx.Options[imageCommandOptionPrompt.String()]; ok {
+		prompt = option.StringValue()
+	}
+	attachment := imageAttachmentOption(ctx, imageCommandOptionImage)
+	if prompt == "" || attachment == nil {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse image edit options")
+		imageFailed(ctx, ctx.T("error.title"), ctx.T("dalle.imagePromptOptions"))
+		return
+	}
+	size, number := dalle2SizeAndNumber(ctx)
+
+	imageData, ok := preparedImageAttachment(ctx, attachment)
+	if !ok {
+		return
+	}
+	imageFile, err := writeTempImage(imageData)
+	if err != nil {
+		ctx.Logger.Error("Failed to store image", "error", err)
+		imageFailed(ctx, ctx.T("error.title"), ctx.T("dalle.processImage"))
+		return
+	}
+	defer removeTempImage(imageFile)
+
+	var maskFile *os.File
+	if maskAttachment := imageAttachmentOption(ctx, imageCommandOptionMask); maskAttachment != nil {
+		mask, _, err := downloadImage(ctx.Client, maskAttachment.URL)
+		if err != nil {
+			ctx.Logger.Error("Failed to download mask attachment", "error", err)
+			imageFailed(ctx, ctx.T("error.attachment"), fmt.Sprintf("`%s`: %v", maskAttachment.Filename, err))
+			return
+		}
+		maskData, err := prepareMask(mask, imageData)
+		if err != nil {
+			imageFailed(ctx, ctx.T("dalle.invalidMask"), fmt.Sprintf("`%s`: %v", maskAttachment.Filename, err))
+			return
+		}
+		maskFile, err = writeTempImage(maskData)
+		if err != nil {
+			ctx.Logger.Error("Failed to store mask", "error", err)
+			imageFailed(ctx, ctx.T("error.title"), ctx.T("dalle.processMask"))
+			return
+		}
+		defer removeTempImage(maskFile)
+	}
+
+	ctx.Logger.Info("Dalle edit request invoked", "size", size, "number", number, "mask", maskFile != nil)
+	position := newQueuePosition(ctx)
+	defer position.clear()
+	var resp openai.ImageResponse
+	err = requestQueue.Do(ctx.Context(), position.request(false), func() (err error) {
+		requestContext, span := tracing.Start(ctx.Context(), "openai.edit_image", attribute.String("model", openai.CreateImageModelDallE2))
+		defer func() { tracing.End(span, err) }()
+		start := time.Now()
+		resp, err = client.CreateEditImage(
+			requestContext,
+			openai.ImageEditRequest{
+				Image:          imageFile,
+				Mask:           maskFile,
+				Prompt:         prompt,
+				Model:          openai.CreateImageModelDallE2,
+				N:              number,
+				Size:           size,
+				ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+			},
+		)
+		monitoring.ObserveProviderRequest(openai.CreateImageModelDallE2, start, err)
+		return
+	})
+	if err != nil {
+		ctx.Logger.Error("OpenAI request CreateEditImage failed", "error", err)
+		imageFailed(ctx, ctx.T("error.openai"), err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Dalle edit request responded", "size", size, "number", number, "images", len(resp.Data))
+	monitoring.AddCost(openai.CreateImageModelDallE2, priceForResponse(len(resp.Data), size, openai.CreateImageModelDallE2, ""))
+
+	imageResponseFollowup(ctx, archiver, prompt, imageCreationUsageEmbedFooter(ctx.Locale(), openai.CreateImageModelDallE2, size, len(resp.Data), ""), size, resp, nil, nil)
+}
+
+func imageVariationHandler(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, archiver archive.Archiver) {
+	attachment := imageAttachmentOption(ctx, imageCommandOptionImage)
+	if attachment == nil {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse image option")
+		imageFailed(ctx, ctx.T("error.title"), ctx.T("dalle.imageOption"))
+		return
+	}
+	size, number := dalle2SizeAndNumber(ctx)
+
+	imageData, ok := preparedImageAttachment(ctx, attachment)
+	if !ok {
+		return
+	}
+	imageFile, err := writeTempImage(imageData)
+	if err != nil {
+		ctx.Logger.Error("Failed to store image", "error", err)
+		imageFailed(ctx, ctx.T("error.title"), ctx.T("dalle.processImage"))
+		return
+	}
+	defer removeTempImage(imageFile)
+
+	ctx.Logger.Info("Dalle variation request invoked", "size", size, "number", number)
+	position := newQueuePosition(ctx)
+	defer position.clear()
+	var resp openai.ImageResponse
+	err = requestQueue.Do(ctx.Context(), position.request(false), func() (err error) {
+		requestContext, span := tracing.Start(ctx.Context(), "openai.image_variation", attribute.String("model", openai.CreateImageModelDallE2))
+		defer func() { tracing.End(span, err) }()
+		start := time.Now()
+		resp, err = client.CreateVariImage(
+			requestContext,
+			openai.ImageVariRequest{
+				Image:          imageFile,
+				Model:          openai.CreateImageModelDallE2,
+				N:              number,
+				Size:           size,
+				ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+			},
+		)
+		monitoring.ObserveProviderRequest(openai.CreateImageModelDallE2, start, err)
+		return
+	})
+	if err != nil {
+		ctx.Logger.Error("OpenAI request CreateVariImage failed", "error", err)
+		imageFailed(ctx, ctx.T("error.openai"), err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Dalle variation request responded", "size", size, "number", number, "images", len(resp.Data))
+	monitoring.AddCost(openai.CreateImageModelDallE2, priceForResponse(len(resp.Data), size, openai.CreateImageModelDallE2, ""))
+
+	imageResponseFollowup(ctx, archiver, ctx.T("dalle.variation.title", attachment.Filename), imageCreationUsageEmbedFooter(ctx.Locale(), openai.CreateImageModelDallE2, size, len(resp.Data), ""), size, resp, nil, nil)
+}
@@ -0,0 +1,75 @@
+package dalle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Dall-e 3 generates a single image per request, so multiple images are requested
+// concurrently, but not too many at once to stay within rate limits
+const dalle3MaxParallelRequests = 2
+
+// createImages generates `request.N` images. Dall-e 2 supports it natively, for Dall-e 3 the request
+// is fanned out into single-image requests. Successfully generated images are returned together with
+// the errors of failed requests, if any
+func createImages(ctx context.Context, client *openai.Client, request openai.ImageRequest) (openai.ImageResponse, []error) {
+	if request.Model != openai.CreateImageModelDallE3 || request.N <= 1 {
+		resp, err := createImage(ctx, client, request)
+		if err != nil {
+			return resp, []error{err}
+		}
+		return resp, nil
+	}
+
+	number := request.N
+	request.N = 1
+
+	results := make([]openai.ImageResponse, number)
+	errs := make([]error, number)
+	semaphore := make(chan struct{}, dalle3MaxParallelRequests)
+	var wg sync.WaitGroup
+	for i := 0; i < number; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			results[i], errs[i] = createImage(ctx, client, request)
+		}(i)
+	}
+	wg.Wait()
+
+	var resp openai.ImageResponse
+	var failed []error
+	for i := range results {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		if resp.Created == 0 {
+			resp.Created = results[i].Created
+		}
+		resp.Data = append(resp.Data, results[i].Data...)
+	}
+	return resp, failed
+}
+
+// createImage sends a single request, recording its latency and cost
+func createImage(ctx context.Context, client *openai.Client, request openai.ImageRequest) (openai.ImageResponse, error) {
+	ctx, span := tracing.Start(ctx, "openai.create_image", attribute.String("model", request.Model), attribute.String("size", request.Size))
+	start := time.Now()
+	resp, err := client.CreateImage(ctx, request)
+	monitoring.ObserveProviderRequest(request.Model, start, err)
+	tracing.End(span, err)
+	if err == nil {
+		monitoring.AddCost(request.Model, priceForResponse(len(resp.Data), request.Size, request.Model, request.Quality))
+	}
+	return resp, err
+}
@@ -1,39 +1,45 @@
 package dalle
 
 import (
-	"context"
+	"bytes"
+	"errors"
 	"fmt"
-	"log"
 
 	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
 	"github.com/raikerian/go-remai-bot-discord/pkg/constants"
+	"github.com/raikerian/go-remai-bot-discord/pkg/logging"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
 	"github.com/sashabaranov/go-openai"
 )
 
 const (
+	imageEmbedAuthorMaxLength = 256
+
 	dalleDefaultModel   = openai.CreateImageModelDallE3
 	dalleDefaultQuality = openai.CreateImageQualityStandard
 	dalleDefaultStyle   = openai.CreateImageStyleNatural
 )
 
-func imageHandler(ctx *bot.Context, client *openai.Client) {
+func imageHandler(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, archiver archive.Archiver) {
 	var prompt string
 	if option, ok := ctx.Options[imageCommandOptionPrompt.String()]; ok {
 		prompt = option.StringValue()
 	} else {
 		// We can't have empty prompt, unfortunately
 		// this should not happen, discord prevents empty required options
-		log.Printf("[GID: %s, i.ID: %s] Failed to parse prompt option\n", ctx.Interaction.GuildID, ctx.Interaction.ID)
+		ctx.Logger.Error("Failed to parse prompt option")
 		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 			Embeds: []*discord.MessageEmbed{
 				{
-					Title:       "❌ Error",
-					Description: " Failed to parse prompt option",
+					Title:       ctx.T("error.title"),
+					Description: ctx.T("error.promptOption"),
 					Color:       0xff0000,
 				},
 			},
-		})
+		}, discord.WithContext(ctx.Context()))
 		return
 	}
 
@@ -41,30 +47,30 @@ func imageHandler(ctx *bot.Context, client *openai.Client) {
 	model := dalleDefaultModel
 	if option, ok := ctx.Options[imageCommandOptionModel.String()]; ok {
 		model = option.StringValue()
-		log.Printf("[GID: %s, i.ID: %s] Model provided: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, model)
+		ctx.Logger.Debug("Model provided", "model", model)
 	}
 
 	size := imageDefaultSize
 	if option, ok := ctx.Options[imageCommandOptionSize.String()]; ok {
 		size = option.StringValue()
-		log.Printf("[GID: %s, i.ID: %s] Image size provided: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, size)
+		ctx.Logger.Debug("Image size provided", "size", size)
 	}
 
 	number := 1
 	if option, ok := ctx.Options[imageCommandOptionNumber.String()]; ok {
 		number = int(option.IntValue())
-		log.Printf("[GID: %s, i.ID: %s] Image number provided: %d\n", ctx.Interaction.GuildID, ctx.Interaction.ID, number)
+		ctx.Logger.Debug("Image number provided", "number", number)
 	}
 
 	quality := dalleDefaultQuality
 	if option, ok := ctx.Options[imageCommandOptionQuality.String()]; ok {
 		quality = option.StringValue()
-		log.Printf("[GID: %s, i.ID: %s] Image quality provided: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, quality)
+		ctx.Logger.Debug("Image quality provided", "quality", quality)
 	}
 	style := dalleDefaultStyle
 	if option, ok := ctx.Options[imageCommandOptionStyle.String()]; ok {
 		style = option.StringValue()
-		log.Printf("[GID: %s, i.ID: %s] Image style provided: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, style)
+		ctx.Logger.Debug("Image style provided", "style", style)
 	}
 	// quality and style dall-e 3 only
 	if model == openai.CreateImageModelDallE2 {
@@ -72,83 +78,249 @@ func imageHandler(ctx *bot.Context, client *openai.Client) {
 		style = ""
 	}
 
-	log.Printf("[GID: %s, CHID: %s] Dalle Request [Size: %s, Number: %d] invoked", ctx.Interaction.GuildID, ctx.Interaction.ID, size, number)
-	resp, err := client.CreateImage(
-		context.Background(),
-		openai.ImageRequest{
-			Prompt:         prompt,
-			Model:          model,
-			N:              number,
-			Quality:        quality,
-			Size:           size,
-			Style:          style,
-			ResponseFormat: openai.CreateImageResponseFormatURL,
-			User:           ctx.Interaction.Member.User.ID,
-		},
-	)
-	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] OpenAI request CreateImage failed with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+	exact := false
+	if option, ok := ctx.Options[imageCommandOptionExact.String()]; ok {
+		exact = option.BoolValue()
+	}
+
+	position := newQueuePosition(ctx)
+	defer position.clear()
+
+	// Enhanced prompt is generated once, rerolls reuse the one stored in the message
+	var fields []*discord.MessageEmbedField
+	imagePrompt := prompt
+	if option, ok := ctx.Options[imageCommandOptionEnhancedPrompt.String()]; ok {
+		imagePrompt = option.StringValue()
+	} else if option, ok := ctx.Options[imageCommandOptionEnhance.String()]; ok && option.BoolValue() {
+		var enhanced string
+		err := requestQueue.Do(ctx.Context(), position.request(true), func() (err error) {
+			enhanced, err = enhanceImagePrompt(ctx.Context(), client, prompt, ctx.Interaction.Member.User.ID)
+			return
+		})
+		if err != nil {
+			if ctx.Context().Err() != nil {
+				ctx.Logger.Info("Prompt enhancement interrupted", "error", err)
+				return
+			}
+			ctx.Logger.Error("Failed to enhance prompt", "error", err)
+			ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+				Embeds: []*discord.MessageEmbed{
+					{
+						Title:       ctx.T("dalle.enhanceFailed"),
+						Description: err.Error(),
+						Color:       0xff0000,
+					},
+				},
+			}, discord.WithContext(ctx.Context()))
+			return
+		}
+		imagePrompt = enhanced
+	}
+	if imagePrompt != prompt {
+		// Enhanced prompt is written by the chat model, or replayed from the message on reroll, the moderation
+		// middleware only checked the prompt of the user
+		verdict, ok := moderateImagePrompt(ctx, moderator, "Enhanced image prompt", imagePrompt)
+		if !ok {
+			return
+		}
+		defer warnImagePrompt(ctx, verdict)
+
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  imageCommandOptionEnhancedPrompt.humanReadableString(),
+			Value: imagePrompt,
+		})
+	}
+
+	requestPrompt := imagePrompt
+	if exact && model == openai.CreateImageModelDallE3 {
+		requestPrompt = dalleExactPromptPrefix + imagePrompt
+	}
+
+	ctx.Logger.Info("Dalle request invoked", "size", size, "number", number, "exact", exact, "enhanced", imagePrompt != prompt, "prompt", logging.Content(imagePrompt))
+	var resp openai.ImageResponse
+	var errs []error
+	err := requestQueue.Do(ctx.Context(), position.request(false), func() error {
+		resp, errs = createImages(
+			ctx.Context(),
+			client,
+			openai.ImageRequest{
+				Prompt:         requestPrompt,
+				Model:          model,
+				N:              number,
+				Quality:        quality,
+				Size:           size,
+				Style:          style,
+				ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+				User:           ctx.Interaction.Member.User.ID,
+			},
+		)
+		if len(resp.Data) == 0 {
+			return errors.Join(errs...)
+		}
+		// some of the images were generated, failures are shown along with them
+		return nil
+	})
+	if err != nil || len(resp.Data) == 0 {
+		if ctx.Context().Err() != nil {
+			// the bot is shutting down, the interrupt handler tells the user to retry
+			ctx.Logger.Info("Dalle request interrupted", "error", err)
+			return
+		}
+		if err == nil {
+			err = errors.New("no images were generated")
+		}
+		ctx.Logger.Error("OpenAI request CreateImage failed", "error", err)
 		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 			Embeds: []*discord.MessageEmbed{
 				{
-					Title:       "❌ OpenAI API failed",
+					Title:       ctx.T("error.openai"),
 					Description: err.Error(),
 					Color:       0xff0000,
 				},
 			},
+		}, discord.WithContext(ctx.Context()))
+		return
+	}
+
+	ctx.Logger.Info("Dalle request responded", "size", size, "number", number, "images", len(resp.Data))
+
+	if len(errs) > 0 {
+		// Some of Dall-e 3 requests failed, show what we have and tell what went wrong
+		ctx.Logger.Error("Some OpenAI CreateImage requests failed", "failed", len(errs), "number", number, "error", errs)
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  ctx.T("dalle.partialFailure", len(errs), number),
+			Value: truncateText(errors.Join(errs...).Error(), embedFieldValueMaxLength),
 		})
+	}
+
+	actions := []discord.MessageComponent{imageActionsRow(ctx.Locale(), ctx.Path, model, size, quality, style, number, exact)}
+	imageResponseFollowup(ctx, archiver, prompt, imageCreationUsageEmbedFooter(ctx.Locale(), model, size, len(resp.Data), quality), size, resp, fields, actions)
+}
+
+// imageResponseFollowup uploads generated images as attachments of a follow up message, so they don't expire
+// like OpenAI URLs do. Title is shown as embed author, fields are shown before revised prompts of the images,
+// actions are extra component rows under the images
+func imageResponseFollowup(ctx *bot.Context, archiver archive.Archiver, title string, footer *discord.MessageEmbedFooter, size string, resp openai.ImageResponse, fields []*discord.MessageEmbedField, actions []discord.MessageComponent) {
+	images, err := generatedImages(ctx.Client, resp)
+	if err != nil {
+		ctx.Logger.Error("Failed to get generated images data", "error", err)
+		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+			Content: fmt.Sprintf("> %s", title),
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("dalle.imagesFailed"),
+					Description: err.Error(),
+					Color:       0xff0000,
+				},
+			},
+		}, discord.WithContext(ctx.Context()))
 		return
 	}
 
-	log.Printf("[GID: %s, i.ID: %s] Dalle Request [Size: %s, Number: %d] responded with a data array size %d\n", ctx.Interaction.GuildID, ctx.Interaction.ID, size, number, len(resp.Data))
+	// Long titles do not fit into embed author, keep the full one in description so it can be recovered
+	author, description := title, ""
+	if runes := []rune(title); len(runes) > imageEmbedAuthorMaxLength {
+		author = string(runes[:imageEmbedAuthorMaxLength-1]) + "…"
+		description = title
+	}
+	used := len([]rune(author)) + len([]rune(description)) + len([]rune(footer.Text))
+	for _, field := range fields {
+		used += len([]rune(field.Name)) + len([]rune(field.Value))
+	}
+	fields = append(fields, revisedPromptFields(resp, used)...)
 
 	var embeds = []*discord.MessageEmbed{
 		{
-			URL: constants.OpenAIBlackIconURL,
+			URL:         constants.OpenAIBlackIconURL,
+			Description: description,
+			Fields:      fields,
 			Author: &discord.MessageEmbedAuthor{
-				Name:         prompt,
+				Name:         author,
 				IconURL:      ctx.Interaction.Member.User.AvatarURL("32"),
 				ProxyIconURL: constants.OpenAIBlackIconURL,
 			},
-			Footer: imageCreationUsageEmbedFooter(model, size, number, quality),
+			Footer: footer,
 		},
 	}
-	var buttonComponents []discord.MessageComponent
+	var files []*discord.File
 	w, h := imageSizeToWidthHeight(size)
-	for i, data := range resp.Data {
+	for _, image := range images {
 		embeds = append(embeds, &discord.MessageEmbed{
 			URL: constants.OpenAIBlackIconURL,
 			Image: &discord.MessageEmbedImage{
-				URL:    data.URL,
+				URL:    "attachment://" + image.name,
 				Width:  w,
 				Height: h,
 			},
 		})
-		buttonComponents = append(buttonComponents, &discord.Button{
-			Label: fmt.Sprintf("Image %d", (i + 1)),
-			Style: discord.LinkButton,
-			Emoji: &discord.ComponentEmoji{
-				Name: "🔗",
-			},
-			URL: data.URL,
+		files = append(files, &discord.File{
+			Name:        image.name,
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(image.data),
 		})
 	}
 
-	_, err = ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
-		Embeds:     embeds,
-		Components: []discord.MessageComponent{discord.ActionsRow{Components: buttonComponents}},
-	})
+	message, err := ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+		Embeds: embeds,
+		Files:  files,
+	}, discord.WithContext(ctx.Context()))
 	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] Failed to send a follow up message with images with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Failed to send a follow up message with images", "error", err)
 		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
-			Content: fmt.Sprintf("> %s", prompt),
+			Content: fmt.Sprintf("> %s", title),
 			Embeds: []*discord.MessageEmbed{
 				{
-					Title:       "❌ Discord API Error",
+					Title:       ctx.T("error.discord"),
 					Description: err.Error(),
 					Color:       0xff0000,
 				},
 			},
+		}, discord.WithContext(ctx.Context()))
+		return
+	}
+
+	if archiver != nil {
+		go archiveImages(archiver, ctx.Logger, ctx.Interaction, images)
+	}
+
+	// Link buttons can only point to the Discord CDN copies once they are uploaded
+	attachmentURLs := make(map[string]string, len(message.Attachments))
+	for _, attachment := range message.Attachments {
+		attachmentURLs[attachment.Filename] = attachment.URL
+	}
+	var buttonComponents []discord.MessageComponent
+	for i, image := range images {
+		url, ok := attachmentURLs[image.name]
+		if !ok {
+			continue
+		}
+		buttonComponents = append(buttonComponents, &discord.Button{
+			Label: ctx.T("dalle.image", i+1),
+			Style: discord.LinkButton,
+			Emoji: &discord.ComponentEmoji{
+				Name: "🔗",
+			},
+			URL: url,
 		})
 	}
+	// Discord rejects action rows without components, the link row is only added if it has buttons
+	var components []discord.MessageComponent
+	if len(buttonComponents) > 0 {
+		components = append(components, discord.ActionsRow{Components: buttonComponents})
+	}
+	components = append(components, actions...)
+	if w == h && len(buttonComponents) > 0 && len(buttonComponents) == len(images) {
+		// Variations are only supported for square images
+		components = append(components, imageVariationsRow(ctx.Locale(), []string{ctx.Path[0], variationCommandName}, images))
+	}
+	if len(components) == 0 {
+		return
+	}
+
+	_, err = ctx.FollowupMessageEdit(ctx.Interaction, message.ID, &discord.WebhookEdit{
+		Components: &components,
+	}, discord.WithContext(ctx.Context()))
+	if err != nil {
+		ctx.Logger.Error("Failed to add image link buttons", "error", err)
+	}
 }
@@ -0,0 +1,192 @@
+package dalle
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+
+	// Decoders of attachments that we convert to PNG
+	_ "image/jpeg"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
+	"github.com/sashabaranov/go-openai"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	imageAttachmentMaxBytes = 4 * 1024 * 1024 // Limit of OpenAI image edit and variation endpoints
+	imageDownloadMaxBytes   = 25 * 1024 * 1024
+	imageMaxDimension       = 1024
+)
+
+// downloadImage fetches attachment and decodes it. PNG, JPEG and WebP are supported
+func downloadImage(client *http.Client, url string) (image.Image, string, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, imageDownloadMaxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > imageDownloadMaxBytes {
+		return nil, "", fmt.Errorf("image is larger than %d MB", imageDownloadMaxBytes/1024/1024)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, "", errors.New("unsupported image format, please use PNG, JPEG or WebP")
+		}
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+// prepareImage validates image is square and converts it to a RGBA PNG of at most `imageMaxDimension` pixels
+// that fits into OpenAI upload limits. RGBA is required for the edit endpoint to respect transparency
+func prepareImage(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	if bounds.Dx() != bounds.Dy() {
+		return nil, fmt.Errorf("image must be square, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	side := bounds.Dx()
+	if side > imageMaxDimension {
+		side = imageMaxDimension
+	}
+
+	for {
+		rgba := image.NewNRGBA(image.Rect(0, 0, side, side))
+		if side == bounds.Dx() {
+			draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+		} else {
+			draw.CatmullRom.Scale(rgba, rgba.Bounds(), img, bounds, draw.Src, nil)
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, rgba); err != nil {
+			return nil, err
+		}
+		if buf.Len() < imageAttachmentMaxBytes {
+			return buf.Bytes(), nil
+		}
+
+		// Noisy images might not fit even in 1024x1024, keep downscaling
+		side = side * 3 / 4
+		if side < 256 {
+			return nil, fmt.Errorf("image cannot be compressed below %d MB", imageAttachmentMaxBytes/1024/1024)
+		}
+	}
+}
+
+// prepareMask resizes mask to the dimensions of the prepared image
+func prepareMask(mask image.Image, imageData []byte) ([]byte, error) {
+	config, err := png.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := mask.Bounds()
+	if bounds.Dx()*config.Height != bounds.Dy()*config.Width {
+		return nil, fmt.Errorf("mask must have the same aspect ratio as the image, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, config.Width, config.Height))
+	draw.CatmullRom.Scale(rgba, rgba.Bounds(), mask, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTempImage stores image in a temporary file, as OpenAI client only accepts files for uploads.
+// Caller is responsible for closing and removing the file
+func writeTempImage(data []byte) (*os.File, error) {
+	file, err := os.CreateTemp("", "dalle-*.png")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = file.Write(data); err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
+
+func removeTempImage(file *os.File) {
+	if file == nil {
+		return
+	}
+	file.Close()
+	os.Remove(file.Name())
+}
+
+type generatedImage struct {
+	name string
+	data []byte
+}
+
+// generatedImages decodes images of the response. Base64 is expected, URLs are downloaded as a fallback
+func generatedImages(client *http.Client, resp openai.ImageResponse) ([]generatedImage, error) {
+	images := make([]generatedImage, 0, len(resp.Data))
+	for i, data := range resp.Data {
+		image := generatedImage{name: fmt.Sprintf("image_%d.png", i+1)}
+		switch {
+		case data.B64JSON != "":
+			decoded, err := base64.StdEncoding.DecodeString(data.B64JSON)
+			if err != nil {
+				return nil, fmt.Errorf("image %d: %w", i+1, err)
+			}
+			image.data = decoded
+		case data.URL != "":
+			res, err := client.Get(data.URL)
+			if err != nil {
+				return nil, fmt.Errorf("image %d: %w", i+1, err)
+			}
+			image.data, err = io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("image %d: %w", i+1, err)
+			}
+		default:
+			return nil, fmt.Errorf("image %d: response has no data", i+1)
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// archiveImages stores images under `<guild ID>/<interaction ID>/<image name>`
+func archiveImages(archiver archive.Archiver, logger *slog.Logger, interaction *discord.Interaction, images []generatedImage) {
+	for _, image := range images {
+		key := path.Join(interaction.GuildID, interaction.ID, image.name)
+		err := archiver.Store(context.Background(), key, "image/png", image.data)
+		if err != nil {
+			logger.Error("Failed to archive image", "image", image.name, "error", err)
+		}
+	}
+}
@@ -1,74 +1,89 @@
 package dalle
 
 import (
-	"context"
-	"log"
-
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
-	"github.com/sashabaranov/go-openai"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
 )
 
 func imageInteractionResponseMiddleware(ctx *bot.Context) {
-	log.Printf("[GID: %s, i.ID: %s] Image interaction invoked by UserID: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, ctx.Interaction.Member.User.ID)
+	ctx.Logger.Info("Image interaction invoked")
 
 	err := ctx.Respond(&discord.InteractionResponse{
 		Type: discord.InteractionResponseDeferredChannelMessageWithSource,
 	})
 	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] Failed to respond to interactrion with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
 		return
 	}
 
+	// Otherwise the deferred response keeps "thinking" if the bot shuts down before the images are sent
+	interruptedMessage := ctx.T("bot.interrupted")
+	removeInterrupt := ctx.OnInterrupt(func() {
+		ctx.Session.InteractionResponseEdit(ctx.Interaction, &discord.WebhookEdit{
+			Content: &interruptedMessage,
+		})
+	})
+	defer removeInterrupt()
+
 	ctx.Next()
 }
 
-func imageModerationMiddleware(ctx *bot.Context, client *openai.Client) {
-	log.Printf("[GID: %s, i.ID: %s] Performing interaction moderation middleware\n", ctx.Interaction.GuildID, ctx.Interaction.ID)
+func imageModerationMiddleware(ctx *bot.Context, moderator *moderation.Moderator) {
+	ctx.Logger.Debug("Performing interaction moderation middleware")
 
-	var prompt string
-	if option, ok := ctx.Options[imageCommandOptionPrompt.String()]; ok {
-		prompt = option.StringValue()
-	} else {
-		// We can't have empty prompt, unfortunately
-		// this should not happen, discord prevents empty required options
-		log.Printf("[GID: %s, i.ID: %s] Failed to parse prompt option\n", ctx.Interaction.GuildID, ctx.Interaction.ID)
-		ctx.Respond(&discord.InteractionResponse{
-			Type: discord.InteractionResponseChannelMessageWithSource,
-			Data: &discord.InteractionResponseData{
-				Content: "ERROR: Failed to parse prompt option",
-			},
-		})
+	option, ok := ctx.Options[imageCommandOptionPrompt.String()]
+	if !ok {
+		// nothing to moderate, e.g. image variations
+		ctx.Next()
 		return
 	}
 
-	resp, err := client.Moderations(
-		context.Background(),
-		openai.ModerationRequest{
-			Input: prompt,
-		},
-	)
-	if err != nil {
-		// do not block request if moderation api failed
-		log.Printf("[GID: %s, i.ID: %s] OpenAI Moderation API request failed with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
-		ctx.Next()
+	verdict, ok := moderateImagePrompt(ctx, moderator, "Image prompt", option.StringValue())
+	if !ok {
 		return
 	}
 
-	if resp.Results[0].Flagged {
+	ctx.Next()
+
+	warnImagePrompt(ctx, verdict)
+}
+
+// moderateImagePrompt checks the prompt and reports it if it is flagged. Blocked prompts are answered
+// with an error and false is returned
+func moderateImagePrompt(ctx *bot.Context, moderator *moderation.Moderator, kind string, prompt string) (*moderation.Verdict, bool) {
+	verdict := moderator.CheckInput(ctx.Context(), ctx.Interaction.GuildID, prompt)
+	moderator.Report(ctx.Session, verdict, moderation.Report{
+		Kind:      kind,
+		GuildID:   ctx.Interaction.GuildID,
+		ChannelID: ctx.Interaction.ChannelID,
+		UserID:    ctx.Interaction.Member.User.ID,
+		Content:   prompt,
+	})
+
+	if verdict.Blocked() {
 		// response was flagged, send error
-		log.Printf("[GID: %s, i.ID: %s] Interaction was flagged by Moderation API, prompt: \"%s\"\n", ctx.Interaction.GuildID, ctx.Interaction.ID, prompt)
+		ctx.Logger.Info("Interaction was flagged by moderation", "kind", kind, "categories", verdict.Categories)
 		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 			Embeds: []*discord.MessageEmbed{
 				{
-					Title:       "❌ Error",
-					Description: "The provided prompt contains text that violates OpenAI's usage policies and is not allowed by their safety system",
+					Title:       ctx.T("error.title"),
+					Description: verdict.Reason(ctx.Locale()),
 					Color:       0xff0000,
 				},
 			},
-		})
-		return
+		}, discord.WithContext(ctx.Context()))
+		return verdict, false
 	}
+	return verdict, true
+}
 
-	ctx.Next()
+// warnImagePrompt follows up with the warning of the verdict, if the prompt was let through with one
+func warnImagePrompt(ctx *bot.Context, verdict *moderation.Verdict) {
+	if verdict.Warned() {
+		ctx.Logger.Info("Interaction was flagged by moderation with a warning", "categories", verdict.Categories)
+		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+			Content: verdict.Warning(ctx.Locale()),
+		}, discord.WithContext(ctx.Context()))
+	}
 }
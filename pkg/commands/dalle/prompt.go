@@ -0,0 +1,108 @@
+package dalle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// See https://platform.openai.com/docs/guides/images/prompting
+	dalleExactPromptPrefix = "I NEED to test how the tool works with extremely simple prompts. DO NOT add any detail, just use it AS-IS: "
+
+	dalleEnhanceModel            = openai.GPT4o
+	dalleEnhancedPromptMaxLength = 800
+	dalleEnhanceSystemMessage    = "You are an assistant that turns short image ideas into detailed prompts for an image generation model. " +
+		"Describe subject, composition, setting, lighting, colors and style in a single paragraph. " +
+		"Keep the language of the original idea. Reply with the prompt only, without quotes, at most %d characters."
+
+	embedFieldValueMaxLength = 1024
+	embedMaxTotalLength      = 6000
+)
+
+// enhanceImagePrompt expands a short prompt into a detailed one with a chat model
+func enhanceImagePrompt(ctx context.Context, client *openai.Client, prompt string, user string) (string, error) {
+	ctx, span := tracing.Start(ctx, "openai.chat_completion", attribute.String("model", dalleEnhanceModel))
+	start := time.Now()
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model: dalleEnhanceModel,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf(dalleEnhanceSystemMessage, dalleEnhancedPromptMaxLength),
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		MaxTokens:   300,
+		Temperature: 0.7,
+		User:        user,
+	})
+	monitoring.ObserveProviderRequest(dalleEnhanceModel, start, err)
+	tracing.End(span, err)
+	if err != nil {
+		return "", err
+	}
+	monitoring.AddUsage(dalleEnhanceModel, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	if len(resp.Choices) == 0 {
+		return "", errors.New("chat model returned no choices")
+	}
+
+	enhanced := strings.Trim(strings.TrimSpace(resp.Choices[0].Message.Content), "\"")
+	if enhanced == "" {
+		return "", errors.New("chat model returned an empty prompt")
+	}
+	return truncateText(enhanced, embedFieldValueMaxLength), nil
+}
+
+func truncateText(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// revisedPromptFields shows prompts rewritten by Dall-e 3 for each image. Embeds of a message share
+// a limit of 6000 characters, so each prompt gets an equal share of what is left
+func revisedPromptFields(resp openai.ImageResponse, used int) []*discord.MessageEmbedField {
+	var revised []string
+	for _, data := range resp.Data {
+		if data.RevisedPrompt != "" {
+			revised = append(revised, data.RevisedPrompt)
+		}
+	}
+	if len(revised) == 0 {
+		return nil
+	}
+
+	fields := make([]*discord.MessageEmbedField, 0, len(resp.Data))
+	for i, data := range resp.Data {
+		if data.RevisedPrompt == "" {
+			continue
+		}
+		name := "Revised prompt"
+		if len(resp.Data) > 1 {
+			name = fmt.Sprintf("Revised prompt %d", i+1)
+		}
+		limit := min((embedMaxTotalLength-used)/len(revised)-len(name), embedFieldValueMaxLength)
+		if limit <= 1 {
+			break
+		}
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  name,
+			Value: truncateText(data.RevisedPrompt, limit),
+		})
+	}
+	return fields
+}
@@ -0,0 +1,47 @@
+package dalle
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+)
+
+// queuePosition shows queue position of image requests in the deferred interaction response.
+// Results are sent as follow up messages, so the response is removed with clear once they are sent
+type queuePosition struct {
+	ctx   *bot.Context
+	shown bool
+}
+
+func newQueuePosition(ctx *bot.Context) *queuePosition {
+	return &queuePosition{ctx: ctx}
+}
+
+func (p *queuePosition) request(short bool) queue.Request {
+	return queue.Request{
+		UserID:     p.ctx.Interaction.Member.User.ID,
+		Short:      short,
+		OnPosition: p.update,
+	}
+}
+
+func (p *queuePosition) update(position int) {
+	content := p.ctx.T("queue.started")
+	if position > 0 {
+		content = p.ctx.T("queue.position", position)
+	}
+	if err := p.ctx.Edit(content); err != nil {
+		p.ctx.Logger.Error("Failed to update queue position", "error", err)
+		return
+	}
+	p.shown = true
+}
+
+func (p *queuePosition) clear() {
+	if !p.shown {
+		return
+	}
+	if err := p.ctx.InteractionResponseDelete(p.ctx.Interaction, discord.WithContext(p.ctx.Context())); err != nil {
+		p.ctx.Logger.Error("Failed to remove queue position", "error", err)
+	}
+}
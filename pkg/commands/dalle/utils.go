@@ -1,10 +1,9 @@
 package dalle
 
 import (
-	"fmt"
-
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/constants"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -48,15 +47,15 @@ func priceForResponse(n int, size, model, quality string) float64 {
 	return 0
 }
 
-func imageCreationUsageEmbedFooter(model string, size string, number int, quality string) *discord.MessageEmbedFooter {
-	extraInfo := fmt.Sprintf("Model: %s", model)
-	extraInfo += fmt.Sprintf("\nSize: %s", size)
-	if model == openai.CreateImageModelDallE2 && number > 1 {
-		extraInfo += fmt.Sprintf(", Images: %d", number)
+func imageCreationUsageEmbedFooter(locale discord.Locale, model string, size string, number int, quality string) *discord.MessageEmbedFooter {
+	extraInfo := i18n.T(locale, "dalle.footer.model", model)
+	extraInfo += "\n" + i18n.T(locale, "dalle.footer.size", size)
+	if number > 1 {
+		extraInfo += ", " + i18n.T(locale, "dalle.footer.images", number)
 	}
 	price := priceForResponse(number, size, model, quality)
 	if price > 0 {
-		extraInfo += fmt.Sprintf("\nGeneration Cost: $%g", price)
+		extraInfo += "\n" + i18n.T(locale, "dalle.footer.cost", price)
 	}
 	return &discord.MessageEmbedFooter{
 		Text:    extraInfo,
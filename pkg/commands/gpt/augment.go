@@ -0,0 +1,83 @@
+package gpt
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Rounds of tool calls before the model must answer
+const gptToolCallsMaxRounds = 3
+
+// augmentation is what is sent along with the conversation for its last message without becoming a part of it
+type augmentation struct {
+	// memory of the user who started the conversation, added to its system message
+	memory    string
+	knowledge knowledge
+	// remember saves a fact about the author of the last message, nil if they did not let the model learn
+	remember func(fact string) error
+}
+
+// augmentConversation prepares the request for the last message of the conversation, written by the user
+func augmentConversation(ctx context.Context, knowledgeBases *kb.Store, memories *memory.Store, logger *slog.Logger, locale discord.Locale, guildID string, userID string, cacheItem *MessagesCacheData) augmentation {
+	return augmentation{
+		memory:    memoryPrompt(memories, cacheItem),
+		knowledge: retrieveKnowledge(ctx, knowledgeBases, logger, locale, guildID, cacheItem),
+		remember:  rememberFunc(memories, logger, userID),
+	}
+}
+
+// systemMessage is the system message of the conversation with memory added to it
+func (a *augmentation) systemMessage(cacheItem *MessagesCacheData) *openai.ChatCompletionMessage {
+	if a.memory == "" {
+		return cacheItem.SystemMessage
+	}
+	content := a.memory
+	if cacheItem.SystemMessage != nil {
+		content = cacheItem.SystemMessage.Content + "\n\n" + a.memory
+	}
+	return &openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: content,
+	}
+}
+
+func (a *augmentation) tools() []openai.Tool {
+	if a.remember == nil {
+		return nil
+	}
+	return []openai.Tool{gptRememberTool}
+}
+
+// call runs the tool call, returns its result for the model and the fact that was remembered, if any
+func (a *augmentation) call(call openai.ToolCall) (result string, learned string) {
+	if call.Function.Name != gptRememberTool.Function.Name || a.remember == nil {
+		return "Error: unknown tool " + call.Function.Name, ""
+	}
+	fact, err := rememberArguments(call.Function.Arguments)
+	if err == nil {
+		err = a.remember(fact)
+	}
+	if err != nil {
+		return "Error: " + err.Error(), ""
+	}
+	return "Remembered", fact
+}
+
+// notes about the answer: facts the model remembered and sources it cites
+func (a *augmentation) notes(locale discord.Locale, resp *chatGPTResponse) string {
+	var notes []string
+	if len(resp.learned) > 0 {
+		notes = append(notes, i18n.T(locale, "gpt.memory.learned", strings.Join(resp.learned, "; ")))
+	}
+	if a.knowledge.sources != "" {
+		notes = append(notes, a.knowledge.sources)
+	}
+	return strings.Join(notes, "\n\n")
+}
@@ -1,12 +1,43 @@
 package gpt
 
 import (
+	"sync"
+
 	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
 	"github.com/sashabaranov/go-openai"
 )
 
-type IgnoredChannelsCache map[string]struct{}
+// IgnoredChannelsCache remembers channels that are not chat threads, so their messages are ignored without
+// looking up the thread. It is safe for concurrent use by handlers of all shards
+type IgnoredChannelsCache struct {
+	mu       sync.RWMutex
+	channels map[string]struct{}
+}
 
+func NewIgnoredChannelsCache() *IgnoredChannelsCache {
+	return &IgnoredChannelsCache{
+		channels: make(map[string]struct{}),
+	}
+}
+
+func (c *IgnoredChannelsCache) Contains(channelID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.channels[channelID]
+	return ok
+}
+
+func (c *IgnoredChannelsCache) Add(channelID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.channels[channelID] = struct{}{}
+}
+
+// MessagesCache keeps conversations of threads. Events of a guild always come to the same shard, so with shards
+// split between processes every process caches conversations of its own guilds only
 type MessagesCache struct {
 	*lru.Cache[string, *MessagesCacheData]
 }
@@ -17,6 +48,10 @@ type MessagesCacheData struct {
 	Model         string
 	Temperature   *float32
 	TokenCount    int
+	// Knowledge base searched for every message of the conversation, if any
+	KnowledgeBase string
+	// User whose memory is added to the system message, the one who started the conversation
+	MemoryUserID string
 }
 
 func NewMessagesCache(size int) (*MessagesCache, error) {
@@ -29,3 +64,10 @@ func NewMessagesCache(size int) (*MessagesCache, error) {
 		Cache: lruCache,
 	}, nil
 }
+
+// Get looks up the conversation of the thread, counting cache hits and misses
+func (c *MessagesCache) Get(threadID string) (*MessagesCacheData, bool) {
+	data, ok := c.Cache.Get(threadID)
+	monitoring.MessagesCacheLookup(ok)
+	return data, ok
+}
@@ -3,76 +3,108 @@ package gpt
 import (
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
 	"github.com/sashabaranov/go-openai"
 )
 
-var gptDefaultModel = openai.GPT3Dot5Turbo
+const (
+	commandName = "gpt"
 
-const commandName = "gpt"
+	// Model used when there are no configured completion models
+	gptFallbackModel = openai.GPT3Dot5Turbo
+)
 
-func Command(client *openai.Client, completionModels []string, messagesCache *MessagesCache, ignoredChannelsCache *IgnoredChannelsCache) *bot.Command {
+func Command(client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, settingsStore *settings.Store, completionModels []string, messagesCache *MessagesCache, ignoredChannelsCache *IgnoredChannelsCache, knowledgeBases *kb.Store, memories *memory.Store) *bot.Command {
 	temperatureOptionMinValue := 0.0
 	opts := []*discord.ApplicationCommandOption{
 		{
-			Type:        discord.ApplicationCommandOptionString,
-			Name:        gptCommandOptionPrompt.String(),
-			Description: "ChatGPT prompt",
-			Required:    true,
+			Type:                     discord.ApplicationCommandOptionString,
+			Name:                     gptCommandOptionPrompt.String(),
+			Description:              i18n.T(i18n.Default, "gpt.option.prompt"),
+			DescriptionLocalizations: i18n.Localizations("gpt.option.prompt"),
+			Required:                 true,
 		},
 		{
-			Type:        discord.ApplicationCommandOptionString,
-			Name:        gptCommandOptionContext.String(),
-			Description: "Sets context that guides the AI assistant's behavior during the conversation",
-			Required:    false,
+			Type:                     discord.ApplicationCommandOptionString,
+			Name:                     gptCommandOptionContext.String(),
+			Description:              i18n.T(i18n.Default, "gpt.option.context"),
+			DescriptionLocalizations: i18n.Localizations("gpt.option.context"),
+			Required:                 false,
 		},
 		{
-			Type:        discord.ApplicationCommandOptionAttachment,
-			Name:        gptCommandOptionContextFile.String(),
-			Description: "File that sets context that guides the AI assistant's behavior during the conversation",
-			Required:    false,
+			Type:                     discord.ApplicationCommandOptionAttachment,
+			Name:                     gptCommandOptionContextFile.String(),
+			Description:              i18n.T(i18n.Default, "gpt.option.contextFile"),
+			DescriptionLocalizations: i18n.Localizations("gpt.option.contextFile"),
+			Required:                 false,
 		},
 	}
-	numberOfModels := len(completionModels)
-	if numberOfModels > 0 {
-		gptDefaultModel = completionModels[0] // set first model as default one
-	}
-	if numberOfModels > 1 {
-		var modelChoices []*discord.ApplicationCommandOptionChoice
-		for i, model := range completionModels {
-			name := model
-			if i == 0 {
-				name += " (Default)"
-			}
-			modelChoices = append(modelChoices, &discord.ApplicationCommandOptionChoice{
-				Name:  name,
-				Value: model,
-			})
-		}
+	if len(completionModels) > 1 {
 		opts = append(opts, &discord.ApplicationCommandOption{
-			Type:        discord.ApplicationCommandOptionString,
-			Name:        gptCommandOptionModel.String(),
-			Description: "GPT model",
-			Required:    false,
-			Choices:     modelChoices,
+			Type:                     discord.ApplicationCommandOptionString,
+			Name:                     gptCommandOptionModel.String(),
+			Description:              i18n.T(i18n.Default, "gpt.option.model"),
+			DescriptionLocalizations: i18n.Localizations("gpt.option.model"),
+			Required:                 false,
+			Choices:                  modelChoices(completionModels),
 		})
 	}
 	opts = append(opts, &discord.ApplicationCommandOption{
-		Type:        discord.ApplicationCommandOptionNumber,
-		Name:        gptCommandOptionTemperature.String(),
-		Description: "What sampling temperature to use, between 0.0 and 2.0. Lower - more focused and deterministic",
-		MinValue:    &temperatureOptionMinValue,
-		MaxValue:    2.0,
-		Required:    false,
+		Type:                     discord.ApplicationCommandOptionNumber,
+		Name:                     gptCommandOptionTemperature.String(),
+		Description:              i18n.T(i18n.Default, "gpt.option.temperature"),
+		DescriptionLocalizations: i18n.Localizations("gpt.option.temperature"),
+		MinValue:                 &temperatureOptionMinValue,
+		MaxValue:                 2.0,
+		Required:                 false,
 	})
+	if knowledgeBases != nil {
+		opts = append(opts, &discord.ApplicationCommandOption{
+			Type:                     discord.ApplicationCommandOptionString,
+			Name:                     gptCommandOptionKnowledgeBase.String(),
+			Description:              i18n.T(i18n.Default, "gpt.option.kb"),
+			DescriptionLocalizations: i18n.Localizations("gpt.option.kb"),
+			Required:                 false,
+		})
+	}
 	return &bot.Command{
-		Name:        commandName,
-		Description: "Start conversation with ChatGPT",
-		Options:     opts,
+		Name:                     commandName,
+		Description:              i18n.T(i18n.Default, "gpt.description"),
+		DescriptionLocalizations: i18n.Localizations("gpt.description"),
+		Options:                  opts,
 		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
-			chatGPTHandler(ctx, client, messagesCache)
+			if ctx.Interaction.Type == discord.InteractionMessageComponent {
+				chatGPTRetryHandler(ctx, client, requestQueue, moderator, settingsStore, completionModels, messagesCache, knowledgeBases, memories)
+				return
+			}
+			chatGPTHandler(ctx, client, requestQueue, moderator, settingsStore, completionModels, messagesCache, knowledgeBases, memories)
 		}),
+		ComponentHandler: bot.HandlerFunc(chatGPTComponentHandler),
 		MessageHandler: bot.MessageHandlerFunc(func(ctx *bot.MessageContext) {
-			chatGPTMessageHandler(ctx, client, messagesCache, ignoredChannelsCache)
+			chatGPTMessageHandler(ctx, client, requestQueue, moderator, settingsStore, completionModels, messagesCache, ignoredChannelsCache, knowledgeBases, memories)
 		}),
 	}
 }
+
+// Model choices are registered once for all guilds, guild settings may allow only some of them.
+// Default is marked according to the configuration, guilds may have their own
+func modelChoices(completionModels []string) []*discord.ApplicationCommandOptionChoice {
+	var choices []*discord.ApplicationCommandOptionChoice
+	for i, model := range completionModels {
+		choice := &discord.ApplicationCommandOptionChoice{
+			Name:  model,
+			Value: model,
+		}
+		if i == 0 {
+			choice.Name = i18n.T(i18n.Default, "choice.default", model)
+			choice.NameLocalizations = i18n.Localizations("choice.default", model)
+		}
+		choices = append(choices, choice)
+	}
+	return choices
+}
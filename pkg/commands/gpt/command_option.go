@@ -5,11 +5,13 @@ import "fmt"
 type gptCommandOptionType uint8
 
 const (
-	gptCommandOptionPrompt      gptCommandOptionType = 1
-	gptCommandOptionContext     gptCommandOptionType = 2
-	gptCommandOptionContextFile gptCommandOptionType = 3
-	gptCommandOptionModel       gptCommandOptionType = 4
-	gptCommandOptionTemperature gptCommandOptionType = 5
+	gptCommandOptionPrompt        gptCommandOptionType = 1
+	gptCommandOptionContext       gptCommandOptionType = 2
+	gptCommandOptionContextFile   gptCommandOptionType = 3
+	gptCommandOptionModel         gptCommandOptionType = 4
+	gptCommandOptionTemperature   gptCommandOptionType = 5
+	gptCommandOptionFile          gptCommandOptionType = 6
+	gptCommandOptionKnowledgeBase gptCommandOptionType = 7
 )
 
 func (t gptCommandOptionType) String() string {
@@ -24,6 +26,10 @@ func (t gptCommandOptionType) String() string {
 		return "model"
 	case gptCommandOptionTemperature:
 		return "temperature"
+	case gptCommandOptionFile:
+		return "file"
+	case gptCommandOptionKnowledgeBase:
+		return "kb"
 	}
 	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
 }
@@ -40,6 +46,10 @@ func (t gptCommandOptionType) humanReadableString() string {
 		return "Model"
 	case gptCommandOptionTemperature:
 		return "Temperature"
+	case gptCommandOptionFile:
+		return "File"
+	case gptCommandOptionKnowledgeBase:
+		return "Knowledge base"
 	}
 	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
 }
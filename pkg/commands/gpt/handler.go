@@ -2,41 +2,46 @@ package gpt
 
 import (
 	"fmt"
-	"log"
+	"strings"
 
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
 	"github.com/raikerian/go-remai-bot-discord/pkg/constants"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/logging"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
 	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
 	"github.com/sashabaranov/go-openai"
 )
 
 const (
-	// Discord expects the auto_archive_duration to be one of the following values: 60, 1440, 4320, or 10080,
-	// which represent the number of minutes before a thread is automatically archived
-	// (1 hour, 1 day, 3 days, or 7 days, respectively).
-	gptDiscordThreadAutoArchivewDurationMinutes = 60
-
 	gptInteractionEmbedColor  = 0x000000
-	gptPendingMessage         = "⌛ Wait a moment, please..."
 	gptContextOptionMaxLength = 1024 // due to discord embed field value limitation
 )
 
-func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *MessagesCache) {
+func chatGPTHandler(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, settingsStore *settings.Store, completionModels []string, messagesCache *MessagesCache, knowledgeBases *kb.Store, memories *memory.Store) {
 	ch, err := ctx.Session.State.Channel(ctx.Interaction.ChannelID)
 	if err == nil && ch.IsThread() {
 		// ignore interactions invoked in threads
-		log.Printf("[GID: %s, i.ID: %s] Interaction was invoked in the existing thread, ignoring\n", ctx.Interaction.GuildID, ctx.Interaction.ID)
+		ctx.Logger.Info("Interaction was invoked in the existing thread, ignoring")
+		return
+	}
+
+	guild := settingsStore.Get(ctx.Interaction.GuildID)
+	if !chatChannelAllowed(ctx, &guild) {
 		return
 	}
 
-	log.Printf("[GID: %s, i.ID: %s] ChatGPT interaction invoked by UserID: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, ctx.Interaction.Member.User.ID)
+	ctx.Logger.Info("ChatGPT interaction invoked")
 
 	err = ctx.Respond(&discord.InteractionResponse{
 		Type: discord.InteractionResponseDeferredChannelMessageWithSource,
 	})
 	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] Failed to respond to interactrion with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
 		return
 	}
 
@@ -46,16 +51,16 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 	} else {
 		// We can't have empty prompt, unfortunately
 		// this should not happen, discord prevents empty required options
-		log.Printf("[GID: %s, i.ID: %s] Failed to parse prompt option\n", ctx.Interaction.GuildID, ctx.Interaction.ID)
+		ctx.Logger.Error("Failed to parse prompt option")
 		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 			Embeds: []*discord.MessageEmbed{
 				{
-					Title:       "❌ Error",
-					Description: "Failed to parse prompt option",
+					Title:       ctx.T("error.title"),
+					Description: ctx.T("error.promptOption"),
 					Color:       0xff0000,
 				},
 			},
-		})
+		}, discord.WithContext(ctx.Context()))
 		return
 	}
 
@@ -65,10 +70,22 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 	})
 
 	// Determine model
-	model := gptDefaultModel
+	model := guild.Model(completionModels, gptFallbackModel)
 	if option, ok := ctx.Options[gptCommandOptionModel.String()]; ok {
 		model = option.StringValue()
-		log.Printf("[GID: %s, i.ID: %s] Model provided: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, model)
+		ctx.Logger.Debug("Model provided", "model", model)
+	}
+	if message := guildModelError(ctx.Locale(), &guild, completionModels, model); message != "" {
+		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: message,
+					Color:       0xff0000,
+				},
+			},
+		}, discord.WithContext(ctx.Context()))
+		return
 	}
 
 	// Prepare cache item
@@ -89,16 +106,16 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 
 		context, err := getContentOrURLData(ctx.Client, attachmentURL)
 		if err != nil {
-			log.Printf("[GID: %s, i.ID: %s] Failed to get context file data with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+			ctx.Logger.Error("Failed to get context file data", "error", err)
 			ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 				Embeds: []*discord.MessageEmbed{
 					{
-						Title:       "Failed to get attachment data",
+						Title:       ctx.T("error.attachment"),
 						Description: err.Error(),
 						Color:       0xff0000,
 					},
 				},
-			})
+			}, discord.WithContext(ctx.Context()))
 			return
 		}
 
@@ -116,13 +133,13 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 			ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 				Embeds: []*discord.MessageEmbed{
 					{
-						Title:       "Failed to process context file",
-						Description: fmt.Sprintf("Context file is `%d` tokens, which exceeds allowed token limit of `%d` for model `%s`.\nPlease provide a shorter file or use `context` option instead", count, truncateLimit, model),
+						Title:       ctx.T("gpt.contextFileTooLong.title"),
+						Description: ctx.T("gpt.contextFileTooLong", count, truncateLimit, model),
 						Color:       0xff0000,
 					},
 				},
-			})
-			log.Printf("[GID: %s, i.ID: %s] User provided context file has %d tokens, which exceeds allowed token limit of `%d` for model `%s`.\n", ctx.Interaction.GuildID, ctx.Interaction.ID, count, truncateLimit, model)
+			}, discord.WithContext(ctx.Context()))
+			ctx.Logger.Info("User provided context file exceeds token limit", "tokens", count, "limit", truncateLimit, "model", model)
 			return
 		}
 
@@ -131,20 +148,20 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 			Value: attachmentURL,
 		})
 
-		log.Printf("[GID: %s, i.ID: %s] Context file provided: [AID: %s]\n", ctx.Interaction.GuildID, ctx.Interaction.ID, attachmentID)
+		ctx.Logger.Debug("Context file provided", "attachment", attachmentID)
 	} else if option, ok := ctx.Options[gptCommandOptionContext.String()]; ok {
 		context := option.StringValue()
 		if len(context) >= gptContextOptionMaxLength {
-			log.Printf("[GID: %s, i.ID: %s] User-provided context is above limit of %d characters\n", ctx.Interaction.GuildID, ctx.Interaction.ID, gptContextOptionMaxLength)
+			ctx.Logger.Info("User provided context is above characters limit", "limit", gptContextOptionMaxLength)
 			ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 				Embeds: []*discord.MessageEmbed{
 					{
-						Title:       "Failed to process command",
-						Description: fmt.Sprintf("Provided context is above the limit of %d characters. Please use `context-file` option instead", gptContextOptionMaxLength),
+						Title:       ctx.T("error.command"),
+						Description: ctx.T("gpt.contextTooLong", gptContextOptionMaxLength),
 						Color:       0xff0000,
 					},
 				},
-			})
+			}, discord.WithContext(ctx.Context()))
 			return
 		}
 		cacheItem.SystemMessage = &openai.ChatCompletionMessage{
@@ -155,7 +172,7 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 			Name:  gptCommandOptionContext.humanReadableString(),
 			Value: context,
 		})
-		log.Printf("[GID: %s, i.ID: %s] Context provided: %s\n", ctx.Interaction.GuildID, ctx.Interaction.ID, context)
+		ctx.Logger.Debug("Context provided", "context", logging.Content(context))
 	}
 
 	// Add model info field after context
@@ -171,35 +188,94 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 			Name:  gptCommandOptionTemperature.humanReadableString(),
 			Value: fmt.Sprintf("%g", temp),
 		})
-		log.Printf("[GID: %s, i.ID: %s] Temperature provided: %g\n", ctx.Interaction.GuildID, ctx.Interaction.ID, temp)
+		ctx.Logger.Debug("Temperature provided", "temperature", temp)
+	}
+
+	if option, ok := ctx.Options[gptCommandOptionKnowledgeBase.String()]; ok && knowledgeBases != nil {
+		name := strings.ToLower(strings.TrimSpace(option.StringValue()))
+		exists, err := knowledgeBases.Exists(ctx.Interaction.GuildID, name)
+		if err != nil || !exists {
+			ctx.Logger.Info("Knowledge base is not available", "kb", name, "error", err)
+			ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+				Embeds: []*discord.MessageEmbed{
+					{
+						Title:       ctx.T("error.title"),
+						Description: ctx.T("gpt.kb.notFound", name),
+						Color:       0xff0000,
+					},
+				},
+			}, discord.WithContext(ctx.Context()))
+			return
+		}
+		cacheItem.KnowledgeBase = name
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  gptCommandOptionKnowledgeBase.humanReadableString(),
+			Value: name,
+		})
+		ctx.Logger.Debug("Knowledge base provided", "kb", name)
+	}
+
+	// Memory of the user is added to the system message of every request, so facts they forget are forgotten
+	// in their conversations too
+	if memories != nil {
+		cacheItem.MemoryUserID = ctx.Interaction.Member.User.ID
+	}
+
+	// Moderate user input before anything is posted
+	moderationInput := prompt
+	if cacheItem.SystemMessage != nil {
+		moderationInput = cacheItem.SystemMessage.Content + "\n" + prompt
+	}
+	verdict := moderator.CheckInput(ctx.Context(), ctx.Interaction.GuildID, moderationInput)
+	moderator.Report(ctx.Session, verdict, moderation.Report{
+		Kind:      "Chat prompt",
+		GuildID:   ctx.Interaction.GuildID,
+		ChannelID: ctx.Interaction.ChannelID,
+		UserID:    ctx.Interaction.Member.User.ID,
+		Content:   moderationInput,
+	})
+	if verdict.Blocked() {
+		ctx.Logger.Info("Interaction was flagged by moderation", "categories", verdict.Categories)
+		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: verdict.Reason(ctx.Locale()),
+					Color:       0xff0000,
+				},
+			},
+		}, discord.WithContext(ctx.Context()))
+		return
 	}
 
-	// Respond to interaction with a reference and user ping
+	// Respond to interaction with a reference and user ping. The thread is started from this message, its
+	// metadata file is what the conversation is rebuilt from
 	_, err = ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 		Embeds: []*discord.MessageEmbed{
 			{
 				Description: prompt,
 				Color:       gptInteractionEmbedColor,
 				Author: &discord.MessageEmbedAuthor{
-					Name:         "OpenAI chat request by " + ctx.Interaction.Member.User.Username,
+					Name:         ctx.T("gpt.author", ctx.Interaction.Member.User.Username),
 					IconURL:      ctx.Interaction.Member.User.AvatarURL("32"),
 					ProxyIconURL: constants.OpenAIBlackIconURL,
 				},
 				Fields: fields,
 			},
 		},
-	})
+		Files: []*discord.File{newThreadMetadata(prompt, cacheItem).file()},
+	}, discord.WithContext(ctx.Context()))
 	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] Failed to respond to interactrion with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
 		ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
 			Embeds: []*discord.MessageEmbed{
 				{
-					Title:       "Failed to process command",
+					Title:       ctx.T("error.command"),
 					Description: err.Error(),
 					Color:       0xff0000,
 				},
 			},
-		})
+		}, discord.WithContext(ctx.Context()))
 		return
 	}
 
@@ -208,92 +284,122 @@ func chatGPTHandler(ctx *bot.Context, client *openai.Client, messagesCache *Mess
 	if err != nil {
 		// Without interaction reference we cannot create a thread with the response of ChatGPT
 		// Maybe in the future just try to post a new message instead, but for now just cancel
-		log.Printf("[GID: %s, i.ID: %s] Failed to get interaction reference with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
-		ctx.Edit(fmt.Sprintf("Failed to get interaction reference with error: %v", err))
+		ctx.Logger.Error("Failed to get interaction reference", "error", err)
+		ctx.Edit(ctx.T("gpt.interactionReference", err))
 		return
 	}
 
 	ch, err = ctx.Session.State.Channel(m.ChannelID)
 	if err != nil || ch.IsThread() {
-		log.Printf("[GID: %s, i.ID: %s] Interaction reply was in a thread, or there was an error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Info("Interaction reply was in a thread, or there was an error", "error", err)
 		return
 	}
 
 	thread, err := ctx.Session.MessageThreadStartComplex(m.ChannelID, m.ID, &discord.ThreadStart{
 		Name:                "New chat",
-		AutoArchiveDuration: gptDiscordThreadAutoArchivewDurationMinutes,
+		AutoArchiveDuration: guild.ThreadArchiveDuration(),
 		Invitable:           false,
-	})
+	}, discord.WithContext(ctx.Context()))
 
 	if err != nil {
 		// Without thread we cannot reply our answer
-		log.Printf("[GID: %s, i.ID: %s] Failed to create a thread with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Failed to create a thread", "error", err)
 		return
 	}
+	ctx.Logger = ctx.Logger.With("thread", thread.ID)
 
 	// Lock the thread while we are generating ChatGPT answser
-	utils.ToggleDiscordThreadLock(ctx.Session, thread.ID, true)
+	utils.ToggleDiscordThreadLock(ctx.Session, thread.ID, true, discord.WithContext(ctx.Context()))
+	// Do not leave the thread locked if the bot shuts down before answering
+	removeUnlock := ctx.OnInterrupt(func() {
+		utils.ToggleDiscordThreadLock(ctx.Session, thread.ID, false)
+	})
+	defer removeUnlock()
 
 	// add user to the thread
-	ctx.ThreadMemberAdd(thread.ID, ctx.Interaction.Member.User.ID)
+	ctx.ThreadMemberAdd(thread.ID, ctx.Interaction.Member.User.ID, discord.WithContext(ctx.Context()))
+
+	if verdict.Warned() {
+		utils.DiscordChannelMessageSend(ctx.Session, thread.ID, verdict.Warning(ctx.Locale()), nil, discord.WithContext(ctx.Context()))
+	}
 
-	channelMessage, err := utils.DiscordChannelMessageSend(ctx.Session, thread.ID, gptPendingMessage, nil)
+	channelMessage, err := utils.DiscordChannelMessageSend(ctx.Session, thread.ID, ctx.T("gpt.pending"), nil, discord.WithContext(ctx.Context()))
 	if err != nil {
 		// Without reply  we cannot edit message with the response of ChatGPT
 		// Maybe in the future just try to post a new message instead, but for now just cancel
-		log.Printf("[GID: %s, i.ID: %s] Failed to reply in the thread with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Failed to reply in the thread", "error", err)
 		return
 	}
+	removeNotice := ctx.OnInterrupt(func() {
+		interruptedNotice(ctx.Session, ctx.Locale(), ctx.Path, channelMessage)
+	})
+	defer removeNotice()
 
 	messagesCache.Add(thread.ID, cacheItem)
 
-	log.Printf("[GID: %s, i.ID: %s] ChatGPT Request invoked with [Model: %s]. Current cache size: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, cacheItem.Model, len(cacheItem.Messages))
-	resp, err := sendChatGPTRequest(client, cacheItem)
+	if !answerInMessage(ctx, client, requestQueue, moderator, knowledgeBases, memories, channelMessage, cacheItem) {
+		return
+	}
+
+	// Unlock the thread at the end
+	utils.ToggleDiscordThreadLock(ctx.Session, thread.ID, false, discord.WithContext(ctx.Context()))
+
+	// The title is generated within the request rather than in the background, its context is cancelled once
+	// the handler returns
+	generateThreadTitleBasedOnInitialPrompt(ctx, client, requestQueue, thread.ID, cacheItem.Messages)
+}
+
+// answerInMessage requests a completion of the conversation and replaces the pending message with it,
+// long answers continue in new messages. Reports whether the completion was received, the message shows
+// the error otherwise
+func answerInMessage(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, knowledgeBases *kb.Store, memories *memory.Store, channelMessage *discord.Message, cacheItem *MessagesCacheData) bool {
+	threadID := channelMessage.ChannelID
+	aug := augmentConversation(ctx.Context(), knowledgeBases, memories, ctx.Logger, ctx.Locale(), ctx.Interaction.GuildID, ctx.Interaction.Member.User.ID, cacheItem)
+	ctx.Logger.Info("ChatGPT request invoked", "model", cacheItem.Model, "messages", len(cacheItem.Messages))
+	resp, err := queuedChatGPTRequest(ctx.Context(), requestQueue, ctx.Interaction.Member.User.ID, pendingMessagePosition(ctx.Session, ctx.Logger, ctx.Locale(), channelMessage), client, cacheItem, aug)
 	if err != nil {
 		// ChatGPT failed for whatever reason, tell users about it
-		log.Printf("[GID: %s, i.ID: %s] OpenAI request ChatCompletion failed with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("OpenAI request ChatCompletion failed", "error", err)
 		emptyString := ""
 		utils.DiscordChannelMessageEdit(ctx.Session, channelMessage.ID, channelMessage.ChannelID, &emptyString, &[]*discord.MessageEmbed{
 			{
-				Title:       "❌ OpenAI API failed",
+				Title:       ctx.T("error.openai"),
 				Description: err.Error(),
 				Color:       0xff0000,
 			},
-		})
-		return
+		}, discord.WithContext(ctx.Context()))
+		return false
 	}
 
-	// Unlock the thread at the end
-	defer utils.ToggleDiscordThreadLock(ctx.Session, thread.ID, false)
+	moderateCompletion(ctx.Context(), ctx.Session, ctx.Logger, ctx.Locale(), moderator, ctx.Interaction.GuildID, threadID, ctx.Interaction.Member.User.ID, cacheItem, resp)
 
-	go generateThreadTitleBasedOnInitialPrompt(ctx, client, thread.ID, cacheItem.Messages)
-
-	log.Printf("[GID: %s, i.ID: %s] ChatGPT Request [Model: %s] responded with a usage: [PromptTokens: %d, CompletionTokens: %d, TotalTokens: %d]\n", ctx.Interaction.GuildID, ctx.Interaction.ID, cacheItem.Model, resp.usage.PromptTokens, resp.usage.CompletionTokens, resp.usage.TotalTokens)
+	ctx.Logger.Info("ChatGPT request responded", "model", cacheItem.Model, "promptTokens", resp.usage.PromptTokens, "completionTokens", resp.usage.CompletionTokens, "totalTokens", resp.usage.TotalTokens)
 
 	messages := splitMessage(resp.content)
-	err = utils.DiscordChannelMessageEdit(ctx.Session, channelMessage.ID, channelMessage.ChannelID, &messages[0], nil)
+	err = utils.DiscordChannelMessageEdit(ctx.Session, channelMessage.ID, channelMessage.ChannelID, &messages[0], nil, discord.WithContext(ctx.Context()))
 	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] Discord API failed with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+		ctx.Logger.Error("Discord API failed", "error", err)
 		emptyString := ""
 		utils.DiscordChannelMessageEdit(ctx.Session, channelMessage.ID, channelMessage.ChannelID, &emptyString, &[]*discord.MessageEmbed{
 			{
-				Title:       "❌ Discord API Error",
+				Title:       ctx.T("error.discord"),
 				Description: err.Error(),
 				Color:       0xff0000,
 			},
-		})
-		return
+		}, discord.WithContext(ctx.Context()))
+		return true
 	}
 
 	if len(messages) > 1 {
 		// if there are more messages, send them as a thread reply
 		for _, message := range messages[1:] {
-			channelMessage, err = utils.DiscordChannelMessageSend(ctx.Session, thread.ID, message, nil)
+			channelMessage, err = utils.DiscordChannelMessageSend(ctx.Session, threadID, message, nil, discord.WithContext(ctx.Context()))
 			if err != nil {
-				log.Printf("[GID: %s, i.ID: %s] Discord API failed with the error: %v\n", ctx.Interaction.GuildID, ctx.Interaction.ID, err)
+				ctx.Logger.Error("Discord API failed", "error", err)
 			}
 		}
 	}
 
-	attachUsageInfo(ctx.Session, channelMessage, resp.usage, cacheItem.Model)
+	attachUsageInfo(ctx.Session, channelMessage, resp.usage, cacheItem.Model, aug.notes(ctx.Locale(), resp))
+	return true
 }
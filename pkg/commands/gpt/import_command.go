@@ -0,0 +1,52 @@
+package gpt
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+const importCommandName = "import"
+
+func ImportCommand(moderator *moderation.Moderator, settingsStore *settings.Store, completionModels []string, messagesCache *MessagesCache) *bot.Command {
+	temperatureOptionMinValue := 0.0
+	opts := []*discord.ApplicationCommandOption{
+		{
+			Type:                     discord.ApplicationCommandOptionAttachment,
+			Name:                     gptCommandOptionFile.String(),
+			Description:              i18n.T(i18n.Default, "gpt.import.option.file"),
+			DescriptionLocalizations: i18n.Localizations("gpt.import.option.file"),
+			Required:                 true,
+		},
+	}
+	if len(completionModels) > 1 {
+		opts = append(opts, &discord.ApplicationCommandOption{
+			Type:                     discord.ApplicationCommandOptionString,
+			Name:                     gptCommandOptionModel.String(),
+			Description:              i18n.T(i18n.Default, "gpt.import.option.model"),
+			DescriptionLocalizations: i18n.Localizations("gpt.import.option.model"),
+			Required:                 false,
+			Choices:                  modelChoices(completionModels),
+		})
+	}
+	opts = append(opts, &discord.ApplicationCommandOption{
+		Type:                     discord.ApplicationCommandOptionNumber,
+		Name:                     gptCommandOptionTemperature.String(),
+		Description:              i18n.T(i18n.Default, "gpt.import.option.temperature"),
+		DescriptionLocalizations: i18n.Localizations("gpt.import.option.temperature"),
+		MinValue:                 &temperatureOptionMinValue,
+		MaxValue:                 2.0,
+		Required:                 false,
+	})
+	return &bot.Command{
+		Name:                     importCommandName,
+		Description:              i18n.T(i18n.Default, "gpt.import.description"),
+		DescriptionLocalizations: i18n.Localizations("gpt.import.description"),
+		Options:                  opts,
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			chatImportHandler(ctx, moderator, settingsStore, completionModels, messagesCache)
+		}),
+	}
+}
@@ -0,0 +1,342 @@
+package gpt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/constants"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	gptImportFileMaxSize          = 1024 * 1024 // 1MB is way above any model context window
+	gptImportSummaryPreviewLength = 80
+	gptEmbedDescriptionMaxLength  = 4096
+	gptImportSummaryPrefix        = "📥 "
+)
+
+// conversationFile describes both the OpenAI chat messages format (either a bare array of messages
+// or a playground export object with `messages`) and the bot's own export format, which adds
+// `version` and `system` on top of the playground export
+type conversationFile struct {
+	Version     int                            `json:"version,omitempty"`
+	Model       string                         `json:"model,omitempty"`
+	Temperature *float32                       `json:"temperature,omitempty"`
+	System      string                         `json:"system,omitempty"`
+	Messages    []openai.ChatCompletionMessage `json:"messages"`
+}
+
+func parseConversationFile(data []byte) (*conversationFile, error) {
+	file := &conversationFile{}
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &file.Messages); err != nil {
+			return nil, fmt.Errorf("invalid messages array: %w", err)
+		}
+	} else if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid conversation file: %w", err)
+	}
+
+	if len(file.Messages) == 0 {
+		return nil, errors.New("conversation file has no messages")
+	}
+
+	for i := range file.Messages {
+		message := &file.Messages[i]
+		switch message.Role {
+		case openai.ChatMessageRoleSystem, openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant:
+		default:
+			return nil, fmt.Errorf("message #%d has unsupported role `%s`, only `system`, `user` and `assistant` are allowed", i+1, message.Role)
+		}
+
+		// Flatten multi-part content, only text parts are supported
+		if len(message.MultiContent) > 0 {
+			var parts []string
+			for _, part := range message.MultiContent {
+				if part.Type != openai.ChatMessagePartTypeText {
+					return nil, fmt.Errorf("message #%d has unsupported content part `%s`, only `text` is allowed", i+1, part.Type)
+				}
+				parts = append(parts, part.Text)
+			}
+			message.Content = strings.Join(parts, "\n")
+			message.MultiContent = nil
+		}
+
+		if strings.TrimSpace(message.Content) == "" {
+			return nil, fmt.Errorf("message #%d has empty content", i+1)
+		}
+	}
+
+	// Leading system messages become a conversation context, same as `context` option of the gpt command
+	var system []string
+	if file.System != "" {
+		system = append(system, file.System)
+	}
+	for len(file.Messages) > 0 && file.Messages[0].Role == openai.ChatMessageRoleSystem {
+		system = append(system, file.Messages[0].Content)
+		file.Messages = file.Messages[1:]
+	}
+	file.System = strings.Join(system, "\n")
+
+	if len(file.Messages) == 0 {
+		return nil, errors.New("conversation file has only system messages")
+	}
+
+	return file, nil
+}
+
+func importSummary(cacheItem *MessagesCacheData) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf(gptImportSummaryPrefix+"Imported `%d` messages (`%d` tokens) for model `%s`\n", len(cacheItem.Messages), cacheItem.TokenCount, cacheItem.Model))
+	footer := "Send a message in this thread to continue the conversation."
+	for i, message := range cacheItem.Messages {
+		content := truncateString(strings.Join(strings.Fields(message.Content), " "), gptImportSummaryPreviewLength)
+		line := fmt.Sprintf("> **%s:** %s\n", message.Role, content)
+		more := fmt.Sprintf("> *…and %d more*\n", len(cacheItem.Messages)-i)
+		if builder.Len()+len(line)+len(more)+len(footer) > discordMaxMessageLength {
+			builder.WriteString(more)
+			break
+		}
+		builder.WriteString(line)
+	}
+	builder.WriteString(footer)
+	return builder.String()
+}
+
+func truncateString(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+func chatImportFailed(ctx *bot.Context, title string, description string) {
+	ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+		Embeds: []*discord.MessageEmbed{
+			{
+				Title:       title,
+				Description: description,
+				Color:       0xff0000,
+			},
+		},
+	}, discord.WithContext(ctx.Context()))
+}
+
+func chatImportHandler(ctx *bot.Context, moderator *moderation.Moderator, settingsStore *settings.Store, completionModels []string, messagesCache *MessagesCache) {
+	ch, err := ctx.Session.State.Channel(ctx.Interaction.ChannelID)
+	if err == nil && ch.IsThread() {
+		// ignore interactions invoked in threads
+		ctx.Logger.Info("Interaction was invoked in the existing thread, ignoring")
+		return
+	}
+
+	guild := settingsStore.Get(ctx.Interaction.GuildID)
+	if !chatChannelAllowed(ctx, &guild) {
+		return
+	}
+
+	ctx.Logger.Info("Chat import interaction invoked")
+
+	err = ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+		return
+	}
+
+	option, ok := ctx.Options[gptCommandOptionFile.String()]
+	if !ok {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse file option")
+		chatImportFailed(ctx, ctx.T("error.title"), ctx.T("gpt.import.fileOption"))
+		return
+	}
+	attachment := ctx.Interaction.ApplicationCommandData().Resolved.Attachments[option.Value.(string)]
+	if attachment.Size > gptImportFileMaxSize {
+		chatImportFailed(ctx, ctx.T("gpt.import.failed"), ctx.T("gpt.import.tooBig", attachment.Filename, gptImportFileMaxSize))
+		return
+	}
+
+	data, err := getUrlData(ctx.Client, attachment.URL)
+	if err != nil {
+		ctx.Logger.Error("Failed to get import file data", "error", err)
+		chatImportFailed(ctx, ctx.T("error.attachment"), err.Error())
+		return
+	}
+
+	file, err := parseConversationFile([]byte(data))
+	if err != nil {
+		ctx.Logger.Error("Failed to parse import file", "error", err)
+		chatImportFailed(ctx, ctx.T("gpt.import.failed"), err.Error())
+		return
+	}
+
+	// Determine model, command option takes precedence over the file
+	model := guild.Model(completionModels, gptFallbackModel)
+	if option, ok := ctx.Options[gptCommandOptionModel.String()]; ok {
+		model = option.StringValue()
+	} else if file.Model != "" {
+		model = file.Model
+	}
+	if message := guildModelError(ctx.Locale(), &guild, completionModels, model); message != "" {
+		chatImportFailed(ctx, ctx.T("gpt.import.failed"), message)
+		return
+	}
+
+	cacheItem := &MessagesCacheData{
+		Messages:    file.Messages,
+		Model:       model,
+		Temperature: file.Temperature,
+	}
+	if option, ok := ctx.Options[gptCommandOptionTemperature.String()]; ok {
+		temp := float32(option.FloatValue())
+		cacheItem.Temperature = &temp
+	}
+	if cacheItem.Temperature != nil && (*cacheItem.Temperature < 0 || *cacheItem.Temperature > 2) {
+		chatImportFailed(ctx, ctx.T("gpt.import.failed"), ctx.T("gpt.import.temperature", *cacheItem.Temperature))
+		return
+	}
+	if file.System != "" {
+		cacheItem.SystemMessage = &openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: file.System,
+		}
+	}
+
+	if ok, count := isCacheItemWithinTruncateLimit(cacheItem); !ok {
+		truncateLimit := count
+		if limit := modelTruncateLimit(model); limit != nil {
+			truncateLimit = *limit
+		}
+		ctx.Logger.Info("Imported conversation exceeds token limit", "tokens", count, "limit", truncateLimit, "model", model)
+		chatImportFailed(ctx, ctx.T("gpt.import.failed"), ctx.T("gpt.import.tooLong", count, truncateLimit, model))
+		return
+	}
+
+	// Moderate everything that was written by the user
+	inputs := []string{file.System}
+	for _, message := range file.Messages {
+		if message.Role != openai.ChatMessageRoleAssistant {
+			inputs = append(inputs, message.Content)
+		}
+	}
+	moderationInput := strings.TrimSpace(strings.Join(inputs, "\n"))
+	verdict := moderator.CheckInput(ctx.Context(), ctx.Interaction.GuildID, moderationInput)
+	moderator.Report(ctx.Session, verdict, moderation.Report{
+		Kind:      "Chat import",
+		GuildID:   ctx.Interaction.GuildID,
+		ChannelID: ctx.Interaction.ChannelID,
+		UserID:    ctx.Interaction.Member.User.ID,
+		Content:   moderationInput,
+	})
+	if verdict.Blocked() {
+		ctx.Logger.Info("Imported conversation was flagged by moderation", "categories", verdict.Categories)
+		chatImportFailed(ctx, ctx.T("error.title"), verdict.Reason(ctx.Locale()))
+		return
+	}
+
+	// Starter message mirrors the one of the gpt command, so the thread can be reconstructed later.
+	// The first user message plays the role of the initial prompt
+	prompt := file.Messages[0].Content
+	for _, message := range file.Messages {
+		if message.Role == openai.ChatMessageRoleUser {
+			prompt = message.Content
+			break
+		}
+	}
+	fields := []*discord.MessageEmbedField{
+		{
+			Value: "\u200B",
+		},
+	}
+	if file.System != "" {
+		if len(file.System) < gptContextOptionMaxLength {
+			fields = append(fields, &discord.MessageEmbedField{
+				Name:  gptCommandOptionContext.humanReadableString(),
+				Value: file.System,
+			})
+		} else {
+			ctx.Logger.Info("Imported system message is above characters limit and will not be shown in the thread", "limit", gptContextOptionMaxLength)
+		}
+	}
+	fields = append(fields, &discord.MessageEmbedField{
+		Name:  gptCommandOptionModel.humanReadableString(),
+		Value: model,
+	})
+	if cacheItem.Temperature != nil {
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  gptCommandOptionTemperature.humanReadableString(),
+			Value: fmt.Sprintf("%g", *cacheItem.Temperature),
+		})
+	}
+	fields = append(fields, &discord.MessageEmbedField{
+		Name:  gptCommandOptionFile.humanReadableString(),
+		Value: attachment.Filename,
+	})
+
+	metadata := newThreadMetadata(prompt, cacheItem)
+	metadata.History = cacheItem.Messages
+	_, err = ctx.FollowupMessageCreate(ctx.Interaction, true, &discord.WebhookParams{
+		Embeds: []*discord.MessageEmbed{
+			{
+				Description: truncateString(prompt, gptEmbedDescriptionMaxLength),
+				Color:       gptInteractionEmbedColor,
+				Author: &discord.MessageEmbedAuthor{
+					Name:         ctx.T("gpt.import.author", ctx.Interaction.Member.User.Username),
+					IconURL:      ctx.Interaction.Member.User.AvatarURL("32"),
+					ProxyIconURL: constants.OpenAIBlackIconURL,
+				},
+				Fields: fields,
+			},
+		},
+		Files: []*discord.File{metadata.file()},
+	}, discord.WithContext(ctx.Context()))
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+		chatImportFailed(ctx, ctx.T("error.command"), err.Error())
+		return
+	}
+
+	m, err := ctx.Response()
+	if err != nil {
+		ctx.Logger.Error("Failed to get interaction reference", "error", err)
+		ctx.Edit(ctx.T("gpt.interactionReference", err))
+		return
+	}
+
+	thread, err := ctx.Session.MessageThreadStartComplex(m.ChannelID, m.ID, &discord.ThreadStart{
+		Name:                truncateString(strings.TrimSuffix(attachment.Filename, ".json"), 100),
+		AutoArchiveDuration: guild.ThreadArchiveDuration(),
+		Invitable:           false,
+	}, discord.WithContext(ctx.Context()))
+	if err != nil {
+		ctx.Logger.Error("Failed to create a thread", "error", err)
+		return
+	}
+	ctx.Logger = ctx.Logger.With("thread", thread.ID)
+
+	// add user to the thread
+	ctx.ThreadMemberAdd(thread.ID, ctx.Interaction.Member.User.ID, discord.WithContext(ctx.Context()))
+
+	messagesCache.Add(thread.ID, cacheItem)
+
+	ctx.Logger.Info("Imported conversation into a thread", "model", cacheItem.Model, "messages", len(cacheItem.Messages), "tokens", cacheItem.TokenCount)
+
+	if verdict.Warned() {
+		utils.DiscordChannelMessageSend(ctx.Session, thread.ID, verdict.Warning(ctx.Locale()), nil, discord.WithContext(ctx.Context()))
+	}
+
+	_, err = utils.DiscordChannelMessageSend(ctx.Session, thread.ID, importSummary(cacheItem), nil, discord.WithContext(ctx.Context()))
+	if err != nil {
+		ctx.Logger.Error("Discord API failed", "error", err)
+	}
+}
@@ -0,0 +1,76 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Sources listed under an answer must fit into embed description along with the footer
+const gptSourcesMaxLength = 2048
+
+// knowledge is what was retrieved from the knowledge base of a conversation for its last message
+type knowledge struct {
+	// message with excerpts sent along with the conversation, nil if there are none
+	message *openai.ChatCompletionMessage
+	// sources cited by the answer, or why the knowledge base was not searched
+	sources string
+}
+
+// retrieveKnowledge searches the knowledge base of the conversation for its last user message. Conversations
+// without knowledge base get nothing
+func retrieveKnowledge(ctx context.Context, knowledgeBases *kb.Store, logger *slog.Logger, locale discord.Locale, guildID string, cacheItem *MessagesCacheData) knowledge {
+	if cacheItem.KnowledgeBase == "" {
+		return knowledge{}
+	}
+	if knowledgeBases == nil {
+		return knowledge{sources: i18n.T(locale, "gpt.kb.unavailable", cacheItem.KnowledgeBase, "knowledge bases are disabled")}
+	}
+	var query string
+	for i := len(cacheItem.Messages) - 1; i >= 0; i-- {
+		if cacheItem.Messages[i].Role == openai.ChatMessageRoleUser {
+			query = cacheItem.Messages[i].Content
+			break
+		}
+	}
+	if query == "" {
+		return knowledge{}
+	}
+
+	results, err := knowledgeBases.Search(ctx, guildID, cacheItem.KnowledgeBase, query)
+	if err != nil {
+		logger.Error("Failed to search knowledge base", "kb", cacheItem.KnowledgeBase, "error", err)
+		return knowledge{sources: i18n.T(locale, "gpt.kb.unavailable", cacheItem.KnowledgeBase, err.Error())}
+	}
+	logger.Debug("Knowledge base searched", "kb", cacheItem.KnowledgeBase, "results", len(results))
+	if len(results) == 0 {
+		return knowledge{}
+	}
+
+	var prompt, sources strings.Builder
+	prompt.WriteString("Answer using the excerpts from the knowledge base below when they are relevant. " +
+		"Cite excerpts you use by their number in square brackets, e.g. [1]. " +
+		"If the excerpts do not answer the question, say so before answering from general knowledge.\n")
+	sources.WriteString(i18n.T(locale, "gpt.kb.sources", cacheItem.KnowledgeBase))
+	for i, result := range results {
+		source := fmt.Sprintf("[%d] %s, chunk %d", i+1, result.Document, result.Chunk)
+		fmt.Fprintf(&prompt, "\n%s:\n%s\n", source, result.Text)
+		line := fmt.Sprintf("\n[%d] `%s` #%d", i+1, result.Document, result.Chunk)
+		if sources.Len()+len(line) <= gptSourcesMaxLength {
+			sources.WriteString(line)
+		}
+	}
+	return knowledge{
+		message: &openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: prompt.String(),
+		},
+		sources: sources.String(),
+	}
+}
@@ -0,0 +1,66 @@
+package gpt
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/sashabaranov/go-openai"
+)
+
+// gptRememberTool lets the model save facts about users who enabled learning with /memory learn
+var gptRememberTool = openai.Tool{
+	Type: openai.ToolTypeFunction,
+	Function: &openai.FunctionDefinition{
+		Name: "remember",
+		Description: "Remember a lasting fact about the user for their future conversations, such as their stack, " +
+			"preferences or project details. Use it when the user asks you to remember something, or shares " +
+			"something they would otherwise have to repeat in every conversation",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"fact": {
+					"type": "string",
+					"description": "The fact in a short sentence, e.g. \"Uses Go with PostgreSQL\""
+				}
+			},
+			"required": ["fact"]
+		}`),
+	},
+}
+
+func rememberArguments(arguments string) (string, error) {
+	var args struct {
+		Fact string `json:"fact"`
+	}
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", err
+	}
+	return args.Fact, nil
+}
+
+// memoryPrompt lists facts about the user who started the conversation within the token budget
+func memoryPrompt(memories *memory.Store, cacheItem *MessagesCacheData) string {
+	if cacheItem.MemoryUserID == "" {
+		return ""
+	}
+	return memories.Prompt(cacheItem.MemoryUserID, func(s string) int {
+		return *countMessageTokens(openai.ChatCompletionMessage{Content: s}, cacheItem.Model) - tokensPerMessage
+	})
+}
+
+// rememberFunc saves facts the model learns about the user, nil if the user did not let it learn
+func rememberFunc(memories *memory.Store, logger *slog.Logger, userID string) func(fact string) error {
+	if memories == nil || !memories.Get(userID).Learn {
+		return nil
+	}
+	return func(fact string) error {
+		_, err := memories.Add(userID, fact, true)
+		if err != nil {
+			logger.Info("Failed to remember fact", "error", err)
+			return err
+		}
+		logger.Info("Fact about the user remembered")
+		return nil
+	}
+}
@@ -1,11 +1,21 @@
 package gpt
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
 	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
 	"github.com/sashabaranov/go-openai"
 )
@@ -16,9 +26,13 @@ const (
 
 	gptEmojiAck = "⌛"
 	gptEmojiErr = "❌"
+	// gptEmojiBlocked marks messages blocked by moderation, they are skipped when the conversation is rebuilt
+	gptEmojiBlocked = "🚫"
 )
 
-func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, messagesCache *MessagesCache, ignoredChannelsCache *IgnoredChannelsCache) {
+var errThreadMessages = errors.New("failed to get thread messages, reached max retries")
+
+func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, settingsStore *settings.Store, completionModels []string, messagesCache *MessagesCache, ignoredChannelsCache *IgnoredChannelsCache, knowledgeBases *kb.Store, memories *memory.Store) {
 	if !shouldHandleMessageType(ctx.Message.Type) {
 		// ignore message types that should not be handled by this command
 		return
@@ -29,7 +43,7 @@ func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, messa
 		return
 	}
 
-	if _, exists := (*ignoredChannelsCache)[ctx.Message.ChannelID]; exists {
+	if ignoredChannelsCache.Contains(ctx.Message.ChannelID) {
 		// skip over ignored channels list
 		return
 	}
@@ -41,126 +55,44 @@ func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, messa
 
 	ch, err := ctx.Session.State.Channel(ctx.Message.ChannelID)
 	if err != nil {
-		log.Printf("[GID: %s, CHID: %s, MID: %s] Failed to get channel info with the error: %v\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID, err)
+		ctx.Logger.Error("Failed to get channel info", "error", err)
 		return
 	}
 
 	if !ch.IsThread() {
 		// ignore non threads
-		(*ignoredChannelsCache)[ctx.Message.ChannelID] = struct{}{}
+		ignoredChannelsCache.Add(ctx.Message.ChannelID)
 		return
 	}
 
 	if ch.ThreadMetadata != nil && (ch.ThreadMetadata.Locked || ch.ThreadMetadata.Archived) {
 		// We don't want to handle messages in locked or archived threads
-		log.Printf("[GID: %s, CHID: %s, MID: %s] Ignoring new message in a potential thread as it is locked or/and archived\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID)
+		ctx.Logger.Info("Ignoring new message in a potential thread as it is locked or/and archived")
 		return
 	}
 
-	log.Printf("[GID: %s, CHID: %s, MID: %s] Handling new message in a potential GPT thread\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID)
+	ctx.Logger.Info("Handling new message in a potential GPT thread")
 
 	cacheItem, ok := messagesCache.Get(ctx.Message.ChannelID)
 	if !ok {
-		isGPTThread := true
-		cacheItem = &MessagesCacheData{}
-
-		var lastID string
-		retries := 0
-		for {
-			if retries >= gptDiscordChannelMessagesRequestMaxRetries {
-				// max retries reached
-				break
-			}
-			// Get messages in batches of 100 (maximum allowed by Discord API)
-			batch, err := ctx.Session.ChannelMessages(ch.ID, 100, lastID, "", "")
-			if err != nil {
-				// Since we cannot fetch messages, that means we cannot determine whether this a GPT thread,
-				// and if it was, we cannot get the full context to provide a better user experience. Do retries
-				// and print the error in the log
-				log.Printf("[GID: %s, CHID: %s, MID: %s] Failed to get channel messages with the error: %v. Retries left: %d\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID, err, (gptDiscordChannelMessagesRequestMaxRetries - retries))
-				retries++
-				continue
-			}
-
-			transformed := make([]openai.ChatCompletionMessage, 0, len(batch))
-			for _, value := range batch {
-				role := openai.ChatMessageRoleUser
-				if value.Author.ID == ctx.Session.State.User.ID {
-					role = openai.ChatMessageRoleAssistant
-				}
-				content := value.Content
-				// First message is always a referenced message
-				// Check if it is, and then modify to get the original prompt
-				if value.Type == discord.MessageTypeThreadStarterMessage {
-					if value.Author.ID != ctx.Session.State.User.ID || value.ReferencedMessage == nil {
-						// this is not gpt thread, ignore
-						isGPTThread = false
-						break
-					}
-					role = openai.ChatMessageRoleUser
-
-					prompt, context, model, temperature := parseInteractionReply(value.ReferencedMessage)
-					if prompt == "" {
-						isGPTThread = false
-						break
-					}
-					content = prompt
-					var systemMessage *openai.ChatCompletionMessage
-					if context != "" {
-						context, _ = getContentOrURLData(ctx.Client, context)
-						systemMessage = &openai.ChatCompletionMessage{
-							Role:    openai.ChatMessageRoleSystem,
-							Content: context,
-						}
-					}
-					if model == "" {
-						model = gptDefaultModel
-					}
-					if temperature != nil {
-						cacheItem.Temperature = temperature
-					}
-
-					cacheItem.SystemMessage = systemMessage
-					cacheItem.Model = model
-				} else if !shouldHandleMessageType(value.Type) {
-					// ignore message types that are
-					// not related to conversation
-					continue
-				}
-				transformed = append(transformed, openai.ChatCompletionMessage{
-					Role:    role,
-					Content: content,
-				})
-			}
-
-			reverseMessages(&transformed)
-
-			// Add the messages to the beginning of the main list
-			cacheItem.Messages = append(transformed, cacheItem.Messages...)
-
-			// If there are no more messages in the thread, break the loop
-			if len(batch) == 0 {
-				break
-			}
-
-			// Set the lastID to the last message's ID to get the next batch of messages
-			lastID = batch[len(batch)-1].ID
-		}
-
-		if retries >= gptDiscordChannelMessagesRequestMaxRetries {
-			// max retries reached on fetching messages
-			log.Printf("[GID: %s, CHID: %s, MID: %s] Failed to get channel messages. Reached max retries\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID)
+		var isGPTThread bool
+		cacheItem, isGPTThread, err = threadConversation(ctx.Context(), ctx.Session, ctx.Logger, ch, settingsStore, completionModels)
+		if err != nil {
+			ctx.Logger.Error("Failed to get channel messages. Reached max retries")
+			monitoring.ThreadReconstructed("failed")
 			return
 		}
 
 		if !isGPTThread {
 			// this was not a GPT thread
-			log.Printf("[GID: %s, CHID: %s, MID: %s] Not a GPT thread, saving to ignored cache to skip over it later\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID)
+			ctx.Logger.Info("Not a GPT thread, saving to ignored cache to skip over it later")
 			// save threadID to ignored cache, so we can always ignore it later
-			(*ignoredChannelsCache)[ctx.Message.ChannelID] = struct{}{}
+			ignoredChannelsCache.Add(ctx.Message.ChannelID)
+			monitoring.ThreadReconstructed("ignored")
 			return
 		}
 
+		monitoring.ThreadReconstructed("chat")
 		messagesCache.Add(ctx.Message.ChannelID, cacheItem)
 	} else {
 		cacheItem.Messages = append(cacheItem.Messages, openai.ChatCompletionMessage{
@@ -169,21 +101,59 @@ func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, messa
 		})
 	}
 
+	verdict := moderator.CheckInput(ctx.Context(), ctx.Message.GuildID, ctx.Message.Content)
+	moderator.Report(ctx.Session, verdict, moderation.Report{
+		Kind:      "Chat message",
+		GuildID:   ctx.Message.GuildID,
+		ChannelID: ctx.Message.ChannelID,
+		UserID:    ctx.Message.Author.ID,
+		Content:   ctx.Message.Content,
+	})
+	if verdict.Blocked() {
+		ctx.Logger.Info("Message was flagged by moderation", "categories", verdict.Categories)
+		// flagged message must not become a part of the conversation
+		if n := len(cacheItem.Messages); n > 0 && cacheItem.Messages[n-1].Content == ctx.Message.Content {
+			cacheItem.Messages = cacheItem.Messages[:n-1]
+		}
+		ctx.AddReaction(gptEmojiBlocked)
+		ctx.EmbedReply(&discord.MessageEmbed{
+			Title:       ctx.T("error.title"),
+			Description: verdict.Reason(ctx.Locale()),
+			Color:       0xff0000,
+		})
+		return
+	}
+	if verdict.Warned() {
+		ctx.Reply(verdict.Warning(ctx.Locale()))
+	}
+
 	// check if current message cache is within allowed token limit
 	if ok, count := isCacheItemWithinTruncateLimit(cacheItem); !ok {
-		log.Printf("[GID: %s, CHID: %s, MID: %s] Current thread cache token count of %d exceeds truncate limit. Performing adjustments.\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID, count)
+		ctx.Logger.Info("Thread cache token count exceeds truncate limit, performing adjustments", "tokens", count)
 		adjustMessageTokens(cacheItem)
-		log.Printf("[GID: %s, CHID: %s, MID: %s] Tokens adjustments finished. Current cache tokens: %d\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID, cacheItem.TokenCount)
+		ctx.Logger.Info("Tokens adjustments finished", "tokens", cacheItem.TokenCount)
 	}
 
 	// Lock the thread while we are generating ChatGPT answser
-	utils.ToggleDiscordThreadLock(ctx.Session, ctx.Message.ChannelID, true)
+	utils.ToggleDiscordThreadLock(ctx.Session, ctx.Message.ChannelID, true, discord.WithContext(ctx.Context()))
 	// Unlock the thread at the end
-	defer utils.ToggleDiscordThreadLock(ctx.Session, ctx.Message.ChannelID, false)
+	defer utils.ToggleDiscordThreadLock(ctx.Session, ctx.Message.ChannelID, false, discord.WithContext(ctx.Context()))
 
 	ctx.AddReaction(gptEmojiAck)
 	defer ctx.RemoveReaction(gptEmojiAck)
 
+	// If the bot shuts down before answering, tell the user to retry and do not leave the thread locked
+	removeInterrupt := ctx.OnInterrupt(func() {
+		ctx.Session.MessageReactionsRemoveEmoji(ctx.Message.ChannelID, ctx.Message.ID, gptEmojiAck)
+		ctx.Session.ChannelMessageSendComplex(ctx.Message.ChannelID, &discord.MessageSend{
+			Content:    ctx.T("bot.interrupted"),
+			Components: retryRow(ctx.Locale(), ctx.Path),
+			Reference:  ctx.Message.Reference(),
+		})
+		utils.ToggleDiscordThreadLock(ctx.Session, ctx.Message.ChannelID, false)
+	})
+	defer removeInterrupt()
+
 	// Create a ticker and a channel for signaling request completion
 	// Discord stops showing typing indicator after 10 seconds, so we
 	// need to send it again
@@ -202,36 +172,40 @@ func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, messa
 		}
 	}()
 
-	log.Printf("[GID: %s, CHID: %s] ChatGPT Request invoked with [Model: %s]. Current cache size: %v\n", ctx.Message.GuildID, ctx.Message.ChannelID, cacheItem.Model, len(cacheItem.Messages))
+	aug := augmentConversation(ctx.Context(), knowledgeBases, memories, ctx.Logger, ctx.Locale(), ctx.Message.GuildID, ctx.Message.Author.ID, cacheItem)
+
+	ctx.Logger.Info("ChatGPT request invoked", "model", cacheItem.Model, "messages", len(cacheItem.Messages))
 
-	resp, err := sendChatGPTRequest(client, cacheItem)
+	resp, err := queuedChatGPTRequest(ctx.Context(), requestQueue, ctx.Message.Author.ID, replyPosition(ctx.Session, ctx.Logger, ctx.Locale(), ctx.Message), client, cacheItem, aug)
 
 	// Signal the typing ticker to stop
 	done <- true
 
 	if err != nil {
 		// ChatGPT failed for whatever reason, tell users about it
-		log.Printf("[GID: %s, CHID: %s] ChatGPT request ChatCompletion failed with the error: %v\n", ctx.Message.GuildID, ctx.Message.ChannelID, err)
+		ctx.Logger.Error("ChatGPT request ChatCompletion failed", "error", err)
 		ctx.AddReaction(gptEmojiErr)
 		ctx.EmbedReply(&discord.MessageEmbed{
-			Title:       "❌ OpenAI API failed",
+			Title:       ctx.T("error.openai"),
 			Description: err.Error(),
 			Color:       0xff0000,
 		})
 		return
 	}
 
-	log.Printf("[GID: %s, CHID: %s] ChatGPT Request [Model: %s] responded with a usage: [PromptTokens: %d, CompletionTokens: %d, TotalTokens: %d]\n", ctx.Message.GuildID, ctx.Message.ChannelID, cacheItem.Model, resp.usage.PromptTokens, resp.usage.CompletionTokens, resp.usage.TotalTokens)
+	ctx.Logger.Info("ChatGPT request responded", "model", cacheItem.Model, "promptTokens", resp.usage.PromptTokens, "completionTokens", resp.usage.CompletionTokens, "totalTokens", resp.usage.TotalTokens)
+
+	moderateCompletion(ctx.Context(), ctx.Session, ctx.Logger, ctx.Locale(), moderator, ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.Author.ID, cacheItem, resp)
 
 	messages := splitMessage(resp.content)
 	var replyMessage *discord.Message
 	for _, message := range messages {
 		replyMessage, err = ctx.Reply(message)
 		if err != nil {
-			log.Printf("[GID: %s, CHID: %s, MID: %s] Failed to reply in the thread with the error: %v\n", ctx.Message.GuildID, ctx.Message.ChannelID, ctx.Message.ID, err)
+			ctx.Logger.Error("Failed to reply in the thread", "error", err)
 			ctx.AddReaction(gptEmojiErr)
 			ctx.EmbedReply(&discord.MessageEmbed{
-				Title:       "❌ Discord API Error",
+				Title:       ctx.T("error.discord"),
 				Description: err.Error(),
 				Color:       0xff0000,
 			})
@@ -239,5 +213,125 @@ func chatGPTMessageHandler(ctx *bot.MessageContext, client *openai.Client, messa
 		}
 	}
 
-	attachUsageInfo(ctx.Session, replyMessage, resp.usage, cacheItem.Model)
+	attachUsageInfo(ctx.Session, replyMessage, resp.usage, cacheItem.Model, aug.notes(ctx.Locale(), resp))
+}
+
+// threadConversation rebuilds the conversation from messages of the thread. Threads that were not started
+// by the chat command are reported with isGPTThread false. Fails if messages cannot be fetched after retries
+func threadConversation(ctx context.Context, s *discord.Session, logger *slog.Logger, ch *discord.Channel, settingsStore *settings.Store, completionModels []string) (cacheItem *MessagesCacheData, isGPTThread bool, err error) {
+	isGPTThread = true
+	cacheItem = &MessagesCacheData{}
+
+	var lastID string
+	retries := 0
+	for {
+		if retries >= gptDiscordChannelMessagesRequestMaxRetries {
+			// max retries reached
+			break
+		}
+		// Get messages in batches of 100 (maximum allowed by Discord API)
+		batch, err := s.ChannelMessages(ch.ID, 100, lastID, "", "", discord.WithContext(ctx))
+		if err != nil {
+			// Since we cannot fetch messages, that means we cannot determine whether this a GPT thread,
+			// and if it was, we cannot get the full context to provide a better user experience. Do retries
+			// and print the error in the log
+			logger.Error("Failed to get channel messages", "error", err, "retriesLeft", gptDiscordChannelMessagesRequestMaxRetries-retries)
+			retries++
+			continue
+		}
+
+		transformed := make([]openai.ChatCompletionMessage, 0, len(batch))
+		for _, value := range batch {
+			role := openai.ChatMessageRoleUser
+			if value.Author.ID == s.State.User.ID {
+				role = openai.ChatMessageRoleAssistant
+			}
+			content := value.Content
+			// First message is always a referenced message
+			// Check if it is, and then modify to get the original prompt
+			if value.Type == discord.MessageTypeThreadStarterMessage {
+				if value.Author.ID != s.State.User.ID || value.ReferencedMessage == nil {
+					// this is not gpt thread, ignore
+					isGPTThread = false
+					break
+				}
+				role = openai.ChatMessageRoleUser
+
+				metadata, err := readThreadMetadata(s.Client, value.ReferencedMessage)
+				if err != nil {
+					logger.Error("Failed to read thread metadata, parsing the embed instead", "error", err)
+					metadata = legacyThreadMetadata(s.Client, value.ReferencedMessage)
+				}
+				if metadata == nil {
+					isGPTThread = false
+					break
+				}
+				content = metadata.Prompt
+				if metadata.Context != "" {
+					cacheItem.SystemMessage = &openai.ChatCompletionMessage{
+						Role:    openai.ChatMessageRoleSystem,
+						Content: metadata.Context,
+					}
+				}
+				cacheItem.Model = metadata.Model
+				if cacheItem.Model == "" {
+					guild := settingsStore.Get(ch.GuildID)
+					cacheItem.Model = guild.Model(completionModels, gptFallbackModel)
+				}
+				cacheItem.Temperature = metadata.Temperature
+				cacheItem.KnowledgeBase = metadata.KnowledgeBase
+				cacheItem.MemoryUserID = metadata.Memory
+				if len(metadata.History) > 0 {
+					// imported conversation takes the place of the prompt, newest first like messages of the batch
+					for i := len(metadata.History) - 1; i >= 0; i-- {
+						transformed = append(transformed, metadata.History[i])
+					}
+					continue
+				}
+			} else if !shouldHandleMessageType(value.Type) || isStatusMessage(s, value) || hasOwnReaction(value, gptEmojiBlocked) {
+				// ignore message types that are not related
+				// to conversation and messages it never had
+				continue
+			} else if role == openai.ChatMessageRoleAssistant {
+				if content = withoutModerationNotice(content); content == "" {
+					// withheld completions, moderation warnings
+					// and error embeds are not a part of it either
+					continue
+				}
+			}
+			transformed = append(transformed, openai.ChatCompletionMessage{
+				Role:    role,
+				Content: content,
+			})
+		}
+
+		reverseMessages(&transformed)
+
+		// Add the messages to the beginning of the main list
+		cacheItem.Messages = append(transformed, cacheItem.Messages...)
+
+		// If there are no more messages in the thread, break the loop
+		if len(batch) == 0 {
+			break
+		}
+
+		// Set the lastID to the last message's ID to get the next batch of messages
+		lastID = batch[len(batch)-1].ID
+	}
+
+	if retries >= gptDiscordChannelMessagesRequestMaxRetries {
+		// max retries reached on fetching messages
+		return nil, false, errThreadMessages
+	}
+	return cacheItem, isGPTThread, nil
+}
+
+// isStatusMessage reports whether the message is a progress or error notice of the bot, not a part of the conversation
+func isStatusMessage(s *discord.Session, m *discord.Message) bool {
+	if m.Author == nil || m.Author.ID != s.State.User.ID {
+		return false
+	}
+	return i18n.Matches("gpt.pending", m.Content) || i18n.Matches("bot.interrupted", m.Content) ||
+		i18n.Matches("gpt.recovered", m.Content) || i18n.Matches("queue.position", m.Content) ||
+		strings.HasPrefix(m.Content, gptImportSummaryPrefix)
 }
@@ -0,0 +1,120 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// threadMetadataVersion is the version of threadMetadata written by this build. Bump it when the meaning
+	// of a field changes, readers fall back to the embed for versions they don't know
+	threadMetadataVersion  = 1
+	threadMetadataFilename = "conversation.json"
+	threadMetadataMaxSize  = 8 << 20
+)
+
+// threadMetadata is everything needed to rebuild the conversation of a thread besides its messages. It is
+// attached as a JSON file to the message the thread is started from, so it survives restarts and is not
+// affected by how the embed of the message is worded or localized. Context is stored as is, files included,
+// since attachment URLs expire
+type threadMetadata struct {
+	Version     int      `json:"v"`
+	Prompt      string   `json:"prompt"`
+	Model       string   `json:"model"`
+	Temperature *float32 `json:"temperature,omitempty"`
+	Context     string   `json:"context,omitempty"`
+	// KnowledgeBase is searched for every message of the conversation
+	KnowledgeBase string `json:"kb,omitempty"`
+	// Memory is ID of the user whose memory is added to the system message
+	Memory string `json:"memory,omitempty"`
+	// History is the imported conversation the thread continues, the prompt is a part of it then
+	History []openai.ChatCompletionMessage `json:"history,omitempty"`
+}
+
+func newThreadMetadata(prompt string, cacheItem *MessagesCacheData) *threadMetadata {
+	metadata := &threadMetadata{
+		Version:     threadMetadataVersion,
+		Prompt:      prompt,
+		Model:       cacheItem.Model,
+		Temperature: cacheItem.Temperature,
+
+		KnowledgeBase: cacheItem.KnowledgeBase,
+		Memory:        cacheItem.MemoryUserID,
+	}
+	if cacheItem.SystemMessage != nil {
+		metadata.Context = cacheItem.SystemMessage.Content
+	}
+	return metadata
+}
+
+// file makes the attachment of the message the thread is started from
+func (metadata *threadMetadata) file() *discord.File {
+	data, _ := json.Marshal(metadata)
+	return &discord.File{
+		Name:        threadMetadataFilename,
+		ContentType: "application/json",
+		Reader:      bytes.NewReader(data),
+	}
+}
+
+// readThreadMetadata reads metadata of the conversation from the message the thread was started from. Messages
+// without the metadata file, or with a version this build does not know, are parsed from their embed the way
+// threads were started before. Returns nil if the message does not start a chat thread
+func readThreadMetadata(client *http.Client, m *discord.Message) (*threadMetadata, error) {
+	for _, attachment := range m.Attachments {
+		if attachment.Filename != threadMetadataFilename {
+			continue
+		}
+		metadata, err := downloadThreadMetadata(client, attachment.URL)
+		if err != nil {
+			return nil, err
+		}
+		if metadata.Version <= threadMetadataVersion {
+			return metadata, nil
+		}
+		break
+	}
+	return legacyThreadMetadata(client, m), nil
+}
+
+func downloadThreadMetadata(client *http.Client, url string) (*threadMetadata, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", threadMetadataFilename, res.Status)
+	}
+
+	var metadata threadMetadata
+	if err := json.NewDecoder(io.LimitReader(res.Body, threadMetadataMaxSize)).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", threadMetadataFilename, err)
+	}
+	return &metadata, nil
+}
+
+// legacyThreadMetadata parses the embed of the message. Context files are downloaded again, if their
+// URLs have not expired yet
+func legacyThreadMetadata(client *http.Client, m *discord.Message) *threadMetadata {
+	prompt, context, model, temperature := parseInteractionReply(m)
+	if prompt == "" {
+		return nil
+	}
+	if utils.IsURL(context) {
+		context, _ = getContentOrURLData(client, context)
+	}
+	return &threadMetadata{
+		Prompt:      prompt,
+		Model:       model,
+		Temperature: temperature,
+		Context:     context,
+	}
+}
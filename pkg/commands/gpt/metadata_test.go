@@ -0,0 +1,94 @@
+package gpt
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestReadThreadMetadata(t *testing.T) {
+	temperature := float32(0.5)
+	current := &threadMetadata{
+		Version:     threadMetadataVersion,
+		Prompt:      "Hello",
+		Model:       openai.GPT4o,
+		Temperature: &temperature,
+		Context:     "Contents of the context file",
+	}
+	legacyEmbeds := []*discord.MessageEmbed{
+		{
+			Description: "Hello from the embed",
+			Fields: []*discord.MessageEmbedField{
+				{Value: "\u200B"},
+				{Name: gptCommandOptionContext.humanReadableString(), Value: "Be brief"},
+				{Name: gptCommandOptionModel.humanReadableString(), Value: openai.GPT4Turbo},
+				{Name: gptCommandOptionTemperature.humanReadableString(), Value: "0.5"},
+			},
+		},
+	}
+	legacy := &threadMetadata{Prompt: "Hello from the embed", Model: openai.GPT4Turbo, Temperature: &temperature, Context: "Be brief"}
+
+	files := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, data)
+	}))
+	defer server.Close()
+	attach := func(name string, data string) []*discord.MessageAttachment {
+		files["/"+name] = data
+		return []*discord.MessageAttachment{{Filename: threadMetadataFilename, URL: server.URL + "/" + name}}
+	}
+	currentData, _ := io.ReadAll(current.file().Reader)
+
+	tests := []struct {
+		name    string
+		message *discord.Message
+		want    *threadMetadata
+		wantErr bool
+	}{
+		{
+			name:    "metadata file",
+			message: &discord.Message{Embeds: legacyEmbeds, Attachments: attach("current", string(currentData))},
+			want:    current,
+		},
+		{
+			name:    "legacy embed",
+			message: &discord.Message{Embeds: legacyEmbeds},
+			want:    legacy,
+		},
+		{
+			name:    "unknown version",
+			message: &discord.Message{Embeds: legacyEmbeds, Attachments: attach("future", `{"v":99,"prompt":"Hello"}`)},
+			want:    legacy,
+		},
+		{
+			name:    "missing metadata file",
+			message: &discord.Message{Attachments: []*discord.MessageAttachment{{Filename: threadMetadataFilename, URL: server.URL + "/missing"}}},
+			wantErr: true,
+		},
+		{
+			name:    "not a chat thread",
+			message: &discord.Message{Content: "Hello"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readThreadMetadata(server.Client(), tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
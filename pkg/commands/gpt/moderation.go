@@ -0,0 +1,50 @@
+package gpt
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+)
+
+// moderateCompletion checks model output if guild policy asks for it. Blocked completions are replaced
+// with a notice and removed from the conversation, so they never reach the model again
+func moderateCompletion(ctx context.Context, s *discord.Session, logger *slog.Logger, locale discord.Locale, moderator *moderation.Moderator, guildID string, channelID string, userID string, cacheItem *MessagesCacheData, resp *chatGPTResponse) {
+	verdict := moderator.CheckOutput(ctx, guildID, resp.content)
+	if !verdict.Flagged {
+		return
+	}
+
+	logger.Info("ChatGPT completion was flagged by moderation", "action", verdict.Action, "categories", verdict.Categories)
+	moderator.Report(s, verdict, moderation.Report{
+		Kind:      "Chat completion",
+		GuildID:   guildID,
+		ChannelID: channelID,
+		UserID:    userID,
+		Content:   resp.content,
+	})
+
+	switch {
+	case verdict.Blocked():
+		cacheItem.Messages = cacheItem.Messages[:len(cacheItem.Messages)-1]
+		resp.content = i18n.T(locale, "gpt.withheld") + "\n> " + verdict.Reason(locale)
+	case verdict.Warned():
+		resp.content = verdict.Warning(locale) + "\n\n" + resp.content
+	}
+}
+
+// withoutModerationNotice returns the bot message the way its completion is in the conversation. Moderation
+// warnings are cut off, withheld completions and warnings sent on their own have nothing left
+func withoutModerationNotice(content string) string {
+	if notice, _, _ := strings.Cut(content, "\n"); i18n.Matches("gpt.withheld", notice) {
+		return ""
+	}
+	if moderation.IsWarning(content) {
+		_, completion, _ := strings.Cut(content, "\n\n")
+		return completion
+	}
+	return content
+}
@@ -0,0 +1,64 @@
+package gpt
+
+import (
+	"context"
+	"log/slog"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
+	"github.com/sashabaranov/go-openai"
+)
+
+// queuedChatGPTRequest sends the request when its turn in the queue comes. Requests with few prompt tokens
+// are short and skip ahead of long ones
+func queuedChatGPTRequest(ctx context.Context, requestQueue *queue.Queue, userID string, onPosition func(position int), client *openai.Client, cacheItem *MessagesCacheData, aug augmentation) (resp *chatGPTResponse, err error) {
+	tokens := countAllMessagesTokens(cacheItem.SystemMessage, cacheItem.Messages, cacheItem.Model)
+	request := queue.Request{
+		UserID:     userID,
+		Short:      tokens == nil || requestQueue.ShortRequest(*tokens),
+		OnPosition: onPosition,
+	}
+	err = requestQueue.Do(ctx, request, func() error {
+		resp, err = sendChatGPTRequest(ctx, client, cacheItem, aug)
+		return err
+	})
+	return
+}
+
+// pendingMessagePosition shows queue position in the pending message, and restores it once the request starts
+func pendingMessagePosition(s *discord.Session, logger *slog.Logger, locale discord.Locale, m *discord.Message) func(position int) {
+	return func(position int) {
+		content := i18n.T(locale, "gpt.pending")
+		if position > 0 {
+			content = i18n.T(locale, "queue.position", position)
+		}
+		err := utils.DiscordChannelMessageEdit(s, m.ID, m.ChannelID, &content, nil)
+		if err != nil {
+			logger.Error("Failed to update queue position", "error", err)
+		}
+	}
+}
+
+// replyPosition shows queue position in a reply to the message, the reply is removed once the request starts
+func replyPosition(s *discord.Session, logger *slog.Logger, locale discord.Locale, m *discord.Message) func(position int) {
+	var reply *discord.Message
+	return func(position int) {
+		var err error
+		switch {
+		case position == 0:
+			if reply != nil {
+				err = s.ChannelMessageDelete(reply.ChannelID, reply.ID)
+				reply = nil
+			}
+		case reply == nil:
+			reply, err = s.ChannelMessageSendReply(m.ChannelID, i18n.T(locale, "queue.position", position), m.Reference())
+		default:
+			_, err = s.ChannelMessageEdit(reply.ChannelID, reply.ID, i18n.T(locale, "queue.position", position))
+		}
+		if err != nil {
+			logger.Error("Failed to update queue position", "error", err)
+		}
+	}
+}
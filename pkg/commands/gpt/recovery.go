@@ -0,0 +1,259 @@
+package gpt
+
+import (
+	"log/slog"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	gptComponentActionRetry = "retry"
+
+	// Unfinished requests are looked up among the latest messages of a locked thread
+	gptRecoveryMessagesLimit = 10
+)
+
+// retryRow makes a button that requests the answer to the last message of the thread again.
+// Custom ID is `retry`
+func retryRow(locale discord.Locale, path []string) []discord.MessageComponent {
+	return []discord.MessageComponent{
+		discord.ActionsRow{
+			Components: []discord.MessageComponent{
+				&discord.Button{
+					Label:    i18n.T(locale, "gpt.retry"),
+					Style:    discord.PrimaryButton,
+					Emoji:    &discord.ComponentEmoji{Name: "🔁"},
+					CustomID: bot.ComponentCustomID(path, gptComponentActionRetry),
+				},
+			},
+		},
+	}
+}
+
+// interruptedNotice replaces the pending message of a request cancelled by shutdown, see bot.Context.OnInterrupt
+func interruptedNotice(s *discord.Session, locale discord.Locale, path []string, m *discord.Message) {
+	content := i18n.T(locale, "bot.interrupted")
+	components := retryRow(locale, path)
+	s.ChannelMessageEditComplex(&discord.MessageEdit{
+		ID:         m.ID,
+		Channel:    m.ChannelID,
+		Content:    &content,
+		Components: &components,
+	})
+}
+
+// RecoverThreads unlocks chat threads that were left locked because the bot stopped while answering,
+// e.g. after a crash. Pending messages and ack reactions of unfinished requests are replaced with a notice
+// and a retry button, in the language of the guild. Threads locked without unfinished requests, e.g. by moderators, stay locked.
+// parent is the path of the command that gpt command is registered under
+func RecoverThreads(s *discord.Session, parent []string, guilds []*discord.Guild) {
+	path := append(append([]string{}, parent...), commandName)
+	for _, guild := range guilds {
+		logger := slog.With("guild", guild.ID)
+		threads, err := s.GuildThreadsActive(guild.ID)
+		if err != nil {
+			logger.Error("Failed to list active threads", "error", err)
+			continue
+		}
+		for _, thread := range threads.Threads {
+			if thread.OwnerID != s.State.User.ID || thread.ThreadMetadata == nil || !thread.ThreadMetadata.Locked {
+				continue
+			}
+			recoverThread(s, logger.With("thread", thread.ID), bot.GuildLocale(s, guild.ID), path, thread)
+		}
+	}
+}
+
+func recoverThread(s *discord.Session, logger *slog.Logger, locale discord.Locale, path []string, thread *discord.Channel) {
+	messages, err := s.ChannelMessages(thread.ID, gptRecoveryMessagesLimit, "", "", "")
+	if err != nil {
+		logger.Error("Failed to get thread messages", "error", err)
+		return
+	}
+
+	content := i18n.T(locale, "gpt.recovered")
+	components := retryRow(locale, path)
+	recovered := 0
+	for _, m := range messages {
+		switch {
+		case isOwnMessage(s, m) && m.Type == discord.MessageTypeReply && i18n.Matches("queue.position", m.Content):
+			// queue position of a message, the message itself gets the notice
+			if err := s.ChannelMessageDelete(m.ChannelID, m.ID); err != nil {
+				logger.Error("Failed to remove queue position", "error", err)
+			}
+		case isOwnMessage(s, m) && (i18n.Matches("gpt.pending", m.Content) || i18n.Matches("queue.position", m.Content)):
+			// pending message of the chat command
+			_, err := s.ChannelMessageEditComplex(&discord.MessageEdit{
+				ID:         m.ID,
+				Channel:    m.ChannelID,
+				Content:    &content,
+				Components: &components,
+			})
+			if err != nil {
+				logger.Error("Failed to replace pending message", "message", m.ID, "error", err)
+				continue
+			}
+			recovered++
+		case hasOwnReaction(m, gptEmojiAck):
+			// message in the thread that was being answered
+			if err := s.MessageReactionsRemoveEmoji(m.ChannelID, m.ID, gptEmojiAck); err != nil {
+				logger.Error("Failed to remove ack reaction", "message", m.ID, "error", err)
+			}
+			_, err := s.ChannelMessageSendComplex(m.ChannelID, &discord.MessageSend{
+				Content:    content,
+				Components: components,
+				Reference:  m.Reference(),
+			})
+			if err != nil {
+				logger.Error("Failed to reply with retry notice", "message", m.ID, "error", err)
+				continue
+			}
+			recovered++
+		}
+	}
+
+	if recovered == 0 {
+		logger.Debug("Locked thread has no unfinished requests, leaving it locked")
+		return
+	}
+	utils.ToggleDiscordThreadLock(s, thread.ID, false)
+	logger.Info("Recovered unfinished requests in a locked thread", "requests", recovered)
+}
+
+func isOwnMessage(s *discord.Session, m *discord.Message) bool {
+	return m.Author != nil && m.Author.ID == s.State.User.ID
+}
+
+func hasOwnReaction(m *discord.Message, emoji string) bool {
+	for _, reaction := range m.Reactions {
+		if reaction.Me && reaction.Emoji != nil && reaction.Emoji.Name == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+func chatGPTComponentFailed(ctx *bot.Context, description string) {
+	ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags: discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: description,
+					Color:       0xff0000,
+				},
+			},
+		},
+	})
+}
+
+// chatGPTComponentHandler checks the retry button, the answer is handled by chatGPTRetryHandler
+// after command middlewares, so retries are rate limited like other requests
+func chatGPTComponentHandler(ctx *bot.Context) {
+	if len(ctx.ComponentArgs) != 1 || ctx.ComponentArgs[0] != gptComponentActionRetry {
+		ctx.Logger.Error("Unexpected chat component arguments", "args", ctx.ComponentArgs)
+		chatGPTComponentFailed(ctx, ctx.T("component.unsupported"))
+		return
+	}
+	ctx.Next()
+}
+
+// chatGPTRetryHandler answers the last message of the thread in place of the notice with the retry button
+func chatGPTRetryHandler(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, moderator *moderation.Moderator, settingsStore *settings.Store, completionModels []string, messagesCache *MessagesCache, knowledgeBases *kb.Store, memories *memory.Store) {
+	ch, err := ctx.Session.State.Channel(ctx.Interaction.ChannelID)
+	if err != nil || !ch.IsThread() || ctx.Interaction.Message == nil {
+		chatGPTComponentFailed(ctx, ctx.T("gpt.retry.threadsOnly"))
+		return
+	}
+	if ch.ThreadMetadata != nil && ch.ThreadMetadata.Locked {
+		chatGPTComponentFailed(ctx, ctx.T("gpt.retry.locked"))
+		return
+	}
+
+	ctx.Logger.Info("ChatGPT retry invoked")
+
+	err = ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+		return
+	}
+
+	// The notice becomes the pending message
+	channelMessage := ctx.Interaction.Message
+	content := ctx.T("gpt.pending")
+	_, err = ctx.Session.ChannelMessageEditComplex(&discord.MessageEdit{
+		ID:         channelMessage.ID,
+		Channel:    channelMessage.ChannelID,
+		Content:    &content,
+		Components: &[]discord.MessageComponent{},
+		Embeds:     &[]*discord.MessageEmbed{},
+	}, discord.WithContext(ctx.Context()))
+	if err != nil {
+		ctx.Logger.Error("Failed to update the message", "error", err)
+		return
+	}
+
+	utils.ToggleDiscordThreadLock(ctx.Session, ch.ID, true, discord.WithContext(ctx.Context()))
+	defer utils.ToggleDiscordThreadLock(ctx.Session, ch.ID, false, discord.WithContext(ctx.Context()))
+	removeInterrupt := ctx.OnInterrupt(func() {
+		interruptedNotice(ctx.Session, ctx.Locale(), ctx.Path, channelMessage)
+		utils.ToggleDiscordThreadLock(ctx.Session, ch.ID, false)
+	})
+	defer removeInterrupt()
+
+	cacheItem, ok := messagesCache.Get(ch.ID)
+	if !ok {
+		var isGPTThread bool
+		cacheItem, isGPTThread, err = threadConversation(ctx.Context(), ctx.Session, ctx.Logger, ch, settingsStore, completionModels)
+		switch {
+		case err != nil:
+			monitoring.ThreadReconstructed("failed")
+		case !isGPTThread:
+			monitoring.ThreadReconstructed("ignored")
+		default:
+			monitoring.ThreadReconstructed("chat")
+		}
+		if err != nil || !isGPTThread {
+			ctx.Logger.Error("Failed to rebuild the conversation", "error", err)
+			chatGPTRetryFailed(ctx, channelMessage, ctx.T("gpt.retry.rebuildFailed"))
+			return
+		}
+		messagesCache.Add(ch.ID, cacheItem)
+	}
+	if n := len(cacheItem.Messages); n == 0 || cacheItem.Messages[n-1].Role != openai.ChatMessageRoleUser {
+		ctx.Logger.Info("Conversation has no unanswered message")
+		chatGPTRetryFailed(ctx, channelMessage, ctx.T("gpt.retry.answered"))
+		return
+	}
+	if ok, count := isCacheItemWithinTruncateLimit(cacheItem); !ok {
+		ctx.Logger.Info("Thread cache token count exceeds truncate limit, performing adjustments", "tokens", count)
+		adjustMessageTokens(cacheItem)
+	}
+
+	answerInMessage(ctx, client, requestQueue, moderator, knowledgeBases, memories, channelMessage, cacheItem)
+}
+
+func chatGPTRetryFailed(ctx *bot.Context, m *discord.Message, description string) {
+	emptyString := ""
+	utils.DiscordChannelMessageEdit(ctx.Session, m.ID, m.ChannelID, &emptyString, &[]*discord.MessageEmbed{
+		{
+			Title:       ctx.T("error.title"),
+			Description: description,
+			Color:       0xff0000,
+		},
+	}, discord.WithContext(ctx.Context()))
+}
@@ -0,0 +1,51 @@
+package gpt
+
+import (
+	"slices"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
+)
+
+// chatChannelAllowed checks whether guild settings allow conversations in the channel of the interaction.
+// Interaction is responded with an ephemeral explanation if they do not
+func chatChannelAllowed(ctx *bot.Context, guild *settings.Guild) bool {
+	if guild.ChatAllowedIn(ctx.Interaction.ChannelID) {
+		return true
+	}
+
+	ctx.Logger.Info("Chat is not allowed in the channel by guild settings")
+	channels := make([]string, 0, len(guild.ChatChannels))
+	for _, channel := range guild.ChatChannels {
+		channels = append(channels, "<#"+channel+">")
+	}
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags: discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: ctx.T("gpt.channelNotAllowed", strings.Join(channels, ", ")),
+					Color:       0xff0000,
+				},
+			},
+		},
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+	}
+	return false
+}
+
+// guildModelError describes why the model cannot be used in the guild, or returns empty string if it can
+func guildModelError(locale discord.Locale, guild *settings.Guild, completionModels []string, model string) string {
+	models := guild.Models(completionModels)
+	if len(completionModels) == 0 || slices.Contains(models, model) {
+		return ""
+	}
+	return i18n.T(locale, "gpt.modelNotEnabled", model, strings.Join(models, "`, `"))
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	discord "github.com/bwmarrin/discordgo"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
 	"github.com/raikerian/go-remai-bot-discord/pkg/constants"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
 	"github.com/raikerian/go-remai-bot-discord/pkg/utils"
 	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // See https://openai.com/pricing
@@ -40,43 +45,99 @@ func shouldHandleMessageType(t discord.MessageType) bool {
 type chatGPTResponse struct {
 	content string
 	usage   openai.Usage
+	// facts the model remembered about the user while answering
+	learned []string
 }
 
-func sendChatGPTRequest(client *openai.Client, cacheItem *MessagesCacheData) (*chatGPTResponse, error) {
+// sendChatGPTRequest completes the conversation. Augmentation is sent along with the conversation but does not
+// become a part of it, tool calls it offers are answered until the model answers the user
+func sendChatGPTRequest(ctx context.Context, client *openai.Client, cacheItem *MessagesCacheData, aug augmentation) (*chatGPTResponse, error) {
 	// Create message with ChatGPT
-	messages := cacheItem.Messages
-	if cacheItem.SystemMessage != nil {
-		messages = append([]openai.ChatCompletionMessage{*cacheItem.SystemMessage}, messages...)
+	var messages []openai.ChatCompletionMessage
+	if systemMessage := aug.systemMessage(cacheItem); systemMessage != nil {
+		messages = append(messages, *systemMessage)
 	}
+	if aug.knowledge.message != nil {
+		messages = append(messages, *aug.knowledge.message)
+	}
+	messages = append(messages, cacheItem.Messages...)
 
 	req := openai.ChatCompletionRequest{
 		Model:    cacheItem.Model,
 		Messages: messages,
+		Tools:    aug.tools(),
 	}
 
 	if cacheItem.Temperature != nil {
 		req.Temperature = *cacheItem.Temperature
 	}
 
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
-		req,
-	)
-	if err != nil {
-		return nil, err
+	response := &chatGPTResponse{}
+	var resp openai.ChatCompletionResponse
+	for round := 1; ; round++ {
+		if round == gptToolCallsMaxRounds {
+			// the last round must answer the user
+			req.Tools = nil
+		}
+		var err error
+		resp, err = createChatCompletion(ctx, client, req)
+		if err != nil {
+			return nil, err
+		}
+		response.usage.PromptTokens += resp.Usage.PromptTokens
+		response.usage.CompletionTokens += resp.Usage.CompletionTokens
+		response.usage.TotalTokens += resp.Usage.TotalTokens
+
+		message := resp.Choices[0].Message
+		if len(message.ToolCalls) == 0 || req.Tools == nil {
+			response.content = message.Content
+			break
+		}
+		req.Messages = append(req.Messages, message)
+		for _, call := range message.ToolCalls {
+			result, learned := aug.call(call)
+			if learned != "" {
+				response.learned = append(response.learned, learned)
+			}
+			req.Messages = append(req.Messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    result,
+				ToolCallID: call.ID,
+			})
+		}
 	}
 
 	// Save response to context cache
-	responseContent := resp.Choices[0].Message.Content
 	cacheItem.Messages = append(cacheItem.Messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleAssistant,
-		Content: responseContent,
+		Content: response.content,
 	})
 	cacheItem.TokenCount = resp.Usage.TotalTokens
-	return &chatGPTResponse{
-		content: responseContent,
-		usage:   resp.Usage,
-	}, nil
+	return response, nil
+}
+
+func createChatCompletion(ctx context.Context, client *openai.Client, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	ctx, span := tracing.Start(ctx, "openai.chat_completion", attribute.String("model", req.Model))
+	start := time.Now()
+	resp, err := client.CreateChatCompletion(
+		ctx,
+		req,
+	)
+	monitoring.ObserveProviderRequest(req.Model, start, err)
+	if err != nil {
+		tracing.End(span, err)
+		return resp, err
+	}
+	span.SetAttributes(
+		attribute.Int("usage.prompt_tokens", resp.Usage.PromptTokens),
+		attribute.Int("usage.completion_tokens", resp.Usage.CompletionTokens),
+	)
+	span.End()
+	monitoring.AddUsage(req.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+	if cost, ok := calculateCost(resp.Usage, req.Model); ok {
+		monitoring.AddCost(req.Model, cost)
+	}
+	return resp, nil
 }
 
 func getUrlData(client *http.Client, url string) (string, error) {
@@ -126,7 +187,7 @@ func parseInteractionReply(discordMessage *discord.Message) (prompt string, cont
 			case gptCommandOptionTemperature.humanReadableString():
 				parsedValue, err := strconv.ParseFloat(field.Value, 32)
 				if err != nil {
-					log.Printf("[GID: %s, CHID: %s, MID: %s] Failed to parse temperature value from the message with the error: %v\n", discordMessage.GuildID, discordMessage.ChannelID, discordMessage.ID, err)
+					slog.Error("Failed to parse temperature value from the message", "guild", discordMessage.GuildID, "channel", discordMessage.ChannelID, "message", discordMessage.ID, "error", err)
 					continue
 				}
 				temp := float32(parsedValue)
@@ -184,7 +245,7 @@ func isCacheItemWithinTruncateLimit(cacheItem *MessagesCacheData) (ok bool, coun
 	return *tokens <= *truncateLimit, *tokens
 }
 
-func generateThreadTitleBasedOnInitialPrompt(ctx *bot.Context, client *openai.Client, threadID string, messages []openai.ChatCompletionMessage) {
+func generateThreadTitleBasedOnInitialPrompt(ctx *bot.Context, client *openai.Client, requestQueue *queue.Queue, threadID string, messages []openai.ChatCompletionMessage) {
 	conversation := make([]map[string]string, len(messages))
 	for i, msg := range messages {
 		conversation[i] = map[string]string{
@@ -203,30 +264,44 @@ func generateThreadTitleBasedOnInitialPrompt(ctx *bot.Context, client *openai.Cl
 	// Create a prompt that asks the model to generate a title
 	prompt := fmt.Sprintf("%s\nGenerate a short and concise title summarizing the conversation in the same language. The title must not contain any quotes. The title should be no longer than 60 characters:", conversationText)
 
-	resp, err := client.CreateCompletion(context.Background(), openai.CompletionRequest{
-		Model:       openai.GPT3Dot5TurboInstruct,
-		Prompt:      prompt,
-		Temperature: 0.5,
-		MaxTokens:   75,
+	var resp openai.CompletionResponse
+	err := requestQueue.Do(ctx.Context(), queue.Request{UserID: ctx.Interaction.Member.User.ID, Short: true}, func() (err error) {
+		requestContext, span := tracing.Start(ctx.Context(), "openai.completion", attribute.String("model", openai.GPT3Dot5TurboInstruct))
+		defer func() { tracing.End(span, err) }()
+		start := time.Now()
+		resp, err = client.CreateCompletion(requestContext, openai.CompletionRequest{
+			Model:       openai.GPT3Dot5TurboInstruct,
+			Prompt:      prompt,
+			Temperature: 0.5,
+			MaxTokens:   75,
+		})
+		monitoring.ObserveProviderRequest(openai.GPT3Dot5TurboInstruct, start, err)
+		if err == nil {
+			monitoring.AddUsage(openai.GPT3Dot5TurboInstruct, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+		}
+		return
 	})
 	if err != nil {
-		log.Printf("[GID: %s, threadID: %s] Failed to generate thread title with the error: %v\n", ctx.Interaction.GuildID, threadID, err)
+		ctx.Logger.Error("Failed to generate thread title", "error", err)
 		return
 	}
 
 	_, err = ctx.Session.ChannelEditComplex(threadID, &discord.ChannelEdit{
 		Name: resp.Choices[0].Text,
-	})
+	}, discord.WithContext(ctx.Context()))
 	if err != nil {
-		log.Printf("[GID: %s, i.ID: %s] Failed to update thread title with the error: %v\n", ctx.Interaction.GuildID, threadID, err)
+		ctx.Logger.Error("Failed to update thread title", "error", err)
 	}
 }
 
-func attachUsageInfo(s *discord.Session, m *discord.Message, usage openai.Usage, model string) {
+// attachUsageInfo adds token usage and cost of the answer to its last message, along with notes about it
+// such as sources it cites
+func attachUsageInfo(s *discord.Session, m *discord.Message, usage openai.Usage, model string, notes string) {
 	extraInfo := fmt.Sprintf("Completion Tokens: %d, Total: %d%s", usage.CompletionTokens, usage.TotalTokens, generateCost(usage, model))
 
 	utils.DiscordChannelMessageEdit(s, m.ID, m.ChannelID, nil, &[]*discord.MessageEmbed{
 		{
+			Description: notes,
 			Footer: &discord.MessageEmbedFooter{
 				Text:    extraInfo,
 				IconURL: constants.OpenAIBlackIconURL,
@@ -236,8 +311,15 @@ func attachUsageInfo(s *discord.Session, m *discord.Message, usage openai.Usage,
 }
 
 func generateCost(usage openai.Usage, model string) string {
-	var cost float64
+	cost, ok := calculateCost(usage, model)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("\nLLM Cost: $%f", cost)
+}
 
+// calculateCost returns cost of the usage in US dollars, if price of the model is known
+func calculateCost(usage openai.Usage, model string) (cost float64, ok bool) {
 	switch model {
 	case openai.GPT3Dot5Turbo16K:
 		cost = float64(usage.PromptTokens)*gptPricePerPromptTokenGPT3Dot5Turbo16K + float64(usage.CompletionTokens)*gptPricePerCompletionTokenGPT3Dot5Turbo16K
@@ -247,8 +329,8 @@ func generateCost(usage openai.Usage, model string) string {
 		cost = float64(usage.PromptTokens)*gptPricePerPromptTokenGPT4o + float64(usage.CompletionTokens)*gptPricePerCompletionTokenGPT4o
 	default:
 		// Not implemented
-		return ""
+		return 0, false
 	}
 
-	return fmt.Sprintf("\nLLM Cost: $%f", cost)
+	return cost, true
 }
@@ -2,21 +2,72 @@ package commands
 
 import (
 	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
 	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
 	"github.com/raikerian/go-remai-bot-discord/pkg/commands/dalle"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/ratelimit"
+	"github.com/raikerian/go-remai-bot-discord/pkg/settings"
 	"github.com/sashabaranov/go-openai"
 )
 
 const imageCommandName = "image"
 
-func ImageCommand(client *openai.Client) *bot.Command {
+type ImageCommandParams struct {
+	OpenAIClient *openai.Client
+	RequestQueue *queue.Queue
+	Moderator    *moderation.Moderator
+	Archiver     archive.Archiver
+	Settings     *settings.Store
+	RateLimiter  *ratelimit.Limiter
+}
+
+// imageEnabledMiddleware stops image commands in guilds that disabled them in settings
+func imageEnabledMiddleware(ctx *bot.Context, settingsStore *settings.Store) {
+	guild := settingsStore.Get(ctx.Interaction.GuildID)
+	if guild.ImagesEnabled() {
+		ctx.Next()
+		return
+	}
+
+	ctx.Logger.Info("Image commands are disabled by guild settings")
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags: discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{
+				{
+					Title:       ctx.T("error.title"),
+					Description: ctx.T("image.disabled"),
+					Color:       0xff0000,
+				},
+			},
+		},
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+	}
+}
+
+func ImageCommand(params *ImageCommandParams) *bot.Command {
 	return &bot.Command{
 		Name:                     imageCommandName,
-		Description:              "Generate creative images from textual descriptions",
+		Description:              i18n.T(i18n.Default, "image.description"),
+		DescriptionLocalizations: i18n.Localizations("image.description"),
 		DMPermission:             false,
 		DefaultMemberPermissions: discord.PermissionViewChannel,
+		Middlewares: []bot.Handler{
+			bot.HandlerFunc(func(ctx *bot.Context) {
+				imageEnabledMiddleware(ctx, params.Settings)
+			}),
+			params.RateLimiter,
+		},
 		SubCommands: bot.NewRouter([]*bot.Command{
-			dalle.Command(client),
+			dalle.Command(params.OpenAIClient, params.RequestQueue, params.Moderator, params.Archiver),
+			dalle.EditCommand(params.OpenAIClient, params.RequestQueue, params.Moderator, params.Archiver),
+			dalle.VariationCommand(params.OpenAIClient, params.RequestQueue, params.Moderator, params.Archiver),
 		}),
 	}
 }
@@ -0,0 +1,126 @@
+package commands_test
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands"
+	"github.com/raikerian/go-remai-bot-discord/pkg/fake"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestImageDalle(t *testing.T) {
+	tests := []struct {
+		name   string
+		number int
+		// failures is how many image requests fail before the other ones succeed
+		failures    int32
+		wantImages  int
+		wantError   string
+		wantWarning string
+	}{
+		{name: "one image", number: 1, wantImages: 1},
+		{name: "several images", number: 3, wantImages: 3},
+		{name: "some images failed", number: 3, failures: 1, wantImages: 2, wantWarning: "⚠️ Failed to generate 1 of 3 images"},
+		{name: "all images failed", number: 2, failures: 2, wantError: "❌ OpenAI API failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBot(t)
+			b.router.Register(commands.ImageCommand(&commands.ImageCommandParams{
+				OpenAIClient: b.openai.Client(),
+				Moderator:    b.moderator,
+				Settings:     b.settings,
+			}))
+			var failures atomic.Int32
+			failures.Store(tt.failures)
+			b.openai.Image = func(fake.Image) error {
+				if failures.Add(-1) >= 0 {
+					return &fake.APIError{Status: http.StatusBadRequest, Message: "rejected"}
+				}
+				return nil
+			}
+
+			i := b.command("image", fake.SubCommand("dalle", fake.Option("prompt", "a cat"), fake.Option("number", tt.number)))
+
+			requests := b.openai.ImageRequests()
+			if len(requests) != tt.number {
+				t.Fatalf("got %d image requests, want %d", len(requests), tt.number)
+			}
+			if requests[0].Model != openai.CreateImageModelDallE3 || requests[0].N != 1 {
+				t.Errorf("request model %s, n %d, want %s, 1", requests[0].Model, requests[0].N, openai.CreateImageModelDallE3)
+			}
+
+			response := b.discord.Response(i)
+			if response == nil || len(response.Embeds) == 0 {
+				t.Fatalf("got response %+v, want embeds", response)
+			}
+			if tt.wantError != "" {
+				if response.Embeds[0].Title != tt.wantError {
+					t.Errorf("error title = %q, want %q", response.Embeds[0].Title, tt.wantError)
+				}
+				return
+			}
+
+			if len(response.Attachments) != tt.wantImages {
+				t.Errorf("got %d attachments, want %d", len(response.Attachments), tt.wantImages)
+			}
+			if got := len(response.Embeds) - 1; got != tt.wantImages {
+				t.Errorf("got %d image embeds, want %d", got, tt.wantImages)
+			}
+			var warning string
+			for _, field := range response.Embeds[0].Fields {
+				if field.Name == tt.wantWarning {
+					warning = field.Name
+				}
+			}
+			if warning != tt.wantWarning {
+				t.Errorf("fields %+v, want %q", response.Embeds[0].Fields, tt.wantWarning)
+			}
+
+			// Link buttons, actions and variations
+			if len(response.Components) != 3 {
+				t.Fatalf("got %d component rows, want 3", len(response.Components))
+			}
+			links := response.Components[0].(*discord.ActionsRow).Components
+			if len(links) != tt.wantImages {
+				t.Errorf("got %d link buttons, want %d", len(links), tt.wantImages)
+			}
+			for n, link := range links {
+				if url := link.(*discord.Button).URL; url != response.Attachments[n].URL {
+					t.Errorf("link button %d points to %s, want %s", n, url, response.Attachments[n].URL)
+				}
+			}
+		})
+	}
+}
+
+func TestImageEnhancedPromptModeration(t *testing.T) {
+	b := newTestBot(t)
+	b.router.Register(commands.ImageCommand(&commands.ImageCommandParams{
+		OpenAIClient: b.openai.Client(),
+		Moderator:    b.moderator,
+		Settings:     b.settings,
+	}))
+	b.openai.Chat = func(openai.ChatCompletionRequest) (string, error) {
+		return "a forbidden cat", nil
+	}
+	b.openai.Moderation = func(input string) []string {
+		if strings.Contains(input, "forbidden") {
+			return []string{"violence"}
+		}
+		return nil
+	}
+
+	i := b.command("image", fake.SubCommand("dalle", fake.Option("prompt", "a cat"), fake.Option("enhance", true)))
+
+	if requests := b.openai.ImageRequests(); len(requests) != 0 {
+		t.Errorf("got %d image requests for the flagged enhanced prompt, want none", len(requests))
+	}
+	if response := b.discord.Response(i); response == nil || len(response.Embeds) == 0 || response.Embeds[0].Title != "❌ Error" {
+		t.Errorf("got response %+v, want moderation error", response)
+	}
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands/knowledge"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/ratelimit"
+)
+
+const kbCommandName = "kb"
+
+type KBCommandParams struct {
+	KnowledgeBases *kb.Store
+	RequestQueue   *queue.Queue
+	RateLimiter    *ratelimit.Limiter
+}
+
+func KBCommand(params *KBCommandParams) *bot.Command {
+	return &bot.Command{
+		Name:                     kbCommandName,
+		Description:              i18n.T(i18n.Default, "kb.description"),
+		DescriptionLocalizations: i18n.Localizations("kb.description"),
+		DMPermission:             false,
+		DefaultMemberPermissions: discord.PermissionViewChannel,
+		Type:                     discord.ChatApplicationCommand,
+		Middlewares:              []bot.Handler{params.RateLimiter},
+		SubCommands: bot.NewRouter([]*bot.Command{
+			knowledge.AddCommand(params.KnowledgeBases, params.RequestQueue),
+			knowledge.ListCommand(params.KnowledgeBases),
+			knowledge.RemoveCommand(params.KnowledgeBases),
+		}),
+	}
+}
@@ -0,0 +1,92 @@
+package commands_test
+
+import (
+	"strings"
+	"testing"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands"
+	"github.com/raikerian/go-remai-bot-discord/pkg/fake"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/sashabaranov/go-openai"
+)
+
+func newKnowledgeBot(t *testing.T) *testBot {
+	knowledgeBases, err := kb.New(kb.Config{Dir: t.TempDir(), Embeddings: kb.EmbeddingsLocal}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newChatBot(t, knowledgeBases, nil)
+	b.router.Register(commands.KBCommand(&commands.KBCommandParams{KnowledgeBases: knowledgeBases}))
+	return b
+}
+
+// kbCommand runs a /kb subcommand as the user, the file is attached if it is not nil
+func (b *testBot) kbCommand(user *discord.User, subCommand string, file *discord.MessageAttachment, options ...*discord.ApplicationCommandInteractionDataOption) *discord.Message {
+	data := fake.CommandData("kb")
+	if file != nil {
+		options = append(options, fake.AttachmentOption(&data, "file", file))
+	}
+	data.Options = append(data.Options, fake.SubCommand(subCommand, options...))
+	return b.discord.Response(b.discord.Command(b.guild.ID, b.channel.ID, user, data))
+}
+
+func embedText(m *discord.Message) string {
+	if m == nil || len(m.Embeds) == 0 {
+		return ""
+	}
+	text := m.Embeds[0].Title + "\n" + m.Embeds[0].Description
+	for _, field := range m.Embeds[0].Fields {
+		text += "\n" + field.Name + ": " + field.Value
+	}
+	return text
+}
+
+func TestKnowledgeBaseChat(t *testing.T) {
+	b := newKnowledgeBot(t)
+	b.openai.Chat = func(openai.ChatCompletionRequest) (string, error) {
+		return "The code is 4242 [1]", nil
+	}
+
+	file := b.discord.Attach("launch.md", "text/markdown", []byte("# Launch\nThe secret launch code is 4242.\n"))
+	if got := embedText(b.kbCommand(b.user, "add", file)); !strings.Contains(got, "Added to knowledge base `default`") || !strings.Contains(got, "`launch.md`, 1 chunks") {
+		t.Fatalf("add response = %q, want the added document", got)
+	}
+	if got := embedText(b.kbCommand(b.user, "list", nil)); !strings.Contains(got, "default: `launch.md`") {
+		t.Errorf("list response = %q, want the knowledge base", got)
+	}
+
+	i := b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "What is the secret launch code?"), fake.Option("kb", "Default")))
+	if got := embedText(b.discord.Response(i)); !strings.Contains(got, "Knowledge base: default") {
+		t.Errorf("chat response = %q, want the knowledge base field", got)
+	}
+	requests := b.openai.ChatRequests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d chat requests, want 1", len(requests))
+	}
+	messages := requests[0].Messages
+	if len(messages) != 2 || messages[0].Role != openai.ChatMessageRoleSystem || !strings.Contains(messages[0].Content, "[1] launch.md, chunk 1:\n# Launch\nThe secret launch code is 4242.") {
+		t.Errorf("chat request messages = %q, want the excerpt before the prompt", chatRoles(messages))
+	}
+	thread := b.discord.Threads(b.channel.ID)[0]
+	threadMessages := b.discord.Messages(thread.ID)
+	if got := embedText(threadMessages[len(threadMessages)-1]); !strings.Contains(got, "Sources from `default`:\n[1] `launch.md` #1") {
+		t.Errorf("answer embed = %q, want the sources", got)
+	}
+
+	i = b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "Hello"), fake.Option("kb", "missing")))
+	if got := embedText(b.discord.Response(i)); !strings.Contains(got, "Knowledge base `missing` does not exist") {
+		t.Errorf("chat response = %q, want unknown knowledge base error", got)
+	}
+
+	other := b.discord.AddUser("other")
+	if got := embedText(b.kbCommand(other, "remove", nil, fake.Option("document", "launch.md"))); !strings.Contains(got, "Failed to remove document") {
+		t.Errorf("remove by other member response = %q, want an error", got)
+	}
+	if got := embedText(b.kbCommand(b.user, "remove", nil, fake.Option("document", "launch.md"))); !strings.Contains(got, "Removed from knowledge base `default`") {
+		t.Errorf("remove response = %q, want the removed document", got)
+	}
+	if got := embedText(b.kbCommand(b.user, "list", nil)); !strings.Contains(got, "no knowledge bases") {
+		t.Errorf("list response = %q, want no knowledge bases", got)
+	}
+}
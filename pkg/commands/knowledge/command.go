@@ -0,0 +1,85 @@
+package knowledge
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+)
+
+const (
+	addCommandName    = "add"
+	listCommandName   = "list"
+	removeCommandName = "remove"
+
+	// Knowledge base used when the command does not name one
+	defaultKnowledgeBase = "default"
+)
+
+func nameOption() *discord.ApplicationCommandOption {
+	return &discord.ApplicationCommandOption{
+		Type:                     discord.ApplicationCommandOptionString,
+		Name:                     kbCommandOptionName.String(),
+		Description:              i18n.T(i18n.Default, "kb.option.kb"),
+		DescriptionLocalizations: i18n.Localizations("kb.option.kb"),
+		Required:                 false,
+		MaxLength:                32,
+	}
+}
+
+// AddCommand adds uploaded documents to knowledge bases, embeddings are requested in the queue like
+// other OpenAI requests
+func AddCommand(knowledgeBases *kb.Store, requestQueue *queue.Queue) *bot.Command {
+	return &bot.Command{
+		Name:                     addCommandName,
+		Description:              i18n.T(i18n.Default, "kb.add.description"),
+		DescriptionLocalizations: i18n.Localizations("kb.add.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionAttachment,
+				Name:                     kbCommandOptionFile.String(),
+				Description:              i18n.T(i18n.Default, "kb.add.option.file"),
+				DescriptionLocalizations: i18n.Localizations("kb.add.option.file"),
+				Required:                 true,
+			},
+			nameOption(),
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			addHandler(ctx, knowledgeBases, requestQueue)
+		}),
+	}
+}
+
+func ListCommand(knowledgeBases *kb.Store) *bot.Command {
+	return &bot.Command{
+		Name:                     listCommandName,
+		Description:              i18n.T(i18n.Default, "kb.list.description"),
+		DescriptionLocalizations: i18n.Localizations("kb.list.description"),
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			listHandler(ctx, knowledgeBases)
+		}),
+	}
+}
+
+// RemoveCommand removes documents, members can remove documents they added, and server managers any of them
+func RemoveCommand(knowledgeBases *kb.Store) *bot.Command {
+	return &bot.Command{
+		Name:                     removeCommandName,
+		Description:              i18n.T(i18n.Default, "kb.remove.description"),
+		DescriptionLocalizations: i18n.Localizations("kb.remove.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     kbCommandOptionDocument.String(),
+				Description:              i18n.T(i18n.Default, "kb.remove.option.document"),
+				DescriptionLocalizations: i18n.Localizations("kb.remove.option.document"),
+				Required:                 true,
+			},
+			nameOption(),
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			removeHandler(ctx, knowledgeBases)
+		}),
+	}
+}
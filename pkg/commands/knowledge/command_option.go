@@ -0,0 +1,23 @@
+package knowledge
+
+import "fmt"
+
+type kbCommandOptionType uint8
+
+const (
+	kbCommandOptionFile     kbCommandOptionType = 1
+	kbCommandOptionName     kbCommandOptionType = 2
+	kbCommandOptionDocument kbCommandOptionType = 3
+)
+
+func (t kbCommandOptionType) String() string {
+	switch t {
+	case kbCommandOptionFile:
+		return "file"
+	case kbCommandOptionName:
+		return "kb"
+	case kbCommandOptionDocument:
+		return "document"
+	}
+	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
+}
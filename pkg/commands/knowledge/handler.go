@@ -0,0 +1,240 @@
+package knowledge
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+)
+
+const (
+	kbEmbedColor = 0x5865f2
+	// due to discord embed field value limitation
+	kbFieldValueMaxLength = 1024
+	// Discord limit of embed fields
+	kbFieldsMaxCount = 25
+)
+
+// respond replies to the interaction right away, errors are shown to the member only
+func respond(ctx *bot.Context, embed *discord.MessageEmbed, ephemeral bool) {
+	var flags discord.MessageFlags
+	if ephemeral {
+		flags = discord.MessageFlagsEphemeral
+	}
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags:  flags,
+			Embeds: []*discord.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+	}
+}
+
+func failed(ctx *bot.Context, title string, description string) {
+	respond(ctx, &discord.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       0xff0000,
+	}, true)
+}
+
+// editResponse replaces the deferred response, along with queue position shown in it
+func editResponse(ctx *bot.Context, embed *discord.MessageEmbed) {
+	content := ""
+	_, err := ctx.InteractionResponseEdit(ctx.Interaction, &discord.WebhookEdit{
+		Content: &content,
+		Embeds:  &[]*discord.MessageEmbed{embed},
+	}, discord.WithContext(ctx.Context()))
+	if err != nil {
+		ctx.Logger.Error("Failed to edit interaction response", "error", err)
+	}
+}
+
+func editFailed(ctx *bot.Context, title string, description string) {
+	editResponse(ctx, &discord.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       0xff0000,
+	})
+}
+
+// knowledgeBaseName is the name given in the options, or the default one
+func knowledgeBaseName(ctx *bot.Context) string {
+	if option, ok := ctx.Options[kbCommandOptionName.String()]; ok {
+		if name := strings.ToLower(strings.TrimSpace(option.StringValue())); name != "" {
+			return name
+		}
+	}
+	return defaultKnowledgeBase
+}
+
+// documentList lists the documents in lines up to maxLength characters in total
+func documentList(ctx *bot.Context, documents []kb.Document, maxLength int) string {
+	var builder strings.Builder
+	for i, document := range documents {
+		line := ctx.T("kb.document", document.Name, document.Chunks, document.UserID) + "\n"
+		more := ctx.T("kb.list.more", len(documents)-i)
+		if builder.Len()+len(line)+len(more) > maxLength {
+			builder.WriteString(more)
+			break
+		}
+		builder.WriteString(line)
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+func download(client *http.Client, url string, maxSize int) ([]byte, error) {
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+func addHandler(ctx *bot.Context, knowledgeBases *kb.Store, requestQueue *queue.Queue) {
+	option, ok := ctx.Options[kbCommandOptionFile.String()]
+	if !ok {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse file option")
+		failed(ctx, ctx.T("error.title"), ctx.T("kb.fileOption"))
+		return
+	}
+	attachment := ctx.Interaction.ApplicationCommandData().Resolved.Attachments[option.Value.(string)]
+	name := knowledgeBaseName(ctx)
+	if !kb.ValidName(name) {
+		failed(ctx, ctx.T("kb.addFailed"), kb.ErrInvalidName.Error())
+		return
+	}
+	if attachment.Size > knowledgeBases.MaxFileSize() {
+		failed(ctx, ctx.T("kb.addFailed"), ctx.T("kb.tooBig", attachment.Filename, knowledgeBases.MaxFileSize()))
+		return
+	}
+
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+		return
+	}
+
+	data, err := download(ctx.Client, attachment.URL, knowledgeBases.MaxFileSize())
+	if err != nil {
+		ctx.Logger.Error("Failed to download document", "error", err)
+		editFailed(ctx, ctx.T("error.attachment"), fmt.Sprintf("`%s`: %v", attachment.Filename, err))
+		return
+	}
+
+	ctx.Logger.Info("Knowledge base document add invoked", "kb", name, "file", attachment.Filename, "size", len(data))
+	request := queue.Request{
+		UserID: ctx.Interaction.Member.User.ID,
+		OnPosition: func(position int) {
+			content := ctx.T("queue.started")
+			if position > 0 {
+				content = ctx.T("queue.position", position)
+			}
+			if err := ctx.Edit(content); err != nil {
+				ctx.Logger.Error("Failed to update queue position", "error", err)
+			}
+		},
+	}
+	var documents []kb.Document
+	err = requestQueue.Do(ctx.Context(), request, func() (err error) {
+		documents, err = knowledgeBases.Add(ctx.Context(), ctx.Interaction.GuildID, name, ctx.Interaction.Member.User.ID, attachment.Filename, data)
+		return err
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to add document", "kb", name, "error", err)
+		editFailed(ctx, ctx.T("kb.addFailed"), err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Knowledge base documents added", "kb", name, "documents", len(documents))
+	editResponse(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("kb.added.title", name),
+		Description: documentList(ctx, documents, kbFieldValueMaxLength),
+		Color:       kbEmbedColor,
+		Footer: &discord.MessageEmbedFooter{
+			Text: ctx.T("kb.added"),
+		},
+	})
+}
+
+func listHandler(ctx *bot.Context, knowledgeBases *kb.Store) {
+	list, err := knowledgeBases.List(ctx.Interaction.GuildID)
+	if err != nil {
+		ctx.Logger.Error("Failed to list knowledge bases", "error", err)
+		failed(ctx, ctx.T("error.title"), err.Error())
+		return
+	}
+	if len(list) == 0 {
+		respond(ctx, &discord.MessageEmbed{
+			Title:       ctx.T("kb.list.title"),
+			Description: ctx.T("kb.list.empty"),
+			Color:       kbEmbedColor,
+		}, true)
+		return
+	}
+
+	fields := make([]*discord.MessageEmbedField, 0, min(len(list), kbFieldsMaxCount))
+	for _, knowledgeBase := range list[:min(len(list), kbFieldsMaxCount)] {
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  knowledgeBase.Name,
+			Value: documentList(ctx, knowledgeBase.Documents, kbFieldValueMaxLength),
+		})
+	}
+	respond(ctx, &discord.MessageEmbed{
+		Title:  ctx.T("kb.list.title"),
+		Color:  kbEmbedColor,
+		Fields: fields,
+	}, true)
+}
+
+func removeHandler(ctx *bot.Context, knowledgeBases *kb.Store) {
+	option, ok := ctx.Options[kbCommandOptionDocument.String()]
+	if !ok {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse document option")
+		failed(ctx, ctx.T("error.title"), ctx.T("kb.removeFailed"))
+		return
+	}
+	document := strings.TrimSpace(option.StringValue())
+	name := knowledgeBaseName(ctx)
+
+	member := ctx.Interaction.Member
+	removed, err := knowledgeBases.Remove(ctx.Interaction.GuildID, name, document, func(d kb.Document) bool {
+		return d.UserID == member.User.ID || member.Permissions&discord.PermissionManageServer != 0
+	})
+	if err != nil {
+		ctx.Logger.Info("Failed to remove document", "kb", name, "document", document, "error", err)
+		failed(ctx, ctx.T("kb.removeFailed"), err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Knowledge base documents removed", "kb", name, "documents", len(removed))
+	respond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("kb.removed.title", name),
+		Description: documentList(ctx, removed, kbFieldValueMaxLength),
+		Color:       kbEmbedColor,
+	}, false)
+}
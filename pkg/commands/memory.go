@@ -0,0 +1,35 @@
+package commands
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands/recall"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/ratelimit"
+)
+
+const memoryCommandName = "memory"
+
+type MemoryCommandParams struct {
+	Memory      *memory.Store
+	RateLimiter *ratelimit.Limiter
+}
+
+func MemoryCommand(params *MemoryCommandParams) *bot.Command {
+	return &bot.Command{
+		Name:                     memoryCommandName,
+		Description:              i18n.T(i18n.Default, "memory.description"),
+		DescriptionLocalizations: i18n.Localizations("memory.description"),
+		DMPermission:             false,
+		DefaultMemberPermissions: discord.PermissionViewChannel,
+		Type:                     discord.ChatApplicationCommand,
+		Middlewares:              []bot.Handler{params.RateLimiter},
+		SubCommands: bot.NewRouter([]*bot.Command{
+			recall.AddCommand(params.Memory),
+			recall.ListCommand(params.Memory),
+			recall.ForgetCommand(params.Memory),
+			recall.LearnCommand(params.Memory),
+		}),
+	}
+}
@@ -0,0 +1,108 @@
+package commands_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/commands"
+	"github.com/raikerian/go-remai-bot-discord/pkg/fake"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/sashabaranov/go-openai"
+)
+
+func newMemoryBot(t *testing.T) (*testBot, *memory.Store) {
+	memories, err := memory.New(memory.Config{File: filepath.Join(t.TempDir(), "memory.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := newChatBot(t, nil, memories)
+	b.router.Register(commands.MemoryCommand(&commands.MemoryCommandParams{Memory: memories}))
+	return b, memories
+}
+
+func TestMemoryChat(t *testing.T) {
+	b, memories := newMemoryBot(t)
+	b.openai.Chat = func(openai.ChatCompletionRequest) (string, error) {
+		return "Noted", nil
+	}
+	b.openai.ToolCalls = func(request openai.ChatCompletionRequest) []openai.ToolCall {
+		if last := request.Messages[len(request.Messages)-1]; last.Role != openai.ChatMessageRoleUser || !strings.Contains(last.Content, "remember") {
+			return nil
+		}
+		return []openai.ToolCall{{
+			ID:       "call_1",
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: "remember", Arguments: `{"fact":"Prefers tabs"}`},
+		}}
+	}
+
+	i := b.command("memory", fake.SubCommand("add", fake.Option("fact", "Uses Go with PostgreSQL")))
+	if got := embedText(b.discord.Response(i)); !strings.Contains(got, "Remembered\nUses Go with PostgreSQL") {
+		t.Fatalf("add response = %q, want the remembered fact", got)
+	}
+
+	i = b.command("chat", fake.SubCommand("gpt", fake.Option("prompt", "Hello"), fake.Option("context", "Be brief")))
+	b.discord.Response(i)
+	requests := b.openai.ChatRequests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d chat requests, want 1", len(requests))
+	}
+	if system := requests[0].Messages[0].Content; !strings.HasPrefix(system, "Be brief\n\nFacts about the user") || !strings.HasSuffix(system, "\n- Uses Go with PostgreSQL") {
+		t.Errorf("system message = %q, want the context and the fact", system)
+	}
+	if len(requests[0].Tools) != 0 {
+		t.Errorf("request offers %d tools without learning enabled", len(requests[0].Tools))
+	}
+
+	i = b.command("memory", fake.SubCommand("learn", fake.Option("enabled", true)))
+	if got := embedText(b.discord.Response(i)); !strings.Contains(got, "will remember facts") {
+		t.Errorf("learn response = %q, want learning enabled", got)
+	}
+	thread := b.discord.Threads(b.channel.ID)[0]
+	b.discord.Send(thread.ID, b.user, "Please remember that I prefer tabs")
+
+	requests = b.openai.ChatRequests()
+	if len(requests) != 3 {
+		t.Fatalf("got %d chat requests, want 3", len(requests))
+	}
+	if len(requests[1].Tools) != 1 || requests[1].Tools[0].Function.Name != "remember" {
+		t.Errorf("request tools = %v, want remember", requests[1].Tools)
+	}
+	if last := requests[2].Messages[len(requests[2].Messages)-1]; last.Role != openai.ChatMessageRoleTool || last.ToolCallID != "call_1" || last.Content != "Remembered" {
+		t.Errorf("last message after tool call = %q, want the tool result", chatRoles([]openai.ChatCompletionMessage{last}))
+	}
+	threadMessages := b.discord.Messages(thread.ID)
+	if got := embedText(threadMessages[len(threadMessages)-1]); !strings.Contains(got, "🧠 Remembered: Prefers tabs") {
+		t.Errorf("answer embed = %q, want the remembered fact", got)
+	}
+	i = b.command("memory", fake.SubCommand("list"))
+	if got := embedText(b.discord.Response(i)); !strings.Contains(got, "`1` Uses Go with PostgreSQL\n`2` Prefers tabs *(learned in a conversation)*") {
+		t.Errorf("list response = %q, want both facts", got)
+	}
+
+	// Other users do not get the memory of the user who started the conversation, nor teach it
+	other := b.discord.AddUser("other")
+	b.discord.Send(thread.ID, other, "Please remember that I prefer spaces")
+	requests = b.openai.ChatRequests()
+	if len(requests[3].Tools) != 0 {
+		t.Errorf("request of another user offers %d tools", len(requests[3].Tools))
+	}
+	if facts := memories.Get(other.ID).Facts; len(facts) != 0 {
+		t.Errorf("other user facts = %v, want none", facts)
+	}
+
+	// Forgetting takes effect in existing conversations
+	i = b.command("memory", fake.SubCommand("forget"))
+	if got := embedText(b.discord.Response(i)); !strings.Contains(got, "Everything remembered about you was erased") {
+		t.Errorf("forget response = %q, want memory erased", got)
+	}
+	b.discord.Send(thread.ID, b.user, "How are you?")
+	requests = b.openai.ChatRequests()
+	if system := requests[len(requests)-1].Messages[0].Content; system != "Be brief" {
+		t.Errorf("system message after forgetting = %q, want only the context", system)
+	}
+	if u := memories.Get(b.user.ID); len(u.Facts) != 0 || u.Learn {
+		t.Errorf("memory after forgetting = %+v, want none", u)
+	}
+}
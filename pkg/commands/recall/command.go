@@ -0,0 +1,90 @@
+package recall
+
+import (
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+)
+
+const (
+	addCommandName    = "add"
+	listCommandName   = "list"
+	forgetCommandName = "forget"
+	learnCommandName  = "learn"
+)
+
+func AddCommand(memories *memory.Store) *bot.Command {
+	return &bot.Command{
+		Name:                     addCommandName,
+		Description:              i18n.T(i18n.Default, "memory.add.description"),
+		DescriptionLocalizations: i18n.Localizations("memory.add.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionString,
+				Name:                     memoryCommandOptionFact.String(),
+				Description:              i18n.T(i18n.Default, "memory.add.option.fact"),
+				DescriptionLocalizations: i18n.Localizations("memory.add.option.fact"),
+				Required:                 true,
+			},
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			addHandler(ctx, memories)
+		}),
+	}
+}
+
+func ListCommand(memories *memory.Store) *bot.Command {
+	return &bot.Command{
+		Name:                     listCommandName,
+		Description:              i18n.T(i18n.Default, "memory.list.description"),
+		DescriptionLocalizations: i18n.Localizations("memory.list.description"),
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			listHandler(ctx, memories)
+		}),
+	}
+}
+
+// ForgetCommand forgets a fact, or erases the whole memory of the user
+func ForgetCommand(memories *memory.Store) *bot.Command {
+	numberOptionMinValue := 1.0
+	return &bot.Command{
+		Name:                     forgetCommandName,
+		Description:              i18n.T(i18n.Default, "memory.forget.description"),
+		DescriptionLocalizations: i18n.Localizations("memory.forget.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionInteger,
+				Name:                     memoryCommandOptionNumber.String(),
+				Description:              i18n.T(i18n.Default, "memory.forget.option.number"),
+				DescriptionLocalizations: i18n.Localizations("memory.forget.option.number"),
+				MinValue:                 &numberOptionMinValue,
+				Required:                 false,
+			},
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			forgetHandler(ctx, memories)
+		}),
+	}
+}
+
+// LearnCommand lets the model remember facts on its own during conversations of the user
+func LearnCommand(memories *memory.Store) *bot.Command {
+	return &bot.Command{
+		Name:                     learnCommandName,
+		Description:              i18n.T(i18n.Default, "memory.learn.description"),
+		DescriptionLocalizations: i18n.Localizations("memory.learn.description"),
+		Options: []*discord.ApplicationCommandOption{
+			{
+				Type:                     discord.ApplicationCommandOptionBoolean,
+				Name:                     memoryCommandOptionEnabled.String(),
+				Description:              i18n.T(i18n.Default, "memory.learn.option.enabled"),
+				DescriptionLocalizations: i18n.Localizations("memory.learn.option.enabled"),
+				Required:                 true,
+			},
+		},
+		Handler: bot.HandlerFunc(func(ctx *bot.Context) {
+			learnHandler(ctx, memories)
+		}),
+	}
+}
@@ -0,0 +1,23 @@
+package recall
+
+import "fmt"
+
+type memoryCommandOptionType uint8
+
+const (
+	memoryCommandOptionFact    memoryCommandOptionType = 1
+	memoryCommandOptionNumber  memoryCommandOptionType = 2
+	memoryCommandOptionEnabled memoryCommandOptionType = 3
+)
+
+func (t memoryCommandOptionType) String() string {
+	switch t {
+	case memoryCommandOptionFact:
+		return "fact"
+	case memoryCommandOptionNumber:
+		return "number"
+	case memoryCommandOptionEnabled:
+		return "enabled"
+	}
+	return fmt.Sprintf("ApplicationCommandOptionType(%d)", t)
+}
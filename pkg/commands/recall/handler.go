@@ -0,0 +1,160 @@
+package recall
+
+import (
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+)
+
+const (
+	memoryEmbedColor = 0x5865f2
+	// due to discord embed description limitation
+	memoryDescriptionMaxLength = 4096
+)
+
+// respond replies to the member only, memory is nobody else's business
+func respond(ctx *bot.Context, embed *discord.MessageEmbed) {
+	err := ctx.Respond(&discord.InteractionResponse{
+		Type: discord.InteractionResponseChannelMessageWithSource,
+		Data: &discord.InteractionResponseData{
+			Flags:  discord.MessageFlagsEphemeral,
+			Embeds: []*discord.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		ctx.Logger.Error("Failed to respond to interaction", "error", err)
+	}
+}
+
+func failed(ctx *bot.Context, description string) {
+	respond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("memory.failed"),
+		Description: description,
+		Color:       0xff0000,
+	})
+}
+
+func factLine(ctx *bot.Context, number int, fact memory.Fact) string {
+	if fact.Learned {
+		return ctx.T("memory.fact.learned", number, fact.Text)
+	}
+	return ctx.T("memory.fact", number, fact.Text)
+}
+
+func addHandler(ctx *bot.Context, memories *memory.Store) {
+	option, ok := ctx.Options[memoryCommandOptionFact.String()]
+	if !ok {
+		// this should not happen, discord prevents empty required options
+		ctx.Logger.Error("Failed to parse fact option")
+		failed(ctx, memory.ErrEmpty.Error())
+		return
+	}
+
+	userID := ctx.Interaction.Member.User.ID
+	fact, err := memories.Add(userID, option.StringValue(), false)
+	if err != nil {
+		ctx.Logger.Info("Failed to remember fact", "error", err)
+		failed(ctx, err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Fact about the user remembered")
+	respond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("memory.added.title"),
+		Description: fact.Text,
+		Color:       memoryEmbedColor,
+		Footer: &discord.MessageEmbedFooter{
+			Text: ctx.T("memory.added"),
+		},
+	})
+}
+
+func listHandler(ctx *bot.Context, memories *memory.Store) {
+	user := memories.Get(ctx.Interaction.Member.User.ID)
+	footer := ctx.T("memory.learnOff")
+	if user.Learn {
+		footer = ctx.T("memory.learnOn")
+	}
+	if len(user.Facts) == 0 {
+		respond(ctx, &discord.MessageEmbed{
+			Title:       ctx.T("memory.list.title"),
+			Description: ctx.T("memory.list.empty"),
+			Color:       memoryEmbedColor,
+			Footer:      &discord.MessageEmbedFooter{Text: footer},
+		})
+		return
+	}
+
+	var builder strings.Builder
+	for i, fact := range user.Facts {
+		line := factLine(ctx, i+1, fact) + "\n"
+		more := ctx.T("memory.list.more", len(user.Facts)-i)
+		if builder.Len()+len(line)+len(more) > memoryDescriptionMaxLength {
+			builder.WriteString(more)
+			break
+		}
+		builder.WriteString(line)
+	}
+	respond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("memory.list.title"),
+		Description: strings.TrimSpace(builder.String()),
+		Color:       memoryEmbedColor,
+		Footer:      &discord.MessageEmbedFooter{Text: footer},
+	})
+}
+
+func forgetHandler(ctx *bot.Context, memories *memory.Store) {
+	userID := ctx.Interaction.Member.User.ID
+	option, ok := ctx.Options[memoryCommandOptionNumber.String()]
+	if !ok {
+		if err := memories.Erase(userID); err != nil {
+			ctx.Logger.Error("Failed to erase memory", "error", err)
+			failed(ctx, err.Error())
+			return
+		}
+		ctx.Logger.Info("Memory of the user erased")
+		respond(ctx, &discord.MessageEmbed{
+			Title:       ctx.T("memory.forgotten.title"),
+			Description: ctx.T("memory.erased"),
+			Color:       memoryEmbedColor,
+		})
+		return
+	}
+
+	number := int(option.IntValue())
+	fact, err := memories.Forget(userID, number)
+	if err != nil {
+		ctx.Logger.Info("Failed to forget fact", "number", number, "error", err)
+		failed(ctx, err.Error())
+		return
+	}
+	ctx.Logger.Info("Fact about the user forgotten")
+	respond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("memory.forgotten.title"),
+		Description: fact.Text,
+		Color:       memoryEmbedColor,
+	})
+}
+
+func learnHandler(ctx *bot.Context, memories *memory.Store) {
+	option, ok := ctx.Options[memoryCommandOptionEnabled.String()]
+	learn := ok && option.BoolValue()
+	if err := memories.SetLearn(ctx.Interaction.Member.User.ID, learn); err != nil {
+		ctx.Logger.Error("Failed to change memory learning", "error", err)
+		failed(ctx, err.Error())
+		return
+	}
+
+	ctx.Logger.Info("Memory learning changed", "learn", learn)
+	description := ctx.T("memory.learnDisabled")
+	if learn {
+		description = ctx.T("memory.learnEnabled")
+	}
+	respond(ctx, &discord.MessageEmbed{
+		Title:       ctx.T("memory.title"),
+		Description: description,
+		Color:       memoryEmbedColor,
+	})
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/archive"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+	"github.com/raikerian/go-remai-bot-discord/pkg/kb"
+	"github.com/raikerian/go-remai-bot-discord/pkg/logging"
+	"github.com/raikerian/go-remai-bot-discord/pkg/memory"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/monitoring"
+	"github.com/raikerian/go-remai-bot-discord/pkg/queue"
+	"github.com/raikerian/go-remai-bot-discord/pkg/ratelimit"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	DefaultFile         = "credentials.yaml"
+	DefaultSettingsFile = "settings.json"
+	// How long shutdown waits for interactions and messages in progress
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+type DiscordConfig struct {
+	// Bot access token
+	Token string `yaml:"token"`
+	// Test guild ID. If not specified - bot registers commands globally
+	Guild string `yaml:"guild"`
+	// More guilds to register commands in, along with guild
+	Guilds []string `yaml:"guilds"`
+	// Register commands globally as well as in the guilds
+	Global bool `yaml:"global"`
+	// Remove all commands after shutdown
+	RemoveCommands bool `yaml:"removeCommands"`
+	// How long to wait for interactions and messages in progress on shutdown before cancelling them
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// Gateway shards, and which of them are run by this process
+	Shards bot.ShardsConfig `yaml:"shards"`
+}
+
+// CommandGuilds lists guilds to register commands in, empty guild ID stands for global commands
+func (c *DiscordConfig) CommandGuilds() []string {
+	var guilds []string
+	if c.Guild != "" {
+		guilds = append(guilds, c.Guild)
+	}
+	for _, guild := range c.Guilds {
+		if guild != c.Guild {
+			guilds = append(guilds, guild)
+		}
+	}
+	if c.Global || len(guilds) == 0 {
+		guilds = append(guilds, "")
+	}
+	return guilds
+}
+
+type OpenAIConfig struct {
+	APIKey string `yaml:"apiKey"`
+	// Enabled chat models, first one is default
+	CompletionModels []string `yaml:"completionModels"`
+}
+
+type ImagesConfig struct {
+	Archive archive.Config `yaml:"archive"`
+}
+
+type SettingsConfig struct {
+	// File that persists per-guild settings changed with /admin config
+	File string `yaml:"file"`
+}
+
+type Config struct {
+	Discord        DiscordConfig     `yaml:"discord"`
+	OpenAI         OpenAIConfig      `yaml:"openAI" env:"OPENAI"`
+	Moderation     moderation.Config `yaml:"moderation"`
+	Images         ImagesConfig      `yaml:"images"`
+	Settings       SettingsConfig    `yaml:"settings"`
+	RateLimits     ratelimit.Config  `yaml:"rateLimits"`
+	Queue          queue.Config      `yaml:"queue"`
+	Logging        logging.Config    `yaml:"logging"`
+	Monitoring     monitoring.Config `yaml:"monitoring"`
+	Tracing        tracing.Config    `yaml:"tracing"`
+	KnowledgeBases kb.Config         `yaml:"knowledgeBases"`
+	Memory         memory.Config     `yaml:"memory"`
+}
+
+// Load reads config from the file, applies environment variable overrides and validates the result.
+// Missing file is only an error if it is required, otherwise config comes from environment variables alone
+func Load(file string, required bool) (*Config, error) {
+	c := &Config{}
+	if err := c.ReadFromFile(file); err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if err := c.ReadFromEnv(); err != nil {
+		return nil, err
+	}
+	if c.Settings.File == "" {
+		c.Settings.File = DefaultSettingsFile
+	}
+	if c.Discord.ShutdownTimeout == 0 {
+		c.Discord.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadFromFile reads config from YAML file. Unknown keys are treated as errors to catch typos early
+func (c *Config) ReadFromFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	err = yaml.UnmarshalStrict(data, c)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// ReadFromEnv overrides config fields with environment variables, see readEnv
+func (c *Config) ReadFromEnv() error {
+	return readEnv(c, envPrefix, os.LookupEnv)
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const envPrefix = "REMAI"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// readEnv overrides struct fields with environment variables named after their YAML path, e.g. `discord.token`
+// is REMAI_DISCORD_TOKEN and `moderation.default.failClosed` is REMAI_MODERATION_DEFAULT_FAIL_CLOSED.
+// `env` tag replaces the name derived from YAML key. Lists are comma-separated, durations are like 1m30s, maps cannot be overridden
+func readEnv(v any, prefix string, lookup func(string) (string, bool)) error {
+	value := reflect.ValueOf(v).Elem()
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := field.Tag.Get("env")
+		if name == "" {
+			name = envName(strings.Split(field.Tag.Get("yaml"), ",")[0])
+		}
+		name = prefix + "_" + name
+
+		fieldValue := value.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			if err := readEnv(fieldValue.Addr().Interface(), name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		env, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fieldValue, env); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// envName converts camelCase YAML key to SCREAMING_SNAKE_CASE
+func envName(key string) string {
+	var builder strings.Builder
+	for i, r := range key {
+		if i > 0 && unicode.IsUpper(r) {
+			builder.WriteRune('_')
+		}
+		builder.WriteRune(unicode.ToUpper(r))
+	}
+	return builder.String()
+}
+
+func setValue(value reflect.Value, env string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(env)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int64:
+		if value.Type() == durationType {
+			d, err := time.ParseDuration(env)
+			if err != nil {
+				return err
+			}
+			value.SetInt(int64(d))
+			break
+		}
+		fallthrough
+	case reflect.Int:
+		n, err := strconv.ParseInt(env, 10, 64)
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		kind := value.Type().Elem().Kind()
+		if kind != reflect.String && kind != reflect.Int {
+			return fmt.Errorf("unsupported list type %s", value.Type())
+		}
+		items := reflect.MakeSlice(value.Type(), 0, 0)
+		for _, item := range strings.Split(env, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = reflect.Append(items, reflect.Zero(value.Type().Elem()))
+				if err := setValue(items.Index(items.Len()-1), item); err != nil {
+					return err
+				}
+			}
+		}
+		value.Set(items)
+	default:
+		return fmt.Errorf("cannot be set from environment, type %s is not supported", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Chat completion models supported by the bot
+var knownCompletionModels = []string{
+	openai.GPT4o,
+	openai.GPT4o20240513,
+	openai.GPT4Turbo,
+	openai.GPT4Turbo20240409,
+	openai.GPT4Turbo0125,
+	openai.GPT4Turbo1106,
+	openai.GPT4TurboPreview,
+	openai.GPT4VisionPreview,
+	openai.GPT4,
+	openai.GPT40613,
+	openai.GPT40314,
+	openai.GPT432K,
+	openai.GPT432K0613,
+	openai.GPT432K0314,
+	openai.GPT3Dot5Turbo,
+	openai.GPT3Dot5Turbo0125,
+	openai.GPT3Dot5Turbo1106,
+	openai.GPT3Dot5Turbo0613,
+	openai.GPT3Dot5Turbo0301,
+	openai.GPT3Dot5Turbo16K,
+	openai.GPT3Dot5Turbo16K0613,
+}
+
+// IsSnowflake reports whether s looks like a Discord ID
+func IsSnowflake(s string) bool {
+	if len(s) < 17 || len(s) > 20 {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the whole config and reports all problems at once
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if strings.TrimSpace(c.Discord.Token) == "" {
+		fail("discord.token", "is required")
+	}
+	if c.Discord.Guild != "" && !IsSnowflake(c.Discord.Guild) {
+		fail("discord.guild", "%q is not a valid guild ID", c.Discord.Guild)
+	}
+	guildSeen := make(map[string]struct{}, len(c.Discord.Guilds))
+	for i, guild := range c.Discord.Guilds {
+		field := fmt.Sprintf("discord.guilds[%d]", i)
+		if !IsSnowflake(guild) {
+			fail(field, "%q is not a valid guild ID", guild)
+		}
+		if _, ok := guildSeen[guild]; ok {
+			fail(field, "duplicate guild %q", guild)
+		}
+		guildSeen[guild] = struct{}{}
+	}
+	if c.Discord.ShutdownTimeout < 0 {
+		fail("discord.shutdownTimeout", "%s must not be negative", c.Discord.ShutdownTimeout)
+	}
+	if err := c.Discord.Shards.Validate(); err != nil {
+		errs = append(errs, prefixErrors("discord.shards", err)...)
+	}
+
+	seen := make(map[string]struct{}, len(c.OpenAI.CompletionModels))
+	for i, model := range c.OpenAI.CompletionModels {
+		field := fmt.Sprintf("openAI.completionModels[%d]", i)
+		if !slices.Contains(knownCompletionModels, model) {
+			fail(field, "unknown model %q, supported models: %s", model, strings.Join(knownCompletionModels, ", "))
+		}
+		if _, ok := seen[model]; ok {
+			fail(field, "duplicate model %q", model)
+		}
+		seen[model] = struct{}{}
+	}
+
+	if err := c.Moderation.Validate(); err != nil {
+		errs = append(errs, prefixErrors("moderation", err)...)
+	}
+	guilds := make([]string, 0, len(c.Moderation.Guilds))
+	for guild := range c.Moderation.Guilds {
+		guilds = append(guilds, guild)
+	}
+	sort.Strings(guilds)
+	for _, guild := range guilds {
+		if !IsSnowflake(guild) {
+			fail("moderation.guilds", "%q is not a valid guild ID", guild)
+		}
+		if channel := c.Moderation.Guilds[guild].LogChannel; channel != "" && !IsSnowflake(channel) {
+			fail("moderation.guilds."+guild+".logChannel", "%q is not a valid channel ID", channel)
+		}
+	}
+	if channel := c.Moderation.Default.LogChannel; channel != "" && !IsSnowflake(channel) {
+		fail("moderation.default.logChannel", "%q is not a valid channel ID", channel)
+	}
+
+	if err := c.Images.Archive.Validate(); err != nil {
+		errs = append(errs, prefixErrors("images.archive", err)...)
+	}
+
+	if err := c.RateLimits.Validate(); err != nil {
+		errs = append(errs, prefixErrors("rateLimits", err)...)
+	}
+
+	if err := c.Queue.Validate(); err != nil {
+		errs = append(errs, prefixErrors("queue", err)...)
+	}
+
+	if err := c.Logging.Validate(); err != nil {
+		errs = append(errs, prefixErrors("logging", err)...)
+	}
+
+	if err := c.Monitoring.Validate(); err != nil {
+		errs = append(errs, prefixErrors("monitoring", err)...)
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, prefixErrors("tracing", err)...)
+	}
+
+	if err := c.KnowledgeBases.Validate(); err != nil {
+		errs = append(errs, prefixErrors("knowledgeBases", err)...)
+	}
+
+	if err := c.Memory.Validate(); err != nil {
+		errs = append(errs, prefixErrors("memory", err)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+// prefixErrors unwraps joined errors and prefixes each one with the field path
+func prefixErrors(prefix string, err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{fmt.Errorf("%s.%w", prefix, err)}
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, prefixErrors(prefix, err)...)
+	}
+	return errs
+}
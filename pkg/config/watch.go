@@ -0,0 +1,108 @@
+package config
+
+import (
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Editors often save files in several steps (truncate, write, rename), wait for them to settle
+const watchDebounce = 500 * time.Millisecond
+
+// Watch calls onChange every time the config file is written, created or replaced.
+// Directory of the file is watched rather than the file itself, so atomic renames are noticed too
+func Watch(file string, onChange func()) (stop func() error, err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(file)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || !event.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(watchDebounce, onChange)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Error("Config watcher failed", "error", err)
+			}
+		}
+	}()
+
+	return watcher.Close, nil
+}
+
+// RestartRequired lists settings that differ between configs, but can only be applied by restarting the bot
+func RestartRequired(old *Config, new *Config) (fields []string) {
+	if old.Discord.Token != new.Discord.Token {
+		fields = append(fields, "discord.token")
+	}
+	if old.Discord.Guild != new.Discord.Guild {
+		fields = append(fields, "discord.guild")
+	}
+	if !slices.Equal(old.Discord.Guilds, new.Discord.Guilds) {
+		fields = append(fields, "discord.guilds")
+	}
+	if old.Discord.Global != new.Discord.Global {
+		fields = append(fields, "discord.global")
+	}
+	if old.Discord.RemoveCommands != new.Discord.RemoveCommands {
+		fields = append(fields, "discord.removeCommands")
+	}
+	if old.Discord.ShutdownTimeout != new.Discord.ShutdownTimeout {
+		fields = append(fields, "discord.shutdownTimeout")
+	}
+	if !reflect.DeepEqual(old.Discord.Shards, new.Discord.Shards) {
+		fields = append(fields, "discord.shards")
+	}
+	if old.OpenAI.APIKey != new.OpenAI.APIKey {
+		fields = append(fields, "openAI.apiKey")
+	}
+	if !reflect.DeepEqual(old.Images.Archive, new.Images.Archive) {
+		fields = append(fields, "images.archive")
+	}
+	if old.Settings.File != new.Settings.File {
+		fields = append(fields, "settings.file")
+	}
+	if old.Logging.Format != new.Logging.Format {
+		fields = append(fields, "logging.format")
+	}
+	if old.Monitoring != new.Monitoring {
+		fields = append(fields, "monitoring.listen")
+	}
+	if old.Tracing != new.Tracing {
+		fields = append(fields, "tracing")
+	}
+	if old.KnowledgeBases != new.KnowledgeBases {
+		fields = append(fields, "knowledgeBases")
+	}
+	if old.Memory != new.Memory {
+		fields = append(fields, "memory")
+	}
+	return
+}
@@ -0,0 +1,965 @@
+// Package fake provides in-memory stand-ins for Discord and OpenAI, so commands can be tested offline
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+// First ID handed out, IDs are increasing like snowflakes are
+const firstID = 100000000000000000
+
+const cdnAttachmentsURL = "https://cdn.discordapp.com/attachments/"
+
+// Discord is an in-memory stand-in for Discord REST API and gateway. Its session sends REST requests to the fake,
+// which records messages, threads, reactions and thread locks. Changes of channels are applied to the session
+// state, and events are delivered to handlers as the gateway would deliver them
+type Discord struct {
+	// Session talks to the fake instead of Discord
+	Session *discord.Session
+	// Bot is the user of the session
+	Bot *discord.User
+
+	t *testing.T
+
+	mu           sync.Mutex
+	nextID       int64
+	channels     map[string]*discord.Channel
+	messages     map[string][]*discord.Message
+	locks        map[string][]bool
+	interactions map[string]*interaction
+	attachments  map[string][]byte
+	commands     map[string][]*discord.ApplicationCommand
+
+	handlersMu          sync.Mutex
+	interactionHandlers []func(*discord.Session, *discord.InteractionCreate)
+	messageHandlers     []func(*discord.Session, *discord.MessageCreate)
+}
+
+type interaction struct {
+	*discord.Interaction
+	// Deferred responses are filled in by the first follow up message
+	deferred bool
+	original *discord.Message
+}
+
+// NewDiscord returns a fake with the bot user logged in. Requests the fake does not support fail the test
+func NewDiscord(t *testing.T) *Discord {
+	d := &Discord{
+		t:            t,
+		nextID:       firstID,
+		channels:     make(map[string]*discord.Channel),
+		messages:     make(map[string][]*discord.Message),
+		locks:        make(map[string][]bool),
+		interactions: make(map[string]*interaction),
+		attachments:  make(map[string][]byte),
+		commands:     make(map[string][]*discord.ApplicationCommand),
+	}
+	d.Bot = &discord.User{ID: d.newID(), Username: "bot", Bot: true}
+
+	session, err := discord.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Client = &http.Client{Transport: transport{d}}
+	session.State.User = d.Bot
+	d.Session = session
+	return d
+}
+
+// transport serves requests to Discord hosts with the fake, other requests go to the network
+type transport struct {
+	d *Discord
+}
+
+func (t transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host != "discord.com" && r.URL.Host != "cdn.discordapp.com" {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+	recorder := httptest.NewRecorder()
+	t.d.ServeHTTP(recorder, r)
+	return recorder.Result(), nil
+}
+
+func (d *Discord) newID() string {
+	d.nextID++
+	return strconv.FormatInt(d.nextID, 10)
+}
+
+// AddHandler adds a handler of interaction or message create events, see discord.Session.AddHandler
+func (d *Discord) AddHandler(handler any) {
+	d.handlersMu.Lock()
+	defer d.handlersMu.Unlock()
+
+	switch h := handler.(type) {
+	case func(*discord.Session, *discord.InteractionCreate):
+		d.interactionHandlers = append(d.interactionHandlers, h)
+	case func(*discord.Session, *discord.MessageCreate):
+		d.messageHandlers = append(d.messageHandlers, h)
+	default:
+		d.t.Fatalf("unsupported handler %T", handler)
+	}
+}
+
+// AddGuild adds a guild to the session state
+func (d *Discord) AddGuild(locale discord.Locale) *discord.Guild {
+	d.mu.Lock()
+	guild := &discord.Guild{ID: d.newID(), Name: "guild", PreferredLocale: string(locale)}
+	d.mu.Unlock()
+
+	if err := d.Session.State.GuildAdd(guild); err != nil {
+		d.t.Fatal(err)
+	}
+	return guild
+}
+
+// AddChannel adds a text channel of the guild
+func (d *Discord) AddChannel(guildID string, name string) *discord.Channel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch := &discord.Channel{ID: d.newID(), GuildID: guildID, Name: name, Type: discord.ChannelTypeGuildText}
+	d.setChannel(ch)
+	return copyChannel(ch)
+}
+
+// AddUser makes a user that can run commands and send messages
+func (d *Discord) AddUser(name string) *discord.User {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return &discord.User{ID: d.newID(), Username: name}
+}
+
+// Attach hosts a file on the CDN, so it can be an attachment option of a command
+func (d *Discord) Attach(name string, contentType string, data []byte) *discord.MessageAttachment {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.attach("0", name, contentType, data)
+}
+
+func (d *Discord) attach(channelID string, name string, contentType string, data []byte) *discord.MessageAttachment {
+	id := d.newID()
+	attachmentURL := cdnAttachmentsURL + channelID + "/" + id + "/" + name
+	d.attachments[attachmentURL] = data
+	return &discord.MessageAttachment{
+		ID:          id,
+		URL:         attachmentURL,
+		ProxyURL:    attachmentURL,
+		Filename:    name,
+		ContentType: contentType,
+		Size:        len(data),
+	}
+}
+
+// Command delivers a slash command interaction to the handlers and returns when they are done
+func (d *Discord) Command(guildID string, channelID string, user *discord.User, data discord.ApplicationCommandInteractionData) *discord.Interaction {
+	return d.interact(guildID, channelID, user, discord.InteractionApplicationCommand, data, nil)
+}
+
+// CommandData makes data of a slash command interaction, see SubCommand and Option
+func CommandData(name string, options ...*discord.ApplicationCommandInteractionDataOption) discord.ApplicationCommandInteractionData {
+	return discord.ApplicationCommandInteractionData{
+		ID:          "command-" + name,
+		Name:        name,
+		CommandType: discord.ChatApplicationCommand,
+		Options:     options,
+		Resolved:    &discord.ApplicationCommandInteractionDataResolved{},
+	}
+}
+
+// SubCommand makes a subcommand option with its options
+func SubCommand(name string, options ...*discord.ApplicationCommandInteractionDataOption) *discord.ApplicationCommandInteractionDataOption {
+	return &discord.ApplicationCommandInteractionDataOption{
+		Name:    name,
+		Type:    discord.ApplicationCommandOptionSubCommand,
+		Options: options,
+	}
+}
+
+// Option makes an option of the value type: string, int, float64 or bool. Values are stored as decoded from JSON
+func Option(name string, value any) *discord.ApplicationCommandInteractionDataOption {
+	option := &discord.ApplicationCommandInteractionDataOption{Name: name, Value: value}
+	switch v := value.(type) {
+	case string:
+		option.Type = discord.ApplicationCommandOptionString
+	case int:
+		option.Type = discord.ApplicationCommandOptionInteger
+		option.Value = float64(v)
+	case float64:
+		option.Type = discord.ApplicationCommandOptionNumber
+	case bool:
+		option.Type = discord.ApplicationCommandOptionBoolean
+	default:
+		panic(fmt.Sprintf("unsupported option value %T", value))
+	}
+	return option
+}
+
+// AttachmentOption adds the attachment to resolved data of the command and makes an option referring to it
+func AttachmentOption(data *discord.ApplicationCommandInteractionData, name string, attachment *discord.MessageAttachment) *discord.ApplicationCommandInteractionDataOption {
+	if data.Resolved.Attachments == nil {
+		data.Resolved.Attachments = make(map[string]*discord.MessageAttachment)
+	}
+	data.Resolved.Attachments[attachment.ID] = attachment
+	return &discord.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discord.ApplicationCommandOptionAttachment,
+		Value: attachment.ID,
+	}
+}
+
+// Click delivers a button interaction of the message to the handlers and returns when they are done
+func (d *Discord) Click(m *discord.Message, user *discord.User, customID string) *discord.Interaction {
+	data := discord.MessageComponentInteractionData{CustomID: customID, ComponentType: discord.ButtonComponent}
+	return d.interact(m.GuildID, m.ChannelID, user, discord.InteractionMessageComponent, data, m)
+}
+
+func (d *Discord) interact(guildID string, channelID string, user *discord.User, typ discord.InteractionType, data discord.InteractionData, m *discord.Message) *discord.Interaction {
+	d.mu.Lock()
+	i := &discord.Interaction{
+		ID:        d.newID(),
+		AppID:     d.Bot.ID,
+		Type:      typ,
+		Data:      data,
+		GuildID:   guildID,
+		ChannelID: channelID,
+		Message:   m,
+		Member:    &discord.Member{User: user, Permissions: discord.PermissionViewChannel},
+		Token:     "token-" + d.newID(),
+		Locale:    discord.EnglishUS,
+	}
+	if guild, err := d.Session.State.Guild(guildID); err == nil && guild.PreferredLocale != "" {
+		locale := discord.Locale(guild.PreferredLocale)
+		i.GuildLocale = &locale
+	}
+	d.interactions[i.Token] = &interaction{Interaction: i}
+	d.mu.Unlock()
+
+	d.handlersMu.Lock()
+	handlers := slices.Clone(d.interactionHandlers)
+	d.handlersMu.Unlock()
+	for _, handler := range handlers {
+		handler(d.Session, &discord.InteractionCreate{Interaction: i})
+	}
+	return i
+}
+
+// Send posts a message of the user to the channel, delivers it to the handlers and returns when they are done
+func (d *Discord) Send(channelID string, user *discord.User, content string) *discord.Message {
+	d.mu.Lock()
+	m := d.addMessage(channelID, user, &discord.Message{Content: content})
+	m = copyMessage(m)
+	d.mu.Unlock()
+
+	d.handlersMu.Lock()
+	handlers := slices.Clone(d.messageHandlers)
+	d.handlersMu.Unlock()
+	for _, handler := range handlers {
+		handler(d.Session, &discord.MessageCreate{Message: m})
+	}
+	return m
+}
+
+// Response returns the original response message of the interaction, nil if there is none
+func (d *Discord) Response(i *discord.Interaction) *discord.Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if it, ok := d.interactions[i.Token]; ok && it.original != nil {
+		return copyMessage(it.original)
+	}
+	return nil
+}
+
+// Messages lists messages of the channel, oldest first
+func (d *Discord) Messages(channelID string) []*discord.Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	messages := make([]*discord.Message, 0, len(d.messages[channelID]))
+	for _, m := range d.messages[channelID] {
+		messages = append(messages, copyMessage(m))
+	}
+	return messages
+}
+
+// Channel returns the channel or thread, nil if there is none
+func (d *Discord) Channel(channelID string) *discord.Channel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if ch, ok := d.channels[channelID]; ok {
+		return copyChannel(ch)
+	}
+	return nil
+}
+
+// Threads lists threads started in the channel, oldest first
+func (d *Discord) Threads(channelID string) (threads []*discord.Channel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, ch := range d.channels {
+		if ch.IsThread() && ch.ParentID == channelID {
+			threads = append(threads, copyChannel(ch))
+		}
+	}
+	slices.SortFunc(threads, func(a, b *discord.Channel) int { return compareIDs(a.ID, b.ID) })
+	return
+}
+
+// Locks lists lock states the thread was switched to, in order
+func (d *Discord) Locks(channelID string) []bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return slices.Clone(d.locks[channelID])
+}
+
+// ServeHTTP handles Discord REST API requests
+func (d *Discord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Host == "cdn.discordapp.com" {
+		d.serveAttachment(w, r)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v"+discord.APIVersion+"/")
+	route := r.Method + " " + path
+	var args []string
+	match := func(pattern string) bool {
+		args = matchRoute(pattern, route)
+		return args != nil
+	}
+
+	var resp any
+	var err *restError
+	switch {
+	case match("GET users/@me"):
+		resp = d.Bot
+	case match("POST interactions/*/*/callback"):
+		err = d.interactionCallback(r, args[1])
+	case match("POST webhooks/*/*"):
+		resp, err = d.followup(r, args[1])
+	case match("GET webhooks/*/*/messages/*"):
+		resp, err = d.webhookMessage(args[1], args[2])
+	case match("PATCH webhooks/*/*/messages/*"):
+		resp, err = d.webhookMessageEdit(r, args[1], args[2])
+	case match("DELETE webhooks/*/*/messages/*"):
+		err = d.webhookMessageDelete(args[1], args[2])
+	case match("GET channels/*"):
+		resp, err = d.channel(args[0])
+	case match("PATCH channels/*"):
+		resp, err = d.channelEdit(r, args[0])
+	case match("GET channels/*/messages"):
+		resp, err = d.channelMessages(r, args[0])
+	case match("POST channels/*/messages"):
+		resp, err = d.messageSend(r, args[0])
+	case match("GET channels/*/messages/*"):
+		resp, err = d.message(args[0], args[1])
+	case match("PATCH channels/*/messages/*"):
+		resp, err = d.messageEdit(r, args[0], args[1])
+	case match("DELETE channels/*/messages/*"):
+		err = d.messageDelete(args[0], args[1])
+	case match("PUT channels/*/messages/*/reactions/*/@me"):
+		err = d.reactionAdd(args[0], args[1], args[2])
+	case match("DELETE channels/*/messages/*/reactions/*"), match("DELETE channels/*/messages/*/reactions/*/*"):
+		err = d.reactionsRemove(args[0], args[1], args[2])
+	case match("POST channels/*/messages/*/threads"):
+		resp, err = d.threadStart(r, args[0], args[1])
+	case match("POST channels/*/typing"), match("PUT channels/*/thread-members/*"):
+		_, err = d.channel(args[0])
+	case match("GET guilds/*/threads/active"):
+		resp = d.activeThreads(args[0])
+	case match("GET applications/*/commands"), match("GET applications/*/guilds/*/commands"):
+		resp = d.applicationCommands(args)
+	case match("POST applications/*/commands"), match("POST applications/*/guilds/*/commands"):
+		resp, err = d.applicationCommandCreate(r, args)
+	case match("PATCH applications/*/commands/*"), match("PATCH applications/*/guilds/*/commands/*"):
+		resp, err = d.applicationCommandEdit(r, args)
+	case match("DELETE applications/*/commands/*"), match("DELETE applications/*/guilds/*/commands/*"):
+		err = d.applicationCommandDelete(args)
+	default:
+		d.t.Errorf("fake Discord does not support %s", route)
+		err = &restError{http.StatusNotFound, "404: Not Found"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(err.status)
+		json.NewEncoder(w).Encode(map[string]any{"message": err.message, "code": 0})
+		return
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+type restError struct {
+	status  int
+	message string
+}
+
+func notFound(what string, id string) *restError {
+	return &restError{http.StatusNotFound, fmt.Sprintf("Unknown %s %s", what, id)}
+}
+
+// matchRoute matches `METHOD path` against the pattern, `*` matches a path segment. Matched segments are returned
+func matchRoute(pattern string, route string) []string {
+	patternParts, routeParts := strings.Split(pattern, "/"), strings.Split(route, "/")
+	if len(patternParts) != len(routeParts) {
+		return nil
+	}
+	args := []string{}
+	for i, part := range patternParts {
+		switch {
+		case part == "*":
+			args = append(args, routeParts[i])
+		case part != routeParts[i]:
+			return nil
+		}
+	}
+	return args
+}
+
+func (d *Discord) serveAttachment(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	data, ok := d.attachments["https://cdn.discordapp.com"+r.URL.Path]
+	d.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(data)
+}
+
+// payload is the message part of a request body, with the list of fields that were set
+type payload struct {
+	discord.Message
+	set map[string]json.RawMessage
+}
+
+// readBody reads a JSON body, or `payload_json` and files of a multipart one, see discord.MultipartBodyWithJSON.
+// Files are hosted as attachments of the channel
+func (d *Discord) readBody(r *http.Request, channelID string) ([]byte, []*discord.MessageAttachment, *restError) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, nil, &restError{http.StatusBadRequest, err.Error()}
+		}
+		return data, nil, nil
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return nil, nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+	var files []*discord.MessageAttachment
+	for i := 0; ; i++ {
+		headers := r.MultipartForm.File[fmt.Sprintf("files[%d]", i)]
+		if len(headers) == 0 {
+			break
+		}
+		file, err := headers[0].Open()
+		if err != nil {
+			return nil, nil, &restError{http.StatusBadRequest, err.Error()}
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, nil, &restError{http.StatusBadRequest, err.Error()}
+		}
+		files = append(files, d.attach(channelID, headers[0].Filename, headers[0].Header.Get("Content-Type"), content))
+	}
+	return []byte(r.FormValue("payload_json")), files, nil
+}
+
+// decodePayload decodes message fields of a request body
+func decodePayload(data []byte, files []*discord.MessageAttachment) (*payload, *restError) {
+	if len(data) == 0 {
+		data = []byte("{}")
+	}
+	p := &payload{}
+	if err := json.Unmarshal(data, &p.set); err != nil {
+		return nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+	if err := json.Unmarshal(data, &p.Message); err != nil {
+		return nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+	p.Attachments = files
+	return p, nil
+}
+
+// readPayload reads and decodes message fields of a request body
+func (d *Discord) readPayload(r *http.Request, channelID string) (*payload, *restError) {
+	data, files, err := d.readBody(r, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return decodePayload(data, files)
+}
+
+// applyEdit changes fields of the message that are set in the payload
+func (p *payload) applyEdit(m *discord.Message) {
+	if _, ok := p.set["content"]; ok {
+		m.Content = p.Content
+	}
+	if _, ok := p.set["embeds"]; ok {
+		m.Embeds = p.Embeds
+	}
+	if _, ok := p.set["components"]; ok {
+		m.Components = p.Components
+	}
+	if _, ok := p.set["flags"]; ok {
+		m.Flags = p.Flags
+	}
+	if len(p.Attachments) > 0 {
+		m.Attachments = append(m.Attachments, p.Attachments...)
+	}
+	now := time.Now()
+	m.EditedTimestamp = &now
+}
+
+func (d *Discord) addMessage(channelID string, author *discord.User, m *discord.Message) *discord.Message {
+	m.ID = d.newID()
+	m.ChannelID = channelID
+	m.Author = author
+	m.Timestamp = time.Now()
+	if ch, ok := d.channels[channelID]; ok {
+		m.GuildID = ch.GuildID
+	}
+	if m.MessageReference != nil {
+		m.Type = discord.MessageTypeReply
+		m.MessageReference.ChannelID = channelID
+		if referenced := d.findMessage(channelID, m.MessageReference.MessageID); referenced != nil {
+			m.ReferencedMessage = referenced
+		}
+	}
+	d.messages[channelID] = append(d.messages[channelID], m)
+	return m
+}
+
+func (d *Discord) findMessage(channelID string, messageID string) *discord.Message {
+	for _, m := range d.messages[channelID] {
+		if m.ID == messageID {
+			return m
+		}
+	}
+	return nil
+}
+
+func (d *Discord) interactionCallback(r *http.Request, token string) *restError {
+	it, ok := d.interactions[token]
+	if !ok {
+		return notFound("interaction", token)
+	}
+	data, files, err := d.readBody(r, it.ChannelID)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		Type discord.InteractionResponseType `json:"type"`
+		Data json.RawMessage                 `json:"data"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return &restError{http.StatusBadRequest, err.Error()}
+	}
+	p, err := decodePayload(response.Data, files)
+	if err != nil {
+		return err
+	}
+
+	switch response.Type {
+	case discord.InteractionResponseChannelMessageWithSource, discord.InteractionResponseDeferredChannelMessageWithSource:
+		it.deferred = response.Type == discord.InteractionResponseDeferredChannelMessageWithSource
+		it.original = d.addMessage(it.ChannelID, d.Bot, &p.Message)
+		it.original.Interaction = &discord.MessageInteraction{ID: it.ID, Type: it.Type, User: it.Member.User}
+	case discord.InteractionResponseUpdateMessage:
+		if it.Message == nil {
+			return &restError{http.StatusBadRequest, "interaction has no message"}
+		}
+		m := d.findMessage(it.Message.ChannelID, it.Message.ID)
+		if m == nil {
+			return notFound("message", it.Message.ID)
+		}
+		p.applyEdit(m)
+	case discord.InteractionResponseDeferredMessageUpdate:
+	default:
+		return &restError{http.StatusBadRequest, fmt.Sprintf("unsupported interaction response type %d", response.Type)}
+	}
+	return nil
+}
+
+func (d *Discord) followup(r *http.Request, token string) (*discord.Message, *restError) {
+	it, ok := d.interactions[token]
+	if !ok {
+		return nil, notFound("webhook", token)
+	}
+	p, err := d.readPayload(r, it.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if it.deferred {
+		// The first follow up message of a deferred response replaces the "thinking" message
+		it.deferred = false
+		p.set = map[string]json.RawMessage{"content": nil, "embeds": nil, "components": nil, "flags": nil}
+		p.applyEdit(it.original)
+		return copyMessage(it.original), nil
+	}
+	m := d.addMessage(it.ChannelID, d.Bot, &p.Message)
+	m.WebhookID = it.AppID
+	return copyMessage(m), nil
+}
+
+func (d *Discord) interactionMessage(token string, messageID string) (*discord.Message, *restError) {
+	it, ok := d.interactions[token]
+	if !ok {
+		return nil, notFound("webhook", token)
+	}
+	if messageID == "@original" {
+		if it.original == nil {
+			return nil, notFound("message", messageID)
+		}
+		return it.original, nil
+	}
+	m := d.findMessage(it.ChannelID, messageID)
+	if m == nil {
+		return nil, notFound("message", messageID)
+	}
+	return m, nil
+}
+
+func (d *Discord) webhookMessage(token string, messageID string) (*discord.Message, *restError) {
+	m, err := d.interactionMessage(token, messageID)
+	if err != nil {
+		return nil, err
+	}
+	return copyMessage(m), nil
+}
+
+func (d *Discord) webhookMessageEdit(r *http.Request, token string, messageID string) (*discord.Message, *restError) {
+	m, err := d.interactionMessage(token, messageID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := d.readPayload(r, m.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	p.applyEdit(m)
+	return copyMessage(m), nil
+}
+
+func (d *Discord) webhookMessageDelete(token string, messageID string) *restError {
+	m, err := d.interactionMessage(token, messageID)
+	if err != nil {
+		return err
+	}
+	if messageID == "@original" {
+		d.interactions[token].original = nil
+	}
+	return d.messageDelete(m.ChannelID, m.ID)
+}
+
+func (d *Discord) channel(channelID string) (*discord.Channel, *restError) {
+	ch, ok := d.channels[channelID]
+	if !ok {
+		return nil, notFound("channel", channelID)
+	}
+	return copyChannel(ch), nil
+}
+
+func (d *Discord) channelEdit(r *http.Request, channelID string) (*discord.Channel, *restError) {
+	ch, ok := d.channels[channelID]
+	if !ok {
+		return nil, notFound("channel", channelID)
+	}
+	var edit discord.ChannelEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		return nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+	if edit.Name != "" {
+		ch.Name = edit.Name
+	}
+	if ch.IsThread() {
+		if edit.Locked != nil {
+			ch.ThreadMetadata.Locked = *edit.Locked
+			d.locks[channelID] = append(d.locks[channelID], *edit.Locked)
+		}
+		if edit.Archived != nil {
+			ch.ThreadMetadata.Archived = *edit.Archived
+		}
+	}
+	d.setChannel(ch)
+	return copyChannel(ch), nil
+}
+
+// setChannel stores the channel and updates the session state as CHANNEL_UPDATE and THREAD_UPDATE events would
+func (d *Discord) setChannel(ch *discord.Channel) {
+	d.channels[ch.ID] = ch
+	if err := d.Session.State.ChannelAdd(copyChannel(ch)); err != nil {
+		d.t.Errorf("cannot add channel %s to the state: %v", ch.ID, err)
+	}
+}
+
+func (d *Discord) channelMessages(r *http.Request, channelID string) ([]*discord.Message, *restError) {
+	if _, ok := d.channels[channelID]; !ok {
+		return nil, notFound("channel", channelID)
+	}
+	query := r.URL.Query()
+	limit := 50
+	if n, err := strconv.Atoi(query.Get("limit")); err == nil {
+		limit = n
+	}
+	before, after := query.Get("before"), query.Get("after")
+
+	// Newest first, as Discord returns them
+	messages := []*discord.Message{}
+	all := d.messages[channelID]
+	for i := len(all) - 1; i >= 0 && len(messages) < limit; i-- {
+		m := all[i]
+		if (before != "" && compareIDs(m.ID, before) >= 0) || (after != "" && compareIDs(m.ID, after) <= 0) {
+			continue
+		}
+		messages = append(messages, copyMessage(m))
+	}
+	return messages, nil
+}
+
+func (d *Discord) messageSend(r *http.Request, channelID string) (*discord.Message, *restError) {
+	if _, ok := d.channels[channelID]; !ok {
+		return nil, notFound("channel", channelID)
+	}
+	p, err := d.readPayload(r, channelID)
+	if err != nil {
+		return nil, err
+	}
+	return copyMessage(d.addMessage(channelID, d.Bot, &p.Message)), nil
+}
+
+func (d *Discord) message(channelID string, messageID string) (*discord.Message, *restError) {
+	m := d.findMessage(channelID, messageID)
+	if m == nil {
+		return nil, notFound("message", messageID)
+	}
+	return copyMessage(m), nil
+}
+
+func (d *Discord) messageEdit(r *http.Request, channelID string, messageID string) (*discord.Message, *restError) {
+	m := d.findMessage(channelID, messageID)
+	if m == nil {
+		return nil, notFound("message", messageID)
+	}
+	p, err := d.readPayload(r, channelID)
+	if err != nil {
+		return nil, err
+	}
+	p.applyEdit(m)
+	return copyMessage(m), nil
+}
+
+func (d *Discord) messageDelete(channelID string, messageID string) *restError {
+	messages := d.messages[channelID]
+	i := slices.IndexFunc(messages, func(m *discord.Message) bool { return m.ID == messageID })
+	if i < 0 {
+		return notFound("message", messageID)
+	}
+	d.messages[channelID] = slices.Delete(messages, i, i+1)
+	return nil
+}
+
+func (d *Discord) reactionAdd(channelID string, messageID string, emoji string) *restError {
+	m := d.findMessage(channelID, messageID)
+	if m == nil {
+		return notFound("message", messageID)
+	}
+	emoji, _ = url.PathUnescape(emoji)
+	for _, reaction := range m.Reactions {
+		if reaction.Emoji.Name == emoji {
+			if !reaction.Me {
+				reaction.Me = true
+				reaction.Count++
+			}
+			return nil
+		}
+	}
+	m.Reactions = append(m.Reactions, &discord.MessageReactions{Emoji: &discord.Emoji{Name: emoji}, Count: 1, Me: true})
+	return nil
+}
+
+func (d *Discord) reactionsRemove(channelID string, messageID string, emoji string) *restError {
+	m := d.findMessage(channelID, messageID)
+	if m == nil {
+		return notFound("message", messageID)
+	}
+	emoji, _ = url.PathUnescape(emoji)
+	m.Reactions = slices.DeleteFunc(m.Reactions, func(reaction *discord.MessageReactions) bool {
+		return reaction.Emoji.Name == emoji
+	})
+	return nil
+}
+
+func (d *Discord) threadStart(r *http.Request, channelID string, messageID string) (*discord.Channel, *restError) {
+	parent, ok := d.channels[channelID]
+	if !ok {
+		return nil, notFound("channel", channelID)
+	}
+	m := d.findMessage(channelID, messageID)
+	if m == nil {
+		return nil, notFound("message", messageID)
+	}
+	var start discord.ThreadStart
+	if err := json.NewDecoder(r.Body).Decode(&start); err != nil {
+		return nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+
+	// Threads started from a message share its ID
+	thread := &discord.Channel{
+		ID:       m.ID,
+		GuildID:  parent.GuildID,
+		ParentID: parent.ID,
+		OwnerID:  d.Bot.ID,
+		Name:     start.Name,
+		Type:     discord.ChannelTypeGuildPublicThread,
+		ThreadMetadata: &discord.ThreadMetadata{
+			AutoArchiveDuration: start.AutoArchiveDuration,
+			Invitable:           start.Invitable,
+		},
+	}
+	d.setChannel(thread)
+	// The first message of the thread refers to the message it was started from
+	d.messages[thread.ID] = append(d.messages[thread.ID], &discord.Message{
+		ID:                d.newID(),
+		ChannelID:         thread.ID,
+		GuildID:           thread.GuildID,
+		Author:            m.Author,
+		Type:              discord.MessageTypeThreadStarterMessage,
+		Timestamp:         time.Now(),
+		MessageReference:  &discord.MessageReference{MessageID: m.ID, ChannelID: channelID, GuildID: m.GuildID},
+		ReferencedMessage: m,
+	})
+	return copyChannel(thread), nil
+}
+
+func (d *Discord) activeThreads(guildID string) *discord.ThreadsList {
+	list := &discord.ThreadsList{Threads: []*discord.Channel{}, Members: []*discord.ThreadMember{}}
+	for _, ch := range d.channels {
+		if ch.GuildID == guildID && ch.IsThread() && !ch.ThreadMetadata.Archived {
+			list.Threads = append(list.Threads, copyChannel(ch))
+		}
+	}
+	return list
+}
+
+// commandsKey is the guild ID of guild commands, empty for global ones
+func commandsKey(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return ""
+}
+
+func (d *Discord) applicationCommands(args []string) []*discord.ApplicationCommand {
+	return append([]*discord.ApplicationCommand{}, d.commands[commandsKey(args)]...)
+}
+
+func (d *Discord) applicationCommandCreate(r *http.Request, args []string) (*discord.ApplicationCommand, *restError) {
+	var c discord.ApplicationCommand
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		return nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+	key := commandsKey(args)
+	c.ID, c.ApplicationID, c.GuildID = d.newID(), args[0], key
+	// Commands are upserted by name
+	d.commands[key] = slices.DeleteFunc(d.commands[key], func(old *discord.ApplicationCommand) bool { return old.Name == c.Name })
+	d.commands[key] = append(d.commands[key], &c)
+	return &c, nil
+}
+
+func (d *Discord) applicationCommandEdit(r *http.Request, args []string) (*discord.ApplicationCommand, *restError) {
+	var c discord.ApplicationCommand
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		return nil, &restError{http.StatusBadRequest, err.Error()}
+	}
+	key, id := commandsKey(args[:len(args)-1]), args[len(args)-1]
+	for i, old := range d.commands[key] {
+		if old.ID == id {
+			c.ID, c.ApplicationID, c.GuildID = old.ID, old.ApplicationID, old.GuildID
+			d.commands[key][i] = &c
+			return &c, nil
+		}
+	}
+	return nil, notFound("command", id)
+}
+
+func (d *Discord) applicationCommandDelete(args []string) *restError {
+	key, id := commandsKey(args[:len(args)-1]), args[len(args)-1]
+	n := len(d.commands[key])
+	d.commands[key] = slices.DeleteFunc(d.commands[key], func(c *discord.ApplicationCommand) bool { return c.ID == id })
+	if len(d.commands[key]) == n {
+		return notFound("command", id)
+	}
+	return nil
+}
+
+func compareIDs(a string, b string) int {
+	x, _ := strconv.ParseInt(a, 10, 64)
+	y, _ := strconv.ParseInt(b, 10, 64)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// copyMessage copies the message through JSON, like it would be received from Discord
+// copyMessage makes a deep copy of the message. Components are not marshalled with the message, so they are
+// copied on their own
+func copyMessage(m *discord.Message) *discord.Message {
+	var c, components discord.Message
+	roundTrip(m, &c)
+	roundTrip(map[string]any{"components": m.Components}, &components)
+	c.Components = components.Components
+	return &c
+}
+
+func roundTrip(v any, copy any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, copy); err != nil {
+		panic(err)
+	}
+}
+
+func copyChannel(ch *discord.Channel) *discord.Channel {
+	c := *ch
+	if ch.ThreadMetadata != nil {
+		metadata := *ch.ThreadMetadata
+		c.ThreadMetadata = &metadata
+	}
+	return &c
+}
@@ -0,0 +1,360 @@
+package fake
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// APIError is returned by handlers of the fake to respond with an OpenAI API error
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Status, e.Message)
+}
+
+// Image is a request of the images API, generation, edit or variation
+type Image struct {
+	Path   string
+	Prompt string
+	Model  string
+	N      int
+	Size   string
+}
+
+// OpenAI is an httptest stand-in for OpenAI API: chat completions with and without streaming, completions,
+// image generations, edits and variations, and moderations. Handlers decide the responses, errors they return
+// are sent as API errors. Handlers must be set before requests are made
+type OpenAI struct {
+	Server *httptest.Server
+
+	// Chat answers chat completions. By default it repeats the last message
+	Chat func(request openai.ChatCompletionRequest) (string, error)
+	// ToolCalls lists calls of the offered tools the model makes instead of answering, if any. Streamed
+	// completions never call tools. By default tools are not called
+	ToolCalls func(request openai.ChatCompletionRequest) []openai.ToolCall
+	// Completion answers completions. By default it returns "Title"
+	Completion func(request openai.CompletionRequest) (string, error)
+	// Image is called for every image request. By default it succeeds, images are 1x1 PNGs
+	Image func(request Image) error
+	// Moderation lists categories the input is flagged in. By default nothing is flagged
+	Moderation func(input string) []string
+
+	t *testing.T
+
+	mu       sync.Mutex
+	requests map[string][][]byte
+}
+
+// NewOpenAI starts the fake, it is closed with the test
+func NewOpenAI(t *testing.T) *OpenAI {
+	o := &OpenAI{
+		Chat: func(request openai.ChatCompletionRequest) (string, error) {
+			return request.Messages[len(request.Messages)-1].Content, nil
+		},
+		Completion: func(openai.CompletionRequest) (string, error) {
+			return "Title", nil
+		},
+		Image:      func(Image) error { return nil },
+		Moderation: func(string) []string { return nil },
+		t:          t,
+		requests:   make(map[string][][]byte),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", o.chatCompletions)
+	mux.HandleFunc("POST /v1/completions", o.completions)
+	mux.HandleFunc("POST /v1/images/generations", o.imageGenerations)
+	mux.HandleFunc("POST /v1/images/edits", o.imageEdits)
+	mux.HandleFunc("POST /v1/images/variations", o.imageEdits)
+	mux.HandleFunc("POST /v1/moderations", o.moderations)
+	mux.HandleFunc("GET /images/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngImage)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("fake OpenAI does not support %s %s", r.Method, r.URL.Path)
+		writeError(w, &APIError{http.StatusNotFound, "not found"})
+	})
+	o.Server = httptest.NewServer(mux)
+	t.Cleanup(o.Server.Close)
+	return o
+}
+
+// Client returns a client of the fake
+func (o *OpenAI) Client() *openai.Client {
+	config := openai.DefaultConfig("test")
+	config.BaseURL = o.Server.URL + "/v1"
+	return openai.NewClientWithConfig(config)
+}
+
+// ChatRequests lists chat completion requests received so far
+func (o *OpenAI) ChatRequests() []openai.ChatCompletionRequest {
+	return decodeRequests[openai.ChatCompletionRequest](o, "/v1/chat/completions")
+}
+
+// ImageRequests lists image generation requests received so far
+func (o *OpenAI) ImageRequests() []openai.ImageRequest {
+	return decodeRequests[openai.ImageRequest](o, "/v1/images/generations")
+}
+
+// ModerationRequests lists moderation requests received so far
+func (o *OpenAI) ModerationRequests() []openai.ModerationRequest {
+	return decodeRequests[openai.ModerationRequest](o, "/v1/moderations")
+}
+
+func decodeRequests[T any](o *OpenAI, path string) []T {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	requests := make([]T, 0, len(o.requests[path]))
+	for _, body := range o.requests[path] {
+		var request T
+		if err := json.Unmarshal(body, &request); err != nil {
+			o.t.Errorf("cannot decode %s request: %v", path, err)
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// readRequest records the JSON body and decodes it
+func (o *OpenAI) readRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(body, v)
+	}
+	if err != nil {
+		writeError(w, &APIError{http.StatusBadRequest, err.Error()})
+		return false
+	}
+	o.mu.Lock()
+	o.requests[r.URL.Path] = append(o.requests[r.URL.Path], body)
+	o.mu.Unlock()
+	return true
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := &APIError{http.StatusInternalServerError, err.Error()}
+	errors.As(err, &apiErr)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": apiErr.Message, "type": "fake_error"},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// countTokens approximates tokens with words, which is enough for usage reports
+func countTokens(s string) int {
+	return len(strings.Fields(s))
+}
+
+func (o *OpenAI) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var request openai.ChatCompletionRequest
+	if !o.readRequest(w, r, &request) {
+		return
+	}
+	var toolCalls []openai.ToolCall
+	if len(request.Tools) > 0 && !request.Stream && o.ToolCalls != nil {
+		toolCalls = o.ToolCalls(request)
+	}
+	var content string
+	if len(toolCalls) == 0 {
+		var err error
+		if content, err = o.Chat(request); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	promptTokens := 0
+	for _, m := range request.Messages {
+		promptTokens += countTokens(m.Content)
+	}
+	usage := openai.Usage{PromptTokens: promptTokens, CompletionTokens: countTokens(content)}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	if request.Stream {
+		o.streamChat(w, request.Model, content)
+		return
+	}
+	message := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content, ToolCalls: toolCalls}
+	finishReason := openai.FinishReasonStop
+	if len(toolCalls) > 0 {
+		finishReason = openai.FinishReasonToolCalls
+	}
+	writeJSON(w, openai.ChatCompletionResponse{
+		ID:      "chatcmpl-fake",
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   request.Model,
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message:      message,
+				FinishReason: finishReason,
+			},
+		},
+		Usage: usage,
+	})
+}
+
+// streamChat sends the answer word by word as server-sent events
+func (o *OpenAI) streamChat(w http.ResponseWriter, model string, content string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	send := func(delta string, finishReason openai.FinishReason) {
+		data, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+			ID:      "chatcmpl-fake",
+			Object:  "chat.completion.chunk",
+			Created: time.Now().Unix(),
+			Model:   model,
+			Choices: []openai.ChatCompletionStreamChoice{
+				{
+					Delta:        openai.ChatCompletionStreamChoiceDelta{Role: openai.ChatMessageRoleAssistant, Content: delta},
+					FinishReason: finishReason,
+				},
+			},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+	for i, word := range strings.SplitAfter(content, " ") {
+		if i == 0 || word != "" {
+			send(word, "")
+		}
+	}
+	send("", openai.FinishReasonStop)
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (o *OpenAI) completions(w http.ResponseWriter, r *http.Request) {
+	var request openai.CompletionRequest
+	if !o.readRequest(w, r, &request) {
+		return
+	}
+	text, err := o.Completion(request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	prompt, _ := request.Prompt.(string)
+	usage := openai.Usage{PromptTokens: countTokens(prompt), CompletionTokens: countTokens(text)}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	writeJSON(w, openai.CompletionResponse{
+		ID:      "cmpl-fake",
+		Object:  "text_completion",
+		Created: time.Now().Unix(),
+		Model:   request.Model,
+		Choices: []openai.CompletionChoice{{Text: text, FinishReason: string(openai.FinishReasonStop)}},
+		Usage:   usage,
+	})
+}
+
+func (o *OpenAI) imageGenerations(w http.ResponseWriter, r *http.Request) {
+	var request openai.ImageRequest
+	if !o.readRequest(w, r, &request) {
+		return
+	}
+	o.respondImages(w, Image{Path: r.URL.Path, Prompt: request.Prompt, Model: request.Model, N: request.N, Size: request.Size}, request.ResponseFormat)
+}
+
+// imageEdits handles edits and variations, their requests are multipart forms
+func (o *OpenAI) imageEdits(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, &APIError{http.StatusBadRequest, err.Error()})
+		return
+	}
+	if len(r.MultipartForm.File["image"]) == 0 {
+		writeError(w, &APIError{http.StatusBadRequest, "image is required"})
+		return
+	}
+	n := 1
+	fmt.Sscan(r.FormValue("n"), &n)
+	request := Image{Path: r.URL.Path, Prompt: r.FormValue("prompt"), Model: r.FormValue("model"), N: n, Size: r.FormValue("size")}
+	o.respondImages(w, request, r.FormValue("response_format"))
+}
+
+func (o *OpenAI) respondImages(w http.ResponseWriter, request Image, format string) {
+	if err := o.Image(request); err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := openai.ImageResponse{Created: time.Now().Unix()}
+	for i := 0; i < max(request.N, 1); i++ {
+		data := openai.ImageResponseDataInner{RevisedPrompt: request.Prompt}
+		if format == openai.CreateImageResponseFormatB64JSON {
+			data.B64JSON = base64.StdEncoding.EncodeToString(pngImage)
+		} else {
+			data.URL = o.Server.URL + "/images/image.png"
+		}
+		resp.Data = append(resp.Data, data)
+	}
+	writeJSON(w, resp)
+}
+
+// Moderation categories as the API names them
+var moderationCategories = []string{
+	"hate", "hate/threatening", "harassment", "harassment/threatening", "self-harm", "self-harm/intent",
+	"self-harm/instructions", "sexual", "sexual/minors", "violence", "violence/graphic",
+}
+
+func (o *OpenAI) moderations(w http.ResponseWriter, r *http.Request) {
+	var request openai.ModerationRequest
+	if !o.readRequest(w, r, &request) {
+		return
+	}
+	flagged := o.Moderation(request.Input)
+	categories := make(map[string]bool, len(moderationCategories))
+	scores := make(map[string]float64, len(moderationCategories))
+	for _, category := range moderationCategories {
+		categories[category] = false
+		scores[category] = 0
+	}
+	for _, category := range flagged {
+		categories[category] = true
+		scores[category] = 1
+	}
+	writeJSON(w, map[string]any{
+		"id":    "modr-fake",
+		"model": "text-moderation-latest",
+		"results": []any{
+			map[string]any{"flagged": len(flagged) > 0, "categories": categories, "category_scores": scores},
+		},
+	})
+}
+
+// pngImage is a 1x1 image returned by image requests
+var pngImage = func() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}()
+
+// PNG returns a 1x1 PNG image, e.g. for image attachments
+func PNG() []byte {
+	return bytes.Clone(pngImage)
+}
@@ -0,0 +1,134 @@
+package i18n
+
+var german = Catalog{
+	"error.title":                   "❌ Fehler",
+	"error.openai":                  "❌ OpenAI-API-Fehler",
+	"error.discord":                 "❌ Discord-API-Fehler",
+	"error.attachment":              "Anhang konnte nicht geladen werden",
+	"error.command":                 "Befehl konnte nicht verarbeitet werden",
+	"error.promptOption":            "Prompt-Option konnte nicht gelesen werden",
+	"component.unsupported":         "Diese Schaltfläche wird nicht mehr unterstützt, bitte verwende stattdessen den Befehl",
+	"choice.default":                "%s (Standard)",
+	"queue.position":                "⌛ Du bist Nr. %d in der Warteschlange",
+	"queue.started":                 "⌛ Wird generiert...",
+	"bot.interrupted":               "🔄 Der Bot wurde neu gestartet, bevor er fertig war, bitte versuche es erneut",
+	"bot.restarting":                "🔄 Der Bot startet neu, bitte versuche es gleich noch einmal",
+	"moderation.unavailable":        "Die Moderation ist derzeit nicht verfügbar, bitte versuche es später erneut",
+	"moderation.violation":          "Der Inhalt verstößt gegen die Nutzungsrichtlinien und wird vom Sicherheitssystem nicht zugelassen",
+	"moderation.categories":         "Der Inhalt verstößt gegen die Nutzungsrichtlinien und wird vom Sicherheitssystem nicht zugelassen (%s)",
+	"chat.description":              "Unterhaltung mit einem LLM beginnen",
+	"image.description":             "Kreative Bilder aus Textbeschreibungen generieren",
+	"image.disabled":                "Bildbefehle sind auf diesem Server deaktiviert",
+	"gpt.description":               "Unterhaltung mit ChatGPT beginnen",
+	"gpt.author":                    "OpenAI-Chatanfrage von %s",
+	"gpt.option.prompt":             "ChatGPT-Prompt",
+	"gpt.option.context":            "Legt den Kontext fest, der das Verhalten des KI-Assistenten in der Unterhaltung steuert",
+	"gpt.option.contextFile":        "Datei mit dem Kontext, der das Verhalten des KI-Assistenten in der Unterhaltung steuert",
+	"gpt.option.model":              "GPT-Modell",
+	"gpt.option.temperature":        "Sampling-Temperatur zwischen 0.0 und 2.0. Niedriger - fokussierter und deterministischer",
+	"gpt.import.description":        "Unterhaltung aus einer JSON-Datei importieren und in einem neuen Thread fortsetzen",
+	"gpt.import.option.file":        "JSON-Datei mit OpenAI-Chatnachrichten oder einer vom Bot exportierten Unterhaltung",
+	"gpt.import.option.model":       "GPT-Modell, ersetzt das Modell aus der Datei",
+	"gpt.import.option.temperature": "Sampling-Temperatur zwischen 0.0 und 2.0, ersetzt die Temperatur aus der Datei",
+	"gpt.import.failed":             "Unterhaltung konnte nicht importiert werden",
+	"gpt.import.fileOption":         "Datei-Option konnte nicht gelesen werden",
+	"gpt.import.tooBig":             "Datei `%s` ist zu groß, erlaubt sind höchstens `%d` Bytes",
+	"gpt.import.temperature":        "Temperatur `%g` liegt außerhalb des erlaubten Bereichs von 0.0 bis 2.0",
+	"gpt.import.tooLong":            "Die Unterhaltung hat `%d` Tokens und überschreitet das Limit von `%d` für das Modell `%s`",
+	"gpt.import.author":             "OpenAI-Chatimport von %s",
+	"gpt.contextFileTooLong":        "Die Kontextdatei hat `%d` Tokens und überschreitet das Limit von `%d` für das Modell `%s`.\nBitte verwende eine kürzere Datei oder stattdessen die Option `context`",
+	"gpt.contextFileTooLong.title":  "Kontextdatei konnte nicht verarbeitet werden",
+	"gpt.contextTooLong":            "Der Kontext ist länger als %d Zeichen. Bitte verwende stattdessen die Option `context-file`",
+	"gpt.channelNotAllowed":         "In diesem Kanal können keine Unterhaltungen begonnen werden. Bitte verwende %s",
+	"gpt.modelNotEnabled":           "Modell `%s` ist nicht aktiviert. Verfügbare Modelle: `%s`",
+	"gpt.pending":                   "⌛ Einen Moment bitte...",
+	"gpt.interactionReference":      "Interaktionsreferenz konnte nicht abgerufen werden, Fehler: %v",
+	"gpt.withheld":                  "🚫 Die Antwort wurde von der Moderation zurückgehalten",
+	"gpt.recovered":                 "🔄 Der Bot wurde neu gestartet, bevor er antworten konnte",
+	"gpt.retry":                     "Erneut versuchen",
+	"gpt.retry.threadsOnly":         "Erneut versuchen funktioniert nur in Chat-Threads",
+	"gpt.retry.locked":              "Der Thread ist gesperrt, wahrscheinlich wird bereits eine Antwort generiert",
+	"gpt.retry.rebuildFailed":       "Die Unterhaltung konnte nicht aus dem Thread wiederhergestellt werden",
+	"gpt.retry.answered":            "Die letzte Nachricht der Unterhaltung wurde bereits beantwortet",
+	"gpt.option.kb":                 "Wissensdatenbank dieses Servers für Antworten, siehe `/kb list`",
+	"gpt.kb.notFound":               "Die Wissensdatenbank `%s` existiert auf diesem Server nicht, siehe `/kb list`",
+	"gpt.kb.sources":                "📚 Quellen aus `%s`:",
+	"gpt.kb.unavailable":            "⚠️ Die Wissensdatenbank `%s` wurde nicht durchsucht: %s",
+	"gpt.memory.learned":            "🧠 Gemerkt: %s",
+	"dalle.description":             "Kreative Bilder aus Textbeschreibungen mit OpenAI Dalle 2 generieren",
+	"dalle.option.prompt":           "Textbeschreibung des gewünschten Bildes",
+	"dalle.option.model":            "Dall-e-Modell",
+	"dalle.option.size":             "Größe der generierten Bilder",
+	"dalle.option.style":            "Stil der generierten Bilder (nur v3)",
+	"dalle.option.quality":          "Qualität der generierten Bilder (nur v3)",
+	"dalle.option.exact":            "Dall-e 3 soll den Prompt unverändert verwenden, ohne ihn umzuschreiben (nur v3)",
+	"dalle.option.enhance":          "Einen kurzen Prompt vor der Generierung mit einem Chatmodell ausführlicher machen",
+	"dalle.option.number":           "Anzahl der zu generierenden Bilder (Standard 1, höchstens 4)",
+	"dalle.edit.description":        "Ein Bild mit einer Textbeschreibung mit OpenAI Dall-e 2 bearbeiten",
+	"dalle.edit.option.image":       "Quadratisches Bild zum Bearbeiten. Ohne Maske werden transparente Bereiche bearbeitet",
+	"dalle.edit.option.mask":        "Bild, dessen vollständig transparente Bereiche angeben, wo das Bild bearbeitet werden soll",
+	"dalle.variation.description":   "Variationen eines Bildes mit OpenAI Dall-e 2 generieren",
+	"dalle.variation.option.image":  "Quadratisches Bild, von dem Variationen erstellt werden",
+	"dalle.variation.title":         "Variationen von %s",
+	"dalle.image":                   "Bild %d",
+	"dalle.footer.model":            "Modell: %s",
+	"dalle.footer.size":             "Größe: %s",
+	"dalle.footer.images":           "Bilder: %d",
+	"dalle.footer.cost":             "Generierungskosten: $%g",
+	"dalle.imagesFailed":            "❌ Generierte Bilder konnten nicht geladen werden",
+	"dalle.invalidImage":            "Ungültiges Bild",
+	"dalle.invalidMask":             "Ungültige Maske",
+	"dalle.imagePromptOptions":      "Bild- und Prompt-Optionen konnten nicht gelesen werden",
+	"dalle.imageOption":             "Bild-Option konnte nicht gelesen werden",
+	"dalle.processImage":            "Bild konnte nicht verarbeitet werden",
+	"dalle.processMask":             "Maske konnte nicht verarbeitet werden",
+	"dalle.reroll":                  "Neu generieren",
+	"dalle.wide":                    "Breit",
+	"dalle.tall":                    "Hoch",
+	"dalle.variations":              "Variationen %d",
+	"dalle.promptNotFound":          "Prompt konnte nicht aus der Nachricht wiederhergestellt werden",
+	"dalle.imageNotFound":           "Bild wurde in der Nachricht nicht gefunden",
+	"dalle.enhanceFailed":           "❌ Prompt konnte nicht verbessert werden",
+	"dalle.partialFailure":          "⚠️ %d von %d Bildern konnten nicht generiert werden",
+	"kb.description":                "Dokumente verwalten, auf die sich Chat-Unterhaltungen stützen können",
+	"kb.option.kb":                  "Name der Wissensdatenbank, `default` wenn nicht angegeben",
+	"kb.add.description":            "Ein Dokument zu einer Wissensdatenbank dieses Servers hinzufügen",
+	"kb.add.option.file":            "Text-, Markdown- oder Codedatei, PDF, Zip- oder Tar-Archiv",
+	"kb.list.description":           "Wissensdatenbanken dieses Servers und ihre Dokumente auflisten",
+	"kb.remove.description":         "Ein Dokument aus einer Wissensdatenbank entfernen",
+	"kb.remove.option.document":     "Dokumentname wie von /kb list angezeigt, ein Archiv entfernt alle seine Dateien",
+	"kb.fileOption":                 "Die Dateioption konnte nicht gelesen werden",
+	"kb.tooBig":                     "Die Datei `%s` ist zu groß, die maximal erlaubte Größe beträgt `%d` Bytes",
+	"kb.addFailed":                  "Das Dokument konnte nicht hinzugefügt werden",
+	"kb.added.title":                "📚 Zur Wissensdatenbank `%s` hinzugefügt",
+	"kb.added":                      "Nutze die Option `kb` von `/chat gpt`, um Fragen zu den Dokumenten der Wissensdatenbank zu stellen",
+	"kb.list.title":                 "📚 Wissensdatenbanken",
+	"kb.list.empty":                 "Es gibt noch keine Wissensdatenbanken, füge Dokumente mit `/kb add` hinzu",
+	"kb.document":                   "`%s`, %d Abschnitte, hinzugefügt von <@%s>",
+	"kb.list.more":                  "…und %d weitere",
+	"kb.removeFailed":               "Das Dokument konnte nicht entfernt werden",
+	"kb.removed.title":              "🗑️ Aus der Wissensdatenbank `%s` entfernt",
+	"memory.description":            "Verwalten, was sich der Bot über Unterhaltungen hinweg über dich merkt",
+	"memory.add.description":        "Einen Fakt über dich in Unterhaltungen merken, die du startest",
+	"memory.add.option.fact":        "Zu merkender Fakt, z. B. dein Stack, deine Vorlieben oder Projektdetails",
+	"memory.list.description":       "Auflisten, was sich der Bot über dich merkt",
+	"memory.forget.description":     "Einen Fakt vergessen, oder alles über dich, wenn keine Nummer angegeben ist",
+	"memory.forget.option.number":   "Nummer des Fakts wie von /memory list angezeigt",
+	"memory.learn.description":      "Dem Modell erlauben, sich Fakten aus deinen Unterhaltungen zu merken",
+	"memory.learn.option.enabled":   "Ob sich das Modell selbst Fakten über dich merken darf",
+	"memory.title":                  "🧠 Gedächtnis",
+	"memory.failed":                 "Das Gedächtnis konnte nicht aktualisiert werden",
+	"memory.fact":                   "`%d` %s",
+	"memory.fact.learned":           "`%d` %s *(aus einer Unterhaltung gelernt)*",
+	"memory.added.title":            "🧠 Gemerkt",
+	"memory.added":                  "Der Fakt wird Unterhaltungen hinzugefügt, die du startest, siehe /memory list",
+	"memory.list.title":             "🧠 Was ich mir über dich merke",
+	"memory.list.empty":             "Über dich ist nichts gespeichert. Füge Fakten mit `/memory add` hinzu",
+	"memory.list.more":              "…und %d weitere",
+	"memory.learnOn":                "Das Modell kann sich Fakten aus deinen Unterhaltungen merken, siehe /memory learn",
+	"memory.learnOff":               "Das Modell merkt sich keine Fakten selbst, siehe /memory learn",
+	"memory.learnEnabled":           "Das Modell merkt sich Fakten, die du ihm in Unterhaltungen erzählst. Alles Gemerkte zeigt `/memory list`",
+	"memory.learnDisabled":          "Das Modell merkt sich keine Fakten mehr selbst. Bereits gemerkte Fakten bleiben, bis du sie vergisst",
+	"memory.forgotten.title":        "🧹 Vergessen",
+	"memory.erased":                 "Alles, was über dich gespeichert war, wurde gelöscht",
+}
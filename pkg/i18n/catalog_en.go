@@ -0,0 +1,134 @@
+package i18n
+
+var english = Catalog{
+	"error.title":                   "❌ Error",
+	"error.openai":                  "❌ OpenAI API failed",
+	"error.discord":                 "❌ Discord API Error",
+	"error.attachment":              "Failed to get attachment data",
+	"error.command":                 "Failed to process command",
+	"error.promptOption":            "Failed to parse prompt option",
+	"component.unsupported":         "This button is no longer supported, please use the command instead",
+	"choice.default":                "%s (Default)",
+	"queue.position":                "⌛ You are #%d in queue",
+	"queue.started":                 "⌛ Generating...",
+	"bot.interrupted":               "🔄 The bot restarted before it could finish, please retry",
+	"bot.restarting":                "🔄 The bot is restarting, please retry in a moment",
+	"moderation.unavailable":        "Moderation is currently unavailable, please try again later",
+	"moderation.violation":          "The content violates usage policies and is not allowed by the safety system",
+	"moderation.categories":         "The content violates usage policies and is not allowed by the safety system (%s)",
+	"chat.description":              "Start conversation with LLM",
+	"image.description":             "Generate creative images from textual descriptions",
+	"image.disabled":                "Image commands are disabled in this server",
+	"gpt.description":               "Start conversation with ChatGPT",
+	"gpt.author":                    "OpenAI chat request by %s",
+	"gpt.option.prompt":             "ChatGPT prompt",
+	"gpt.option.context":            "Sets context that guides the AI assistant's behavior during the conversation",
+	"gpt.option.contextFile":        "File that sets context that guides the AI assistant's behavior during the conversation",
+	"gpt.option.model":              "GPT model",
+	"gpt.option.temperature":        "What sampling temperature to use, between 0.0 and 2.0. Lower - more focused and deterministic",
+	"gpt.import.description":        "Import a conversation from a JSON file and continue it in a new thread",
+	"gpt.import.option.file":        "JSON file with OpenAI chat messages or a conversation exported by the bot",
+	"gpt.import.option.model":       "GPT model, overrides the one in the file",
+	"gpt.import.option.temperature": "Sampling temperature between 0.0 and 2.0, overrides the one in the file",
+	"gpt.import.failed":             "Failed to import conversation",
+	"gpt.import.fileOption":         "Failed to parse file option",
+	"gpt.import.tooBig":             "File `%s` is too big, maximum allowed size is `%d` bytes",
+	"gpt.import.temperature":        "Temperature `%g` is out of the allowed range between 0.0 and 2.0",
+	"gpt.import.tooLong":            "Conversation is `%d` tokens, which exceeds allowed token limit of `%d` for model `%s`",
+	"gpt.import.author":             "OpenAI chat import by %s",
+	"gpt.contextFileTooLong":        "Context file is `%d` tokens, which exceeds allowed token limit of `%d` for model `%s`.\nPlease provide a shorter file or use `context` option instead",
+	"gpt.contextFileTooLong.title":  "Failed to process context file",
+	"gpt.contextTooLong":            "Provided context is above the limit of %d characters. Please use `context-file` option instead",
+	"gpt.channelNotAllowed":         "Conversations cannot be started in this channel. Please use %s",
+	"gpt.modelNotEnabled":           "Model `%s` is not enabled. Available models: `%s`",
+	"gpt.pending":                   "⌛ Wait a moment, please...",
+	"gpt.interactionReference":      "Failed to get interaction reference with error: %v",
+	"gpt.withheld":                  "🚫 The response was withheld by moderation",
+	"gpt.recovered":                 "🔄 The bot restarted before it could answer",
+	"gpt.retry":                     "Retry",
+	"gpt.retry.threadsOnly":         "Retry only works in chat threads",
+	"gpt.retry.locked":              "The thread is locked, an answer is probably being generated already",
+	"gpt.retry.rebuildFailed":       "Failed to rebuild the conversation from the thread",
+	"gpt.retry.answered":            "The last message of the conversation is already answered",
+	"gpt.option.kb":                 "Knowledge base of this server to answer from, see `/kb list`",
+	"gpt.kb.notFound":               "Knowledge base `%s` does not exist in this server, see `/kb list`",
+	"gpt.kb.sources":                "📚 Sources from `%s`:",
+	"gpt.kb.unavailable":            "⚠️ Knowledge base `%s` was not searched: %s",
+	"gpt.memory.learned":            "🧠 Remembered: %s",
+	"dalle.description":             "Generate creative images from textual descriptions using OpenAI Dalle 2",
+	"dalle.option.prompt":           "A text description of the desired image",
+	"dalle.option.model":            "Dall-e model",
+	"dalle.option.size":             "The size of the generated images",
+	"dalle.option.style":            "The style of the generated images (v3 only)",
+	"dalle.option.quality":          "The quality of the generated images (v3 only)",
+	"dalle.option.exact":            "Ask Dall-e 3 to use the prompt as is, without rewriting it (v3 only)",
+	"dalle.option.enhance":          "Expand a short prompt into a detailed one with a chat model before generation",
+	"dalle.option.number":           "The number of images to generate (default 1, max 4)",
+	"dalle.edit.description":        "Edit an image with a textual description using OpenAI Dall-e 2",
+	"dalle.edit.option.image":       "Square image to edit. Transparent areas are edited unless mask is provided",
+	"dalle.edit.option.mask":        "Image whose fully transparent areas indicate where the image should be edited",
+	"dalle.variation.description":   "Generate variations of an image using OpenAI Dall-e 2",
+	"dalle.variation.option.image":  "Square image to make variations of",
+	"dalle.variation.title":         "Variations of %s",
+	"dalle.image":                   "Image %d",
+	"dalle.footer.model":            "Model: %s",
+	"dalle.footer.size":             "Size: %s",
+	"dalle.footer.images":           "Images: %d",
+	"dalle.footer.cost":             "Generation Cost: $%g",
+	"dalle.imagesFailed":            "❌ Failed to get generated images",
+	"dalle.invalidImage":            "Invalid image",
+	"dalle.invalidMask":             "Invalid mask",
+	"dalle.imagePromptOptions":      "Failed to parse image and prompt options",
+	"dalle.imageOption":             "Failed to parse image option",
+	"dalle.processImage":            "Failed to process image",
+	"dalle.processMask":             "Failed to process mask",
+	"dalle.reroll":                  "Reroll",
+	"dalle.wide":                    "Wide",
+	"dalle.tall":                    "Tall",
+	"dalle.variations":              "Variations %d",
+	"dalle.promptNotFound":          "Failed to recover prompt from the message",
+	"dalle.imageNotFound":           "Failed to find the image in the message",
+	"dalle.enhanceFailed":           "❌ Failed to enhance prompt",
+	"dalle.partialFailure":          "⚠️ Failed to generate %d of %d images",
+	"kb.description":                "Manage documents that chat conversations can answer from",
+	"kb.option.kb":                  "Knowledge base name, `default` if not specified",
+	"kb.add.description":            "Add a document to a knowledge base of this server",
+	"kb.add.option.file":            "Text, markdown or code file, PDF, zip or tar archive",
+	"kb.list.description":           "List knowledge bases of this server and their documents",
+	"kb.remove.description":         "Remove a document from a knowledge base",
+	"kb.remove.option.document":     "Document name as listed by /kb list, removing an archive removes all its files",
+	"kb.fileOption":                 "Failed to parse file option",
+	"kb.tooBig":                     "File `%s` is too big, maximum allowed size is `%d` bytes",
+	"kb.addFailed":                  "Failed to add document",
+	"kb.added.title":                "📚 Added to knowledge base `%s`",
+	"kb.added":                      "Use the `kb` option of `/chat gpt` to ask about documents of the knowledge base",
+	"kb.list.title":                 "📚 Knowledge bases",
+	"kb.list.empty":                 "There are no knowledge bases yet, add documents with `/kb add`",
+	"kb.document":                   "`%s`, %d chunks, added by <@%s>",
+	"kb.list.more":                  "…and %d more",
+	"kb.removeFailed":               "Failed to remove document",
+	"kb.removed.title":              "🗑️ Removed from knowledge base `%s`",
+	"memory.description":            "Manage what the bot remembers about you across conversations",
+	"memory.add.description":        "Remember a fact about you in conversations you start",
+	"memory.add.option.fact":        "Fact to remember, e.g. your stack, preferences or project details",
+	"memory.list.description":       "List what the bot remembers about you",
+	"memory.forget.description":     "Forget a fact, or everything about you if no number is given",
+	"memory.forget.option.number":   "Number of the fact as listed by /memory list",
+	"memory.learn.description":      "Let the model remember facts you tell it during conversations",
+	"memory.learn.option.enabled":   "Whether the model can remember facts about you on its own",
+	"memory.title":                  "🧠 Memory",
+	"memory.failed":                 "Failed to update memory",
+	"memory.fact":                   "`%d` %s",
+	"memory.fact.learned":           "`%d` %s *(learned in a conversation)*",
+	"memory.added.title":            "🧠 Remembered",
+	"memory.added":                  "The fact is added to conversations you start, see /memory list",
+	"memory.list.title":             "🧠 What I remember about you",
+	"memory.list.empty":             "Nothing is remembered about you. Add facts with `/memory add`",
+	"memory.list.more":              "…and %d more",
+	"memory.learnOn":                "The model can remember facts you tell it in conversations, see /memory learn",
+	"memory.learnOff":               "The model does not remember facts on its own, see /memory learn",
+	"memory.learnEnabled":           "The model will remember facts you tell it in conversations. Everything it remembers is listed by `/memory list`",
+	"memory.learnDisabled":          "The model will not remember facts on its own anymore. Facts it remembered are kept until you forget them",
+	"memory.forgotten.title":        "🧹 Forgotten",
+	"memory.erased":                 "Everything remembered about you was erased",
+}
@@ -0,0 +1,81 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+// Catalog maps message keys to messages of one language. Messages with arguments are fmt formats
+type Catalog map[string]string
+
+// Default is the language of command definitions and the fallback for other locales
+const Default = discord.EnglishUS
+
+var catalogs = map[discord.Locale]Catalog{
+	discord.EnglishUS: english,
+	discord.German:    german,
+}
+
+// catalog finds the catalog of the locale, locales without one get the default catalog
+func catalog(locale discord.Locale) Catalog {
+	if c, ok := catalogs[locale]; ok {
+		return c
+	}
+	return catalogs[Default]
+}
+
+// T translates the message to the locale, messages missing in its catalog are in English
+func T(locale discord.Locale, key string, args ...any) string {
+	message, ok := catalog(locale)[key]
+	if !ok {
+		message, ok = catalogs[Default][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Localizations lists translations of the message to other locales, for name and description
+// localizations of commands, options and choices
+func Localizations(key string, args ...any) map[discord.Locale]string {
+	localizations := make(map[discord.Locale]string)
+	for locale, c := range catalogs {
+		if locale == Default {
+			continue
+		}
+		message, ok := c[key]
+		if !ok {
+			continue
+		}
+		if len(args) != 0 {
+			message = fmt.Sprintf(message, args...)
+		}
+		localizations[locale] = message
+	}
+	return localizations
+}
+
+// Matches reports whether s is the message in any locale, e.g. to recognize messages the bot sent earlier.
+// Formatted messages match if s starts with the text before the first argument
+func Matches(key string, s string) bool {
+	for _, c := range catalogs {
+		message, ok := c[key]
+		if !ok {
+			continue
+		}
+		if prefix, _, formatted := strings.Cut(message, "%"); formatted {
+			if prefix != "" && strings.HasPrefix(s, prefix) {
+				return true
+			}
+		} else if s == message {
+			return true
+		}
+	}
+	return false
+}
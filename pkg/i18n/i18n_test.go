@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	discord "github.com/bwmarrin/discordgo"
+)
+
+var verbPattern = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogsHaveEveryKey(t *testing.T) {
+	for locale, c := range catalogs {
+		for key := range catalogs[Default] {
+			if _, ok := c[key]; !ok {
+				t.Errorf("%s: missing key %q", locale, key)
+			}
+		}
+		for key := range c {
+			if _, ok := catalogs[Default][key]; !ok {
+				t.Errorf("%s: unknown key %q", locale, key)
+			}
+		}
+	}
+}
+
+func TestCatalogsHaveSameArguments(t *testing.T) {
+	for locale, c := range catalogs {
+		for key, message := range c {
+			want := verbPattern.FindAllString(catalogs[Default][key], -1)
+			if got := verbPattern.FindAllString(message, -1); !slices.Equal(got, want) {
+				t.Errorf("%s: %q has arguments %v, want %v", locale, key, got, want)
+			}
+		}
+	}
+}
+
+// Discord rejects command and option descriptions longer than 100 characters
+func TestDescriptionsFitDiscordLimit(t *testing.T) {
+	for locale, c := range catalogs {
+		for key, message := range c {
+			if !strings.HasSuffix(key, ".description") && !strings.Contains(key, ".option.") {
+				continue
+			}
+			if n := utf8.RuneCountInString(message); n > 100 {
+				t.Errorf("%s: %q is %d characters long", locale, key, n)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		locale discord.Locale
+		key    string
+		args   []any
+		want   string
+	}{
+		{discord.EnglishUS, "gpt.retry", nil, "Retry"},
+		{discord.German, "gpt.retry", nil, "Erneut versuchen"},
+		{discord.German, "queue.position", []any{2}, "⌛ Du bist Nr. 2 in der Warteschlange"},
+		{discord.French, "queue.position", []any{3}, "⌛ You are #3 in queue"},
+		{discord.German, "missing.key", nil, "missing.key"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.locale, tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%s, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizations(t *testing.T) {
+	localizations := Localizations("gpt.description")
+	if _, ok := localizations[Default]; ok {
+		t.Errorf("localizations include the default locale")
+	}
+	if got, want := localizations[discord.German], german["gpt.description"]; got != want {
+		t.Errorf("german localization = %q, want %q", got, want)
+	}
+	if got, want := Localizations("choice.default", "gpt-4o")[discord.German], "gpt-4o (Standard)"; got != want {
+		t.Errorf("formatted german localization = %q, want %q", got, want)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		key  string
+		s    string
+		want bool
+	}{
+		{"gpt.pending", english["gpt.pending"], true},
+		{"gpt.pending", german["gpt.pending"], true},
+		{"gpt.pending", "⌛ Wait", false},
+		{"queue.position", T(discord.German, "queue.position", 5), true},
+		{"queue.position", T(discord.EnglishUS, "queue.position", 5), true},
+		{"queue.position", "Hello", false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.key, tt.s); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.key, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package kb
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// chunkText splits the text into chunks of up to size characters, preferably between lines. Every chunk
+// starts with the last overlap characters of the previous one, so passages cut in two are found in both.
+// Overlap must be less than size
+func chunkText(text string, size int, overlap int) []string {
+	var chunks []string
+	var chunk strings.Builder
+	// length of the chunk, and how much of it is not repeated from the previous one
+	length, fresh := 0, 0
+	flush := func() {
+		s := chunk.String()
+		if strings.TrimSpace(s) != "" {
+			chunks = append(chunks, strings.TrimSpace(s))
+		}
+		runes := []rune(s)
+		tail := string(runes[max(len(runes)-overlap, 0):])
+		chunk.Reset()
+		chunk.WriteString(tail)
+		length, fresh = utf8.RuneCountInString(tail), 0
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		for line != "" {
+			lineLength := utf8.RuneCountInString(line)
+			if length+lineLength <= size {
+				chunk.WriteString(line)
+				length += lineLength
+				if strings.TrimSpace(line) != "" {
+					fresh += lineLength
+				}
+				break
+			}
+			if fresh > 0 {
+				// line starts the next chunk
+				flush()
+				continue
+			}
+			if strings.TrimSpace(line) == "" {
+				break
+			}
+			// line is too long for any chunk, cut it
+			runes := []rune(line)
+			cut := size - length
+			chunk.WriteString(string(runes[:cut]))
+			length += cut
+			fresh += cut
+			line = string(runes[cut:])
+			flush()
+		}
+	}
+	if fresh > 0 {
+		flush()
+	}
+	return chunks
+}
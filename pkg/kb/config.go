@@ -0,0 +1,92 @@
+package kb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	EmbeddingsOpenAI = "openai"
+	EmbeddingsLocal  = "local"
+)
+
+const (
+	DefaultEmbeddingModel = string(openai.SmallEmbedding3)
+	DefaultChunkSize      = 1500
+	DefaultChunkOverlap   = 200
+	DefaultTopK           = 4
+	DefaultMaxFileSize    = 10 << 20
+)
+
+type Config struct {
+	// Directory knowledge bases are stored in. Knowledge bases are disabled if empty
+	Dir string `yaml:"dir"`
+	// Embeddings provider: openai (default) or local. Local one matches words rather than meaning,
+	// for deployments without access to the Embeddings API
+	Embeddings string `yaml:"embeddings"`
+	// OpenAI embedding model, text-embedding-3-small by default
+	Model string `yaml:"model"`
+	// Size of document chunks in characters, and how many characters of a chunk are repeated in the next one
+	ChunkSize    int `yaml:"chunkSize"`
+	ChunkOverlap int `yaml:"chunkOverlap"`
+	// How many chunks are retrieved for every message of a conversation
+	TopK int `yaml:"topK"`
+	// Maximum size of an uploaded document in bytes, also of unpacked archives and decompressed PDF streams
+	MaxFileSize int `yaml:"maxFileSize"`
+}
+
+// Enabled reports whether knowledge bases are configured
+func (c *Config) Enabled() bool {
+	return c.Dir != ""
+}
+
+func (c *Config) withDefaults() Config {
+	config := *c
+	if config.Embeddings == "" {
+		config.Embeddings = EmbeddingsOpenAI
+	}
+	if config.Model == "" {
+		config.Model = DefaultEmbeddingModel
+	}
+	if config.ChunkSize == 0 {
+		config.ChunkSize = DefaultChunkSize
+	}
+	if config.ChunkOverlap == 0 {
+		config.ChunkOverlap = min(DefaultChunkOverlap, config.ChunkSize/2)
+	}
+	if config.TopK == 0 {
+		config.TopK = DefaultTopK
+	}
+	if config.MaxFileSize == 0 {
+		config.MaxFileSize = DefaultMaxFileSize
+	}
+	return config
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Embeddings {
+	case "", EmbeddingsOpenAI, EmbeddingsLocal:
+	default:
+		errs = append(errs, fmt.Errorf("embeddings: unknown provider %q, expected %s or %s", c.Embeddings, EmbeddingsOpenAI, EmbeddingsLocal))
+	}
+	if c.ChunkSize < 0 {
+		errs = append(errs, fmt.Errorf("chunkSize: %d must not be negative", c.ChunkSize))
+	}
+	if c.ChunkOverlap < 0 {
+		errs = append(errs, fmt.Errorf("chunkOverlap: %d must not be negative", c.ChunkOverlap))
+	}
+	if config := c.withDefaults(); c.ChunkSize >= 0 && config.ChunkOverlap >= config.ChunkSize {
+		errs = append(errs, fmt.Errorf("chunkOverlap: %d must be less than chunk size %d", config.ChunkOverlap, config.ChunkSize))
+	}
+	if c.TopK < 0 {
+		errs = append(errs, fmt.Errorf("topK: %d must not be negative", c.TopK))
+	}
+	if c.MaxFileSize < 0 {
+		errs = append(errs, fmt.Errorf("maxFileSize: %d must not be negative", c.MaxFileSize))
+	}
+	return errors.Join(errs...)
+}
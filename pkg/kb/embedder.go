@@ -0,0 +1,117 @@
+package kb
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Embedder turns texts into vectors, similar texts get vectors pointing in similar directions
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Name identifies the embedder and its model. Vectors of different embedders cannot be compared
+	Name() string
+}
+
+// Texts sent to the Embeddings API in one request
+const openAIEmbeddingsBatchSize = 100
+
+type openAIEmbedder struct {
+	client *openai.Client
+	model  string
+}
+
+func (e *openAIEmbedder) Name() string {
+	return EmbeddingsOpenAI + "/" + e.model
+}
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += openAIEmbeddingsBatchSize {
+		batch := texts[start:min(start+openAIEmbeddingsBatchSize, len(texts))]
+		resp, err := e.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+			Input: batch,
+			Model: openai.EmbeddingModel(e.model),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("embeddings API returned %d embeddings for %d texts", len(resp.Data), len(batch))
+		}
+		embeddings := make([][]float32, len(batch))
+		for _, embedding := range resp.Data {
+			if embedding.Index < 0 || embedding.Index >= len(batch) {
+				return nil, fmt.Errorf("embeddings API returned embedding of unknown text %d", embedding.Index)
+			}
+			embeddings[embedding.Index] = embedding.Embedding
+		}
+		vectors = append(vectors, embeddings...)
+	}
+	return vectors, nil
+}
+
+// Dimensions of vectors made by the local embedder
+const localEmbeddingDimensions = 512
+
+// localEmbedder is a stand-in for deployments without access to the Embeddings API. Words are hashed into
+// dimensions of the vector, so texts sharing words are similar, synonyms are not
+type localEmbedder struct{}
+
+func (localEmbedder) Name() string {
+	return EmbeddingsLocal
+}
+
+func (localEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, localEmbeddingDimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%localEmbeddingDimensions]++
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors, nil
+}
+
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+// similarity is cosine similarity of the vectors
+func similarity(a []float32, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
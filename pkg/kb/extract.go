@@ -0,0 +1,154 @@
+package kb
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// File is a text document extracted from an upload, archives hold many of them
+type File struct {
+	Name string
+	Text string
+}
+
+var errNoText = errors.New("no text found")
+
+// Extract gets text out of an uploaded document: plain text, markdown and code as is, text of PDFs, and text
+// files in zip and tar archives. Archives may not unpack, and PDFs decompress, to more than maxSize bytes
+func Extract(name string, data []byte, maxSize int) ([]File, error) {
+	lower := strings.ToLower(name)
+	var files []File
+	var err error
+	switch {
+	case strings.HasSuffix(lower, ".pdf"):
+		var text string
+		if text, err = extractPDF(data, maxSize); err == nil {
+			files = []File{{Name: name, Text: text}}
+		}
+	case strings.HasSuffix(lower, ".zip"):
+		files, err = extractZip(name, data, maxSize)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			files, err = extractTar(name, r, maxSize)
+		}
+	case strings.HasSuffix(lower, ".tar"):
+		files, err = extractTar(name, bytes.NewReader(data), maxSize)
+	default:
+		text, ok := plainText(data)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a text document, supported are text, markdown and code files, PDFs, zip and tar archives", name)
+		}
+		files = []File{{Name: name, Text: text}}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	nonEmpty := files[:0]
+	for _, file := range files {
+		if strings.TrimSpace(file.Text) != "" {
+			nonEmpty = append(nonEmpty, file)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil, fmt.Errorf("%s: %w", name, errNoText)
+	}
+	return nonEmpty, nil
+}
+
+// plainText reports whether the data is text, binary files are not valid UTF-8 or have NUL bytes
+func plainText(data []byte) (string, bool) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return "", false
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), true
+}
+
+// archivedFile reports whether the file of an archive should be indexed, hidden files and metadata are skipped
+func archivedFile(name string) bool {
+	for _, part := range strings.Split(path.Clean(name), "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" || part == "node_modules" || part == "vendor" {
+			return false
+		}
+	}
+	return true
+}
+
+// limitedReader reads files of an archive until they are maxSize bytes in total
+type limitedReader struct {
+	left int
+}
+
+func (l *limitedReader) read(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(l.left)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > l.left {
+		return nil, errors.New("archive is too big when unpacked")
+	}
+	l.left -= len(data)
+	return data, nil
+}
+
+func extractZip(name string, data []byte, maxSize int) ([]File, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	limit := &limitedReader{left: maxSize}
+	var files []File
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() || !archivedFile(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := limit.read(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if text, ok := plainText(content); ok {
+			files = append(files, File{Name: name + "/" + f.Name, Text: text})
+		}
+	}
+	return files, nil
+}
+
+func extractTar(name string, r io.Reader, maxSize int) ([]File, error) {
+	tr := tar.NewReader(r)
+	limit := &limitedReader{left: maxSize}
+	var files []File
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg || !archivedFile(header.Name) {
+			continue
+		}
+		content, err := limit.read(tr)
+		if err != nil {
+			return nil, err
+		}
+		if text, ok := plainText(content); ok {
+			files = append(files, File{Name: name + "/" + strings.TrimPrefix(header.Name, "./"), Text: text})
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package kb
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		size    int
+		overlap int
+		want    []string
+	}{
+		{
+			name: "fits one chunk",
+			text: "one\ntwo\n",
+			size: 20,
+			want: []string{"one\ntwo"},
+		},
+		{
+			name: "split between lines",
+			text: "aaaa\nbbbb\ncccc\n",
+			size: 10,
+			want: []string{"aaaa\nbbbb", "cccc"},
+		},
+		{
+			name:    "overlap repeats the tail",
+			text:    "aaaa\nbbbb\ncccc\n",
+			size:    10,
+			overlap: 5,
+			want:    []string{"aaaa\nbbbb", "bbbb\ncccc"},
+		},
+		{
+			name: "long line is cut",
+			text: "abcdefghij",
+			size: 4,
+			want: []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name: "blank lines only",
+			text: "\n\n   \n",
+			size: 4,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkText(tt.text, tt.size, tt.overlap); !slices.Equal(got, tt.want) {
+				t.Errorf("chunkText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range sortedNames(files) {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(files[name]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, name := range sortedNames(files) {
+		w.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+		w.Write([]byte(files[name]))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// pdfDocument makes a PDF with the content streams, the first one is compressed
+func pdfDocument(t *testing.T, streams ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, stream := range streams {
+		if i == 0 {
+			var compressed bytes.Buffer
+			w := zlib.NewWriter(&compressed)
+			w.Write([]byte(stream))
+			w.Close()
+			fmt.Fprintf(&buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n", i+1, compressed.Len(), compressed.Bytes())
+		} else {
+			fmt.Fprintf(&buf, "%d 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", i+1, len(stream), stream)
+		}
+	}
+	buf.WriteString("%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    func(t *testing.T) []byte
+		want    []File
+		wantErr bool
+	}{
+		{
+			name: "text",
+			file: "notes.md",
+			data: func(*testing.T) []byte { return []byte("\ufeff# Notes\r\nText\r\n") },
+			want: []File{{Name: "notes.md", Text: "# Notes\nText\n"}},
+		},
+		{
+			name:    "binary",
+			file:    "image.png",
+			data:    func(*testing.T) []byte { return []byte{0x89, 'P', 'N', 'G', 0, 0} },
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			file:    "empty.txt",
+			data:    func(*testing.T) []byte { return []byte(" \n") },
+			wantErr: true,
+		},
+		{
+			name: "zip",
+			file: "docs.zip",
+			data: func(t *testing.T) []byte {
+				return zipArchive(t, map[string]string{
+					"a.txt":            "A",
+					"dir/b.go":         "package b",
+					".git/config":      "hidden",
+					"__MACOSX/._a.txt": "metadata",
+					"bin/tool":         "\x00\x01",
+				})
+			},
+			want: []File{{Name: "docs.zip/a.txt", Text: "A"}, {Name: "docs.zip/dir/b.go", Text: "package b"}},
+		},
+		{
+			name: "tar",
+			file: "docs.tar",
+			data: func(t *testing.T) []byte {
+				return tarArchive(t, map[string]string{"./a.txt": "A", "node_modules/x.js": "x"})
+			},
+			want: []File{{Name: "docs.tar/a.txt", Text: "A"}},
+		},
+		{
+			name: "archive too big",
+			file: "big.zip",
+			data: func(t *testing.T) []byte {
+				return zipArchive(t, map[string]string{"a.txt": strings.Repeat("a", 100)})
+			},
+			wantErr: true,
+		},
+		{
+			name: "pdf",
+			file: "paper.PDF",
+			data: func(t *testing.T) []byte {
+				return pdfDocument(t,
+					"BT /F1 12 Tf 72 712 Td (Hello \\(PDF\\)) Tj 0 -14 Td [(Wor) 20 (ld) -300 (again)] TJ ET",
+					"BT (Second) Tj T* <4F4B> Tj ET",
+				)
+			},
+			want: []File{{Name: "paper.PDF", Text: "Hello (PDF)\nWorld again\nSecond\nOK\n"}},
+		},
+		{
+			name: "pdf too big when decompressed",
+			file: "bomb.pdf",
+			data: func(t *testing.T) []byte {
+				return pdfDocument(t, "BT ("+strings.Repeat("a", 1000)+") Tj ET")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pdf without text",
+			file: "scan.pdf",
+			data: func(t *testing.T) []byte {
+				return pdfDocument(t, "q 100 0 0 100 0 0 cm /Im1 Do Q")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.file, tt.data(t), 96)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Extract() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Extract() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestStore(t *testing.T) *Store {
+	store, err := New(Config{Dir: t.TempDir(), Embeddings: EmbeddingsLocal, ChunkSize: 80}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func documentNames(documents []Document) []string {
+	var names []string
+	for _, d := range documents {
+		names = append(names, d.Name)
+	}
+	return names
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	allowed := func(Document) bool { return true }
+
+	if _, err := store.Add(ctx, "g1", "Manual", "u1", "a.txt", []byte("text")); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("Add() with invalid name error = %v, want %v", err, ErrInvalidName)
+	}
+
+	_, err := store.Add(ctx, "g1", "manual", "u1", "cats.txt", []byte("Cats sleep most of the day.\nCats purr when they are happy.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zipArchive(t, map[string]string{"rockets.md": "Rockets burn fuel to reach orbit.", "boats.md": "Boats float on water."})
+	documents, err := store.Add(ctx, "g1", "manual", "u2", "vehicles.zip", archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := documentNames(documents), []string{"vehicles.zip/boats.md", "vehicles.zip/rockets.md"}; !slices.Equal(got, want) {
+		t.Errorf("Add() = %q, want %q", got, want)
+	}
+
+	results, err := store.Search(ctx, "g1", "manual", "why do rockets need fuel?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Document != "vehicles.zip/rockets.md" || results[0].Chunk != 1 {
+		t.Errorf("Search() = %+v, want rockets.md first", results)
+	}
+	if _, err := store.Search(ctx, "g2", "manual", "rockets"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Search() in other guild error = %v, want %v", err, ErrNotFound)
+	}
+
+	// Knowledge bases survive restarts
+	reopened, err := New(store.config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := reopened.List("g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "manual" || len(list[0].Documents) != 3 {
+		t.Fatalf("List() = %+v, want manual with 3 documents", list)
+	}
+
+	if _, err := reopened.Remove("g1", "manual", "vehicles.zip", func(d Document) bool { return d.UserID == "u1" }); err == nil {
+		t.Error("Remove() of a document added by someone else succeeded")
+	}
+	removed, err := reopened.Remove("g1", "manual", "vehicles.zip", allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Remove() = %q, want both files of the archive", documentNames(removed))
+	}
+	results, err = reopened.Search(ctx, "g1", "manual", "rockets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Document != "cats.txt" {
+			t.Errorf("Search() found removed document %s", result.Document)
+		}
+	}
+
+	// Removing the last document removes the knowledge base
+	if _, err := reopened.Remove("g1", "manual", "cats.txt", allowed); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := reopened.Exists("g1", "manual"); err != nil || exists {
+		t.Errorf("Exists() = %v, %v, want false", exists, err)
+	}
+}
@@ -0,0 +1,225 @@
+package kb
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// extractPDF gets text out of content streams of the PDF. It is no PDF renderer: text is taken from text
+// showing operators in the order it is drawn, which is the reading order for PDFs made by most editors.
+// Uncompressed and Flate compressed streams are supported. Scanned documents have no text, and fonts that
+// remap characters give unreadable text. Compressed streams may not decompress to more than maxSize bytes
+func extractPDF(data []byte, maxSize int) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", errors.New("not a PDF document")
+	}
+
+	var text strings.Builder
+	left := maxSize
+	rest := data
+	for {
+		start := bytes.Index(rest, []byte("stream"))
+		if start < 0 {
+			break
+		}
+		// Stream dictionary is between the object header and the stream keyword
+		dictionary := rest[:start]
+		if obj := bytes.LastIndex(dictionary, []byte("obj")); obj >= 0 {
+			dictionary = dictionary[obj:]
+		}
+		body := rest[start+len("stream"):]
+		body = bytes.TrimPrefix(body, []byte("\r"))
+		body = bytes.TrimPrefix(body, []byte("\n"))
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		rest = body[end+len("endstream"):]
+
+		content := body[:end]
+		if bytes.Contains(dictionary, []byte("/Filter")) {
+			if !bytes.Contains(dictionary, []byte("/FlateDecode")) || bytes.Contains(dictionary, []byte("/DecodeParms")) {
+				continue
+			}
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// Streams may have trailing garbage, take what was decompressed
+			content, _ = io.ReadAll(io.LimitReader(r, int64(left)+1))
+			if len(content) > left {
+				return "", errors.New("PDF is too big when decompressed")
+			}
+			left -= len(content)
+		}
+		if !bytes.Contains(content, []byte("BT")) {
+			// not a content stream with text
+			continue
+		}
+		text.WriteString(pdfContentText(content))
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return "", errNoText
+	}
+	return text.String(), nil
+}
+
+// pdfContentText runs text operators of the content stream
+func pdfContentText(content []byte) string {
+	var text, line strings.Builder
+	var operands []string
+	inArray := false
+	newLine := func() {
+		if s := strings.TrimSpace(line.String()); s != "" {
+			text.WriteString(s)
+			text.WriteByte('\n')
+		}
+		line.Reset()
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '(':
+			s, n := pdfLiteralString(content[i:])
+			operands = append(operands, s)
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] == '<', c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return text.String()
+			}
+			operands = append(operands, pdfHexString(content[i+1:i+end]))
+			i += end + 1
+		case c == '[':
+			inArray = true
+			i++
+		case c == ']':
+			inArray = false
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case pdfDelimiter(c):
+			i++
+		default:
+			start := i
+			for i < len(content) && !pdfDelimiter(content[i]) && !strings.ContainsRune("()<>[]%", rune(content[i])) {
+				i++
+			}
+			token := string(content[start:i])
+			if number, err := strconv.ParseFloat(token, 64); err == nil {
+				if inArray && number <= -200 {
+					// large kerning in TJ array separates words
+					operands = append(operands, " ")
+				}
+				continue
+			}
+			switch token {
+			case "Tj", "TJ":
+				line.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				newLine()
+				line.WriteString(strings.Join(operands, ""))
+			case "T*", "Td", "TD", "ET":
+				newLine()
+			}
+			if !strings.HasPrefix(token, "/") {
+				operands = nil
+			}
+		}
+	}
+	newLine()
+	return text.String()
+}
+
+func pdfDelimiter(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// pdfLiteralString decodes the string in parentheses at the start of data, returns it and its length
+func pdfLiteralString(data []byte) (string, int) {
+	var s strings.Builder
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return s.String(), i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(data) {
+				return s.String(), i
+			}
+			switch e := data[i]; e {
+			case 'n':
+				s.WriteByte('\n')
+			case 'r', 'b', 'f':
+			case 't':
+				s.WriteByte('\t')
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7' {
+						j++
+					}
+					code, _ := strconv.ParseUint(string(data[i:j]), 8, 8)
+					writePDFChar(&s, byte(code))
+					i = j - 1
+				} else {
+					s.WriteByte(e)
+				}
+			}
+			continue
+		}
+		writePDFChar(&s, c)
+	}
+	return s.String(), len(data)
+}
+
+// pdfHexString decodes a hex string, strings of multibyte character codes can't be read without their font
+func pdfHexString(data []byte) string {
+	hex := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return r
+		}
+		return -1
+	}, string(data))
+	if len(hex)%2 == 1 {
+		hex += "0"
+	}
+	var s strings.Builder
+	for i := 0; i < len(hex); i += 2 {
+		code, _ := strconv.ParseUint(hex[i:i+2], 16, 8)
+		if code < 0x20 {
+			return ""
+		}
+		writePDFChar(&s, byte(code))
+	}
+	return s.String()
+}
+
+// writePDFChar writes a character of a simple font, most of them use Latin-1 compatible encodings
+func writePDFChar(s *strings.Builder, c byte) {
+	if c < 0x20 && c != '\n' && c != '\t' {
+		return
+	}
+	s.WriteRune(rune(c))
+}
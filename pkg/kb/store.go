@@ -0,0 +1,353 @@
+package kb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var (
+	ErrNotFound        = errors.New("knowledge base not found")
+	ErrInvalidName     = errors.New("knowledge base name must be 1-32 lowercase letters, digits, dashes or underscores")
+	ErrEmbedderChanged = errors.New("knowledge base was built with other embeddings, remove its documents and add them again")
+)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ValidName reports whether the name can be used for a knowledge base
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Document is an uploaded file of a knowledge base
+type Document struct {
+	Name   string    `json:"name"`
+	UserID string    `json:"userId"`
+	Added  time.Time `json:"added"`
+	Chunks int       `json:"chunks"`
+}
+
+type chunk struct {
+	Document string    `json:"document"`
+	Index    int       `json:"index"`
+	Text     string    `json:"text"`
+	Vector   []float32 `json:"vector"`
+}
+
+// index is a knowledge base in memory. It is never changed once loaded, changes make a new one
+type index struct {
+	Embedder  string     `json:"embedder"`
+	Documents []Document `json:"documents"`
+	Chunks    []chunk    `json:"chunks"`
+}
+
+// KnowledgeBase lists documents of a knowledge base
+type KnowledgeBase struct {
+	Name      string
+	Documents []Document
+}
+
+// Result is a chunk of a document found by Search. Chunks are numbered from 1
+type Result struct {
+	Document string
+	Chunk    int
+	Text     string
+	Score    float32
+}
+
+// Store keeps knowledge bases of guilds as JSON files in the directory, a file per knowledge base. Loaded
+// knowledge bases stay in memory
+type Store struct {
+	config   Config
+	embedder Embedder
+
+	mu      sync.Mutex
+	indexes map[string]*index
+}
+
+// New creates the store, returns nil store if knowledge bases are disabled
+func New(config Config, client *openai.Client) (*Store, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+	config = config.withDefaults()
+	s := &Store{config: config, indexes: make(map[string]*index)}
+	switch config.Embeddings {
+	case EmbeddingsOpenAI:
+		if client == nil {
+			return nil, fmt.Errorf("embeddings provider %s requires OpenAI client", EmbeddingsOpenAI)
+		}
+		s.embedder = &openAIEmbedder{client: client, model: config.Model}
+	case EmbeddingsLocal:
+		s.embedder = localEmbedder{}
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider: %s", config.Embeddings)
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// MaxFileSize is the maximum size of a document in bytes
+func (s *Store) MaxFileSize() int {
+	return s.config.MaxFileSize
+}
+
+func (s *Store) file(guildID string, name string) string {
+	return filepath.Join(s.config.Dir, guildID, name+".json")
+}
+
+// load returns the knowledge base, nil if there is none. Must be called with the lock held
+func (s *Store) load(guildID string, name string) (*index, error) {
+	file := s.file(guildID, name)
+	if idx, ok := s.indexes[file]; ok {
+		return idx, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	s.indexes[file] = idx
+	return idx, nil
+}
+
+// save replaces the knowledge base, empty one is removed. Must be called with the lock held
+func (s *Store) save(guildID string, name string, idx *index) error {
+	file := s.file(guildID, name)
+	if len(idx.Documents) == 0 {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		delete(s.indexes, file)
+		return nil
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so the file is never left half-written
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.indexes[file] = idx
+	return nil
+}
+
+// Add extracts, chunks and embeds the uploaded file, and adds its documents to the knowledge base. The knowledge
+// base is created if there is none. Documents replace ones with the same name
+func (s *Store) Add(ctx context.Context, guildID string, name string, userID string, filename string, data []byte) ([]Document, error) {
+	if !ValidName(name) {
+		return nil, ErrInvalidName
+	}
+	files, err := Extract(filename, data, s.config.MaxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []chunk
+	var texts []string
+	documents := make([]Document, 0, len(files))
+	for _, file := range files {
+		document := Document{Name: file.Name, UserID: userID, Added: time.Now().UTC()}
+		for i, text := range chunkText(file.Text, s.config.ChunkSize, s.config.ChunkOverlap) {
+			chunks = append(chunks, chunk{Document: file.Name, Index: i + 1, Text: text})
+			texts = append(texts, text)
+			document.Chunks++
+		}
+		documents = append(documents, document)
+	}
+	vectors, err := s.embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed %s: %w", filename, err)
+	}
+	for i := range chunks {
+		chunks[i].Vector = vectors[i]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.load(guildID, name)
+	if err != nil {
+		return nil, err
+	}
+	idx := &index{Embedder: s.embedder.Name()}
+	if old != nil {
+		if old.Embedder != idx.Embedder {
+			return nil, ErrEmbedderChanged
+		}
+		replaced := func(document string) bool {
+			return slices.ContainsFunc(documents, func(d Document) bool { return d.Name == document })
+		}
+		for _, d := range old.Documents {
+			if !replaced(d.Name) {
+				idx.Documents = append(idx.Documents, d)
+			}
+		}
+		for _, c := range old.Chunks {
+			if !replaced(c.Document) {
+				idx.Chunks = append(idx.Chunks, c)
+			}
+		}
+	}
+	idx.Documents = append(idx.Documents, documents...)
+	idx.Chunks = append(idx.Chunks, chunks...)
+	if err := s.save(guildID, name, idx); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// Remove removes the document, and documents of the archive if it is one, from the knowledge base. Documents
+// are only removed if allowed returns true for all of them
+func (s *Store) Remove(guildID string, name string, document string, allowed func(Document) bool) ([]Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.load(guildID, name)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, ErrNotFound
+	}
+	matches := func(d string) bool {
+		return d == document || strings.HasPrefix(d, document+"/")
+	}
+
+	idx := &index{Embedder: old.Embedder}
+	var removed []Document
+	for _, d := range old.Documents {
+		if matches(d.Name) {
+			if !allowed(d) {
+				return nil, fmt.Errorf("document %s was added by someone else", d.Name)
+			}
+			removed = append(removed, d)
+		} else {
+			idx.Documents = append(idx.Documents, d)
+		}
+	}
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("document %s not found in knowledge base %s", document, name)
+	}
+	for _, c := range old.Chunks {
+		if !matches(c.Document) {
+			idx.Chunks = append(idx.Chunks, c)
+		}
+	}
+	if err := s.save(guildID, name, idx); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// List lists knowledge bases of the guild sorted by name
+func (s *Store) List(guildID string) ([]KnowledgeBase, error) {
+	entries, err := os.ReadDir(filepath.Join(s.config.Dir, guildID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var knowledgeBases []KnowledgeBase
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || !ValidName(name) {
+			continue
+		}
+		idx, err := s.load(guildID, name)
+		if err != nil {
+			return nil, err
+		}
+		if idx != nil {
+			knowledgeBases = append(knowledgeBases, KnowledgeBase{Name: name, Documents: idx.Documents})
+		}
+	}
+	sort.Slice(knowledgeBases, func(i, j int) bool { return knowledgeBases[i].Name < knowledgeBases[j].Name })
+	return knowledgeBases, nil
+}
+
+// Exists reports whether the guild has the knowledge base
+func (s *Store) Exists(guildID string, name string) (bool, error) {
+	if !ValidName(name) {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, err := s.load(guildID, name)
+	return idx != nil, err
+}
+
+// Search finds chunks of the knowledge base most similar to the query, best ones first
+func (s *Store) Search(ctx context.Context, guildID string, name string, query string) ([]Result, error) {
+	if !ValidName(name) {
+		return nil, ErrNotFound
+	}
+	s.mu.Lock()
+	idx, err := s.load(guildID, name)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if idx == nil {
+		return nil, ErrNotFound
+	}
+	if idx.Embedder != s.embedder.Name() {
+		return nil, ErrEmbedderChanged
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(idx.Chunks))
+	for _, c := range idx.Chunks {
+		if score := similarity(vectors[0], c.Vector); score > 0 {
+			results = append(results, Result{Document: c.Document, Chunk: c.Index, Text: c.Text, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results[:min(len(results), s.config.TopK)], nil
+}
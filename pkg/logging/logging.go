@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Config struct {
+	// debug, info, warn or error. Defaults to info
+	Level string `yaml:"level"`
+	// text or json. Defaults to text
+	Format string `yaml:"format"`
+}
+
+// Level of the default logger, it can be changed without replacing the logger
+var level slog.LevelVar
+
+// Setup makes a logger for the config the default one. Standard log package writes to it too
+func Setup(config Config) error {
+	return setup(os.Stdout, config)
+}
+
+func setup(w io.Writer, config Config) error {
+	if err := SetLevel(config); err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{AddSource: true, Level: &level}
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format %q", config.Format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLevel changes level of the default logger
+func SetLevel(config Config) error {
+	l, err := parseLevel(config.Level)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return l, fmt.Errorf("unknown log level %q, expected one of debug, info, warn, error", s)
+	}
+	return l, nil
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := parseLevel(c.Level); err != nil {
+		errs = append(errs, fmt.Errorf("level: %w", err))
+	}
+	switch strings.ToLower(c.Format) {
+	case "", FormatText, FormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("format: unknown log format %q, expected %s or %s", c.Format, FormatText, FormatJSON))
+	}
+	return errors.Join(errs...)
+}
+
+// Content is user or model provided text, e.g. a prompt. It is only logged as is with debug level,
+// otherwise it is redacted to its length
+type Content string
+
+func (c Content) LogValue() slog.Value {
+	if level.Level() <= slog.LevelDebug {
+		return slog.StringValue(string(c))
+	}
+	return slog.StringValue(fmt.Sprintf("[redacted, %d characters]", len([]rune(c))))
+}
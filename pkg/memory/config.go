@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	DefaultMaxFacts      = 50
+	DefaultMaxFactLength = 300
+	DefaultTokenBudget   = 500
+)
+
+type Config struct {
+	// JSON file memories of users are stored in. Memory is disabled if empty
+	File string `yaml:"file"`
+	// How many facts a user can have remembered, and how long each of them can be in characters
+	MaxFacts      int `yaml:"maxFacts"`
+	MaxFactLength int `yaml:"maxFactLength"`
+	// Maximum tokens of facts added to the system message of a conversation, newest facts are kept
+	TokenBudget int `yaml:"tokenBudget"`
+}
+
+// Enabled reports whether memory is configured
+func (c *Config) Enabled() bool {
+	return c.File != ""
+}
+
+func (c *Config) withDefaults() Config {
+	config := *c
+	if config.MaxFacts == 0 {
+		config.MaxFacts = DefaultMaxFacts
+	}
+	if config.MaxFactLength == 0 {
+		config.MaxFactLength = DefaultMaxFactLength
+	}
+	if config.TokenBudget == 0 {
+		config.TokenBudget = DefaultTokenBudget
+	}
+	return config
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	if c.MaxFacts < 0 {
+		errs = append(errs, fmt.Errorf("maxFacts: %d must not be negative", c.MaxFacts))
+	}
+	if c.MaxFactLength < 0 {
+		errs = append(errs, fmt.Errorf("maxFactLength: %d must not be negative", c.MaxFactLength))
+	}
+	if c.TokenBudget < 0 {
+		errs = append(errs, fmt.Errorf("tokenBudget: %d must not be negative", c.TokenBudget))
+	}
+	return errors.Join(errs...)
+}
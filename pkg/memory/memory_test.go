@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestStore(t *testing.T, config Config) *Store {
+	config.File = filepath.Join(t.TempDir(), "memory.json")
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func factTexts(u User) (texts []string) {
+	for _, f := range u.Facts {
+		texts = append(texts, f.Text)
+	}
+	return
+}
+
+func TestStore(t *testing.T) {
+	s := newTestStore(t, Config{MaxFacts: 2, MaxFactLength: 20})
+
+	if _, err := s.Add("u1", "  Uses   Go\n", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("u1", "uses go", true); err != nil {
+		t.Fatalf("Add() of remembered fact error = %v", err)
+	}
+	if _, err := s.Add("u1", " ", false); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Add() of empty fact error = %v, want %v", err, ErrEmpty)
+	}
+	if _, err := s.Add("u1", strings.Repeat("a", 21), false); err == nil {
+		t.Error("Add() of too long fact succeeded")
+	}
+	if _, err := s.Add("u1", "Likes tabs", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("u1", "Third fact", false); err == nil {
+		t.Error("Add() over maximum facts succeeded")
+	}
+	if got := factTexts(s.Get("u1")); strings.Join(got, "|") != "Uses Go|Likes tabs" {
+		t.Errorf("facts = %q, want [Uses Go Likes tabs]", got)
+	}
+	if u := s.Get("u2"); len(u.Facts) != 0 || u.Learn {
+		t.Errorf("other user memory = %+v, want none", u)
+	}
+
+	// Memory survives restarts
+	reopened, err := New(s.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := reopened.Get("u1"); len(u.Facts) != 2 || u.Facts[0].Learned || !u.Facts[1].Learned {
+		t.Errorf("reopened memory = %+v, want both facts", u)
+	}
+
+	if _, err := reopened.Forget("u1", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Forget() of missing fact error = %v, want %v", err, ErrNotFound)
+	}
+	fact, err := reopened.Forget("u1", 1)
+	if err != nil || fact.Text != "Uses Go" {
+		t.Errorf("Forget() = %q, %v, want Uses Go", fact.Text, err)
+	}
+
+	if err := reopened.SetLearn("u1", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Erase("u1"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(s.config.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "u1") || strings.Contains(string(data), "tabs") {
+		t.Errorf("memory file has erased user: %s", data)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	words := func(s string) int { return len(strings.Fields(s)) }
+	header := words("Facts about the user who started this conversation, remembered at their request. " +
+		"Use them when they are relevant, do not mention them otherwise:")
+
+	tests := []struct {
+		name   string
+		budget int
+		want   []string
+	}{
+		{name: "all facts", budget: 100, want: []string{"Uses Go", "Likes tabs", "Works on a bot"}},
+		{name: "newest facts within budget", budget: header + 9, want: []string{"Likes tabs", "Works on a bot"}},
+		{name: "no facts within budget", budget: header + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t, Config{TokenBudget: tt.budget})
+			if got := s.Prompt("u1", words); got != "" {
+				t.Errorf("Prompt() without facts = %q, want empty", got)
+			}
+			for _, fact := range []string{"Uses Go", "Likes tabs", "Works on a bot"} {
+				if _, err := s.Add("u1", fact, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := s.Prompt("u1", words)
+			if tt.want == nil {
+				if got != "" {
+					t.Errorf("Prompt() = %q, want empty", got)
+				}
+				return
+			}
+			lines := strings.Split(got, "\n")
+			if strings.Join(lines[1:], "|") != "- "+strings.Join(tt.want, "|- ") {
+				t.Errorf("Prompt() = %q, want facts %q", got, tt.want)
+			}
+		})
+	}
+}
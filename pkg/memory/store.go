@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrEmpty    = errors.New("fact is empty")
+	ErrNotFound = errors.New("fact not found")
+)
+
+// Fact is something a user wants the bot to remember about them
+type Fact struct {
+	Text  string    `json:"text"`
+	Added time.Time `json:"added"`
+	// Learned facts were saved by the model during a conversation, others with the command
+	Learned bool `json:"learned,omitempty"`
+}
+
+// User is the memory of a user. Users without facts that did not let the model learn have no memory
+type User struct {
+	Facts []Fact `json:"facts,omitempty"`
+	// Learn lets the model save facts it is told during conversations
+	Learn bool `json:"learn,omitempty"`
+}
+
+func (u *User) clone() User {
+	return User{Facts: slices.Clone(u.Facts), Learn: u.Learn}
+}
+
+// Store keeps memories of all users in memory and persists them to a JSON file on every change.
+// Memories are per user and shared by all servers the user talks to the bot in
+type Store struct {
+	config Config
+
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// New loads memories from the file, returns nil store if memory is disabled. Missing file means
+// no user has memory yet
+func New(config Config) (*Store, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+	s := &Store{config: config.withDefaults(), users: make(map[string]User)}
+	data, err := os.ReadFile(config.File)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.users); err != nil {
+		return nil, fmt.Errorf("%s: %w", config.File, err)
+	}
+	return s, nil
+}
+
+// Get returns memory of the user. Nil store returns empty memory
+func (s *Store) Get(userID string) User {
+	if s == nil {
+		return User{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u := s.users[userID]
+	return u.clone()
+}
+
+// Add remembers the fact, facts that are already remembered are returned as they are
+func (s *Store) Add(userID string, text string, learned bool) (fact Fact, err error) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return Fact{}, ErrEmpty
+	}
+	if n := utf8.RuneCountInString(text); n > s.config.MaxFactLength {
+		return Fact{}, fmt.Errorf("fact is %d characters long, maximum is %d", n, s.config.MaxFactLength)
+	}
+
+	err = s.update(userID, func(u *User) error {
+		if i := slices.IndexFunc(u.Facts, func(f Fact) bool { return strings.EqualFold(f.Text, text) }); i >= 0 {
+			fact = u.Facts[i]
+			return nil
+		}
+		if len(u.Facts) >= s.config.MaxFacts {
+			return fmt.Errorf("%d facts are already remembered, which is the maximum, forget some of them first", len(u.Facts))
+		}
+		fact = Fact{Text: text, Added: time.Now().UTC(), Learned: learned}
+		u.Facts = append(u.Facts, fact)
+		return nil
+	})
+	return
+}
+
+// Forget removes the fact by its number in the list, numbers start from 1
+func (s *Store) Forget(userID string, number int) (fact Fact, err error) {
+	err = s.update(userID, func(u *User) error {
+		if number < 1 || number > len(u.Facts) {
+			return ErrNotFound
+		}
+		fact = u.Facts[number-1]
+		u.Facts = slices.Delete(u.Facts, number-1, number)
+		return nil
+	})
+	return
+}
+
+// Erase removes everything remembered about the user, learning is turned off too
+func (s *Store) Erase(userID string) error {
+	return s.update(userID, func(u *User) error {
+		*u = User{}
+		return nil
+	})
+}
+
+// SetLearn lets the model save facts during conversations with the user, or stops it
+func (s *Store) SetLearn(userID string, learn bool) error {
+	return s.update(userID, func(u *User) error {
+		u.Learn = learn
+		return nil
+	})
+}
+
+// Prompt lists facts of the user for the system message of a conversation, empty if there are none.
+// Facts are kept within the token budget, newer ones take precedence. Tokens are counted with count
+func (s *Store) Prompt(userID string, count func(string) int) string {
+	u := s.Get(userID)
+	if len(u.Facts) == 0 {
+		return ""
+	}
+
+	header := "Facts about the user who started this conversation, remembered at their request. " +
+		"Use them when they are relevant, do not mention them otherwise:"
+	budget := s.config.TokenBudget - count(header)
+	first := len(u.Facts)
+	for first > 0 {
+		tokens := count("- " + u.Facts[first-1].Text)
+		if tokens > budget {
+			break
+		}
+		budget -= tokens
+		first--
+	}
+	if first == len(u.Facts) {
+		return ""
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(header)
+	for _, fact := range u.Facts[first:] {
+		prompt.WriteString("\n- ")
+		prompt.WriteString(fact.Text)
+	}
+	return prompt.String()
+}
+
+// update changes memory of the user with the function and persists it. Nothing is changed if
+// the function or persisting fails
+func (s *Store) update(userID string, update func(u *User) error) error {
+	if s == nil {
+		return errors.New("memory is not available")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.users[userID]
+	u := old.clone()
+	if err := update(&u); err != nil {
+		return err
+	}
+
+	if len(u.Facts) == 0 && !u.Learn {
+		delete(s.users, userID)
+	} else {
+		s.users[userID] = u
+	}
+	if err := s.save(); err != nil {
+		if ok {
+			s.users[userID] = old
+		} else {
+			delete(s.users, userID)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes memories to a temporary file first, so the file is never left half-written
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.users, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.config.File), filepath.Base(s.config.File)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.config.File)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Action string
+
+const (
+	// ActionBlock rejects flagged content
+	ActionBlock Action = "block"
+	// ActionWarn lets flagged content through with a warning
+	ActionWarn Action = "warn"
+	// ActionLog lets flagged content through silently, it is only reported to the moderator log channel
+	ActionLog Action = "log"
+)
+
+const (
+	ProviderOpenAI   = "openai"
+	ProviderLocal    = "local"
+	ProviderDisabled = "disabled"
+)
+
+type Policy struct {
+	// What to do with flagged content. Defaults to block
+	Action Action `yaml:"action"`
+	// Category score thresholds, e.g. `harassment: 0.5`. Content is flagged when any category score
+	// reaches its threshold. Categories without threshold fall back to the provider verdict
+	Thresholds map[string]float64 `yaml:"thresholds"`
+	// Check model outputs in addition to user inputs
+	CheckOutputs bool `yaml:"checkOutputs"`
+	// Channel that receives flagged content with category scores
+	LogChannel string `yaml:"logChannel"`
+	// Block requests when moderation provider is not available
+	FailClosed bool `yaml:"failClosed"`
+}
+
+type Config struct {
+	// Moderation provider: openai (default), local or disabled
+	Provider string `yaml:"provider"`
+	// Local provider keywords and regular expressions grouped by category
+	Keywords map[string][]string `yaml:"keywords"`
+	Patterns map[string][]string `yaml:"patterns"`
+	// Policy applied to guilds without their own policy
+	Default Policy `yaml:"default"`
+	// Per-guild policies, keyed by guild ID. Guild policy replaces default one completely
+	Guilds map[string]Policy `yaml:"guilds"`
+}
+
+func (c *Config) policy(guildID string) Policy {
+	policy, ok := c.Guilds[guildID]
+	if !ok {
+		policy = c.Default
+	}
+	if policy.Action == "" {
+		policy.Action = ActionBlock
+	}
+	return policy
+}
+
+// Validate checks provider, policies and local rules. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Provider {
+	case "", ProviderOpenAI, ProviderLocal, ProviderDisabled:
+	default:
+		errs = append(errs, fmt.Errorf("provider: unknown provider %q, expected one of %s, %s, %s", c.Provider, ProviderOpenAI, ProviderLocal, ProviderDisabled))
+	}
+
+	if _, err := newLocalProvider(c.Keywords, c.Patterns); err != nil {
+		errs = append(errs, fmt.Errorf("patterns: %w", err))
+	}
+
+	errs = append(errs, c.Default.validate("default")...)
+	for guild, policy := range c.Guilds {
+		errs = append(errs, policy.validate("guilds."+guild)...)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Policy) validate(field string) []error {
+	var errs []error
+	switch p.Action {
+	case "", ActionBlock, ActionWarn, ActionLog:
+	default:
+		errs = append(errs, fmt.Errorf("%s.action: unknown action %q, expected one of %s, %s, %s", field, p.Action, ActionBlock, ActionWarn, ActionLog))
+	}
+	for category, threshold := range p.Thresholds {
+		if threshold < 0 || threshold > 1 {
+			errs = append(errs, fmt.Errorf("%s.thresholds.%s: %g is out of range between 0 and 1", field, category, threshold))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,277 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/i18n"
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"github.com/sashabaranov/go-openai"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	reportEmbedColor          = 0xffa500
+	reportDescriptionMaxChars = 4000
+)
+
+type Moderator struct {
+	state atomic.Pointer[moderatorState]
+	// Changes configured policy of the guild, e.g. with guild settings
+	override func(guildID string, policy Policy) Policy
+}
+
+type moderatorState struct {
+	// Nil provider means moderation is disabled
+	provider Provider
+	config   Config
+}
+
+type Verdict struct {
+	Flagged bool
+	Action  Action
+	// Flagged categories, sorted by name
+	Categories []string
+	Scores     map[string]float64
+	// Provider error, only set when policy fails closed
+	Err error
+
+	policy Policy
+}
+
+// Blocked reports whether content must not be processed any further
+func (v *Verdict) Blocked() bool {
+	return v != nil && v.Flagged && v.Action == ActionBlock
+}
+
+// Warned reports whether content is allowed, but the user has to be warned about it
+func (v *Verdict) Warned() bool {
+	return v != nil && v.Flagged && v.Action == ActionWarn
+}
+
+// warningPrefix marks warnings in every language, so they can be told apart from content they are sent with
+const warningPrefix = "⚠️ "
+
+// Reason explains the verdict to the user in their language
+func (v *Verdict) Reason(locale discord.Locale) string {
+	if v.Err != nil {
+		return i18n.T(locale, "moderation.unavailable")
+	}
+	if len(v.Categories) > 0 {
+		return i18n.T(locale, "moderation.categories", strings.Join(v.Categories, ", "))
+	}
+	return i18n.T(locale, "moderation.violation")
+}
+
+// Warning is the reason of the verdict marked as a warning, see IsWarning
+func (v *Verdict) Warning(locale discord.Locale) string {
+	return warningPrefix + v.Reason(locale)
+}
+
+// IsWarning reports whether the text starts with a warning made by Warning, in any language. Content the
+// warning was sent with follows it after an empty line
+func IsWarning(s string) bool {
+	warning, _, _ := strings.Cut(s, "\n\n")
+	reason, ok := strings.CutPrefix(warning, warningPrefix)
+	return ok && (i18n.Matches("moderation.violation", reason) || i18n.Matches("moderation.categories", reason) ||
+		i18n.Matches("moderation.unavailable", reason))
+}
+
+// New creates a moderator for the given config. OpenAI client is only required for the openai provider.
+// All methods of a nil moderator allow everything, same as of the one with disabled provider
+func New(config Config, client *openai.Client) (*Moderator, error) {
+	m := &Moderator{}
+	if err := m.Update(config, client); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Update atomically replaces provider and policies of the moderator. Checks in progress finish with the old ones
+func (m *Moderator) Update(config Config, client *openai.Client) error {
+	state := &moderatorState{config: config}
+	switch config.Provider {
+	case ProviderDisabled:
+	case ProviderLocal:
+		provider, err := newLocalProvider(config.Keywords, config.Patterns)
+		if err != nil {
+			return err
+		}
+		state.provider = provider
+	case "", ProviderOpenAI:
+		if client == nil {
+			return fmt.Errorf("moderation provider %s requires OpenAI client", ProviderOpenAI)
+		}
+		state.provider = &openAIProvider{client: client}
+	default:
+		return fmt.Errorf("unknown moderation provider: %s", config.Provider)
+	}
+	m.state.Store(state)
+	return nil
+}
+
+// SetPolicyOverride sets a function that adjusts configured policies per guild. Must be called before
+// the moderator is used
+func (m *Moderator) SetPolicyOverride(override func(guildID string, policy Policy) Policy) {
+	if m != nil {
+		m.override = override
+	}
+}
+
+func (m *Moderator) policy(state *moderatorState, guildID string) Policy {
+	policy := state.config.policy(guildID)
+	if m.override != nil {
+		policy = m.override(guildID, policy)
+	}
+	return policy
+}
+
+func (m *Moderator) load() *moderatorState {
+	if m == nil {
+		return nil
+	}
+	state := m.state.Load()
+	if state == nil || state.provider == nil {
+		return nil
+	}
+	return state
+}
+
+// CheckInput moderates user-provided content
+func (m *Moderator) CheckInput(ctx context.Context, guildID string, input string) *Verdict {
+	state := m.load()
+	if state == nil {
+		return &Verdict{}
+	}
+	return state.check(ctx, guildID, input, m.policy(state, guildID))
+}
+
+// CheckOutput moderates model-generated content, if policy of the guild asks for it
+func (m *Moderator) CheckOutput(ctx context.Context, guildID string, output string) *Verdict {
+	state := m.load()
+	if state == nil {
+		return &Verdict{}
+	}
+	policy := m.policy(state, guildID)
+	if !policy.CheckOutputs {
+		return &Verdict{}
+	}
+	return state.check(ctx, guildID, output, policy)
+}
+
+func (m *moderatorState) check(ctx context.Context, guildID string, input string, policy Policy) *Verdict {
+	verdict := &Verdict{Action: policy.Action, policy: policy}
+
+	ctx, span := tracing.Start(ctx, "moderation.check", attribute.String("moderation.action", string(policy.Action)))
+	result, err := m.provider.Moderate(ctx, input)
+	tracing.End(span, err)
+	if err != nil {
+		slog.Error("Moderation provider failed", "guild", guildID, "error", err)
+		if policy.FailClosed {
+			verdict.Flagged = true
+			verdict.Action = ActionBlock
+			verdict.Err = err
+		}
+		return verdict
+	}
+
+	verdict.Scores = result.Scores
+	for category, flagged := range result.Categories {
+		threshold, ok := policy.Thresholds[category]
+		if ok {
+			flagged = result.Scores[category] >= threshold
+		}
+		if flagged {
+			verdict.Categories = append(verdict.Categories, category)
+		}
+	}
+	sort.Strings(verdict.Categories)
+	verdict.Flagged = len(verdict.Categories) > 0
+
+	return verdict
+}
+
+type Report struct {
+	// What was moderated, e.g. "Chat prompt" or "Chat completion"
+	Kind      string
+	GuildID   string
+	ChannelID string
+	UserID    string
+	Content   string
+}
+
+// Report sends flagged content with its category scores to the moderator log channel of the guild, if any
+func (m *Moderator) Report(s *discord.Session, verdict *Verdict, report Report) {
+	if m == nil || verdict == nil || !verdict.Flagged || verdict.policy.LogChannel == "" {
+		return
+	}
+
+	content := report.Content
+	if runes := []rune(content); len(runes) > reportDescriptionMaxChars {
+		content = string(runes[:reportDescriptionMaxChars]) + "…"
+	}
+
+	fields := []*discord.MessageEmbedField{
+		{
+			Name:   "User",
+			Value:  fmt.Sprintf("<@%s>", report.UserID),
+			Inline: true,
+		},
+		{
+			Name:   "Channel",
+			Value:  fmt.Sprintf("<#%s>", report.ChannelID),
+			Inline: true,
+		},
+		{
+			Name:   "Action",
+			Value:  string(verdict.Action),
+			Inline: true,
+		},
+	}
+	if verdict.Err != nil {
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  "Provider error",
+			Value: verdict.Err.Error(),
+		})
+	}
+
+	categories := make([]string, 0, len(verdict.Scores))
+	for category := range verdict.Scores {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return verdict.Scores[categories[i]] > verdict.Scores[categories[j]]
+	})
+	var scores strings.Builder
+	for _, category := range categories {
+		marker := ""
+		for _, flagged := range verdict.Categories {
+			if flagged == category {
+				marker = " 🚩"
+				break
+			}
+		}
+		scores.WriteString(fmt.Sprintf("`%s`: %.4f%s\n", category, verdict.Scores[category], marker))
+	}
+	if scores.Len() > 0 {
+		fields = append(fields, &discord.MessageEmbedField{
+			Name:  "Category scores",
+			Value: scores.String(),
+		})
+	}
+
+	_, err := s.ChannelMessageSendEmbed(verdict.policy.LogChannel, &discord.MessageEmbed{
+		Title:       "🚩 Flagged " + strings.ToLower(report.Kind),
+		Description: content,
+		Color:       reportEmbedColor,
+		Fields:      fields,
+	})
+	if err != nil {
+		slog.Error("Failed to send moderation report", "guild", report.GuildID, "channel", verdict.policy.LogChannel, "error", err)
+	}
+}
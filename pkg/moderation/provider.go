@@ -0,0 +1,116 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+type Result struct {
+	// Categories flagged by the provider itself
+	Categories map[string]bool
+	// Score of each category between 0 and 1
+	Scores map[string]float64
+}
+
+type Provider interface {
+	Moderate(ctx context.Context, input string) (*Result, error)
+}
+
+type openAIProvider struct {
+	client *openai.Client
+}
+
+func (p *openAIProvider) Moderate(ctx context.Context, input string) (*Result, error) {
+	resp, err := p.client.Moderations(ctx, openai.ModerationRequest{
+		Input: input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, errors.New("moderation API returned no results")
+	}
+
+	// Categories and scores are fixed structs, their JSON representation gives us
+	// category names as the API defines them
+	result := &Result{}
+	data, err := json.Marshal(resp.Results[0].Categories)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &result.Categories); err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(resp.Results[0].CategoryScores)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &result.Scores); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// localProvider is a keyword/regex stand-in for deployments without access to the Moderations API
+type localProvider struct {
+	keywords map[string][]string
+	patterns map[string][]*regexp.Regexp
+}
+
+func newLocalProvider(keywords map[string][]string, patterns map[string][]string) (*localProvider, error) {
+	p := &localProvider{
+		keywords: make(map[string][]string, len(keywords)),
+		patterns: make(map[string][]*regexp.Regexp, len(patterns)),
+	}
+	for category, words := range keywords {
+		for _, word := range words {
+			p.keywords[category] = append(p.keywords[category], strings.ToLower(word))
+		}
+	}
+	for category, expressions := range patterns {
+		for _, expression := range expressions {
+			re, err := regexp.Compile(expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid moderation pattern %q for category %s: %w", expression, category, err)
+			}
+			p.patterns[category] = append(p.patterns[category], re)
+		}
+	}
+	return p, nil
+}
+
+func (p *localProvider) Moderate(_ context.Context, input string) (*Result, error) {
+	result := &Result{
+		Categories: make(map[string]bool),
+		Scores:     make(map[string]float64),
+	}
+	flag := func(category string) {
+		result.Categories[category] = true
+		result.Scores[category] = 1
+	}
+
+	lowered := strings.ToLower(input)
+	for category, words := range p.keywords {
+		for _, word := range words {
+			if strings.Contains(lowered, word) {
+				flag(category)
+				break
+			}
+		}
+	}
+	for category, expressions := range p.patterns {
+		for _, re := range expressions {
+			if re.MatchString(input) {
+				flag(category)
+				break
+			}
+		}
+	}
+	return result, nil
+}
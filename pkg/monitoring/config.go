@@ -0,0 +1,22 @@
+package monitoring
+
+import (
+	"fmt"
+	"net"
+)
+
+type Config struct {
+	// Address of the HTTP server with /metrics, /healthz and /readyz endpoints, e.g. ":9090". Disabled if empty
+	Listen string `yaml:"listen"`
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("listen: %q is not a valid address: %w", c.Listen, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package monitoring
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "remai"
+
+var (
+	interactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "interactions_total",
+		Help:      "Interactions handled, by command path.",
+	}, []string{"command"})
+	interactionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "interaction_duration_seconds",
+		Help:      "Time spent handling interactions, by command path.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"command"})
+	messageHandlers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "message_handler_invocations_total",
+		Help:      "Message handler invocations, by command path.",
+	}, []string{"command"})
+
+	providerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of requests to the provider, by model.",
+		Buckets:   []float64{.25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+	}, []string{"model"})
+	providerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Failed requests to the provider, by model.",
+	}, []string{"model"})
+	tokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens used by provider requests, by model and type (prompt or completion).",
+	}, []string{"model", "type"})
+	cost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cost_dollars_total",
+		Help:      "Estimated cost of provider requests in US dollars, by model.",
+	}, []string{"model"})
+
+	messagesCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_cache_lookups_total",
+		Help:      "Lookups of chat conversations in the messages cache, by result (hit or miss).",
+	}, []string{"result"})
+	threadReconstructions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "thread_reconstructions_total",
+		Help:      "Chat conversations rebuilt from thread messages after a cache miss, by result (chat, ignored or failed).",
+	}, []string{"result"})
+)
+
+// ObserveProviderRequest records latency of a provider request that started at the time, and its failure
+func ObserveProviderRequest(model string, start time.Time, err error) {
+	providerDuration.WithLabelValues(model).Observe(time.Since(start).Seconds())
+	if err != nil {
+		providerErrors.WithLabelValues(model).Inc()
+	}
+}
+
+// AddUsage records tokens used by a provider request
+func AddUsage(model string, promptTokens int, completionTokens int) {
+	tokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	tokens.WithLabelValues(model, "completion").Add(float64(completionTokens))
+}
+
+// AddCost records cost of a provider request. Requests to models with unknown prices are not counted
+func AddCost(model string, dollars float64) {
+	if dollars > 0 {
+		cost.WithLabelValues(model).Add(dollars)
+	}
+}
+
+// MessagesCacheLookup records whether a conversation was found in the messages cache
+func MessagesCacheLookup(hit bool) {
+	if hit {
+		messagesCache.WithLabelValues("hit").Inc()
+	} else {
+		messagesCache.WithLabelValues("miss").Inc()
+	}
+}
+
+// ThreadReconstructed records the result of rebuilding a conversation from thread messages:
+// "chat" if it was a chat thread, "ignored" if it was not, "failed" if messages could not be fetched
+func ThreadReconstructed(result string) {
+	threadReconstructions.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,27 @@
+package monitoring
+
+import (
+	"strings"
+	"time"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+)
+
+// Middleware counts interactions and measures how long they are handled
+func Middleware() bot.Handler {
+	return bot.HandlerFunc(func(ctx *bot.Context) {
+		command := strings.Join(ctx.Path, " ")
+		start := time.Now()
+		ctx.Next()
+		interactions.WithLabelValues(command).Inc()
+		interactionDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	})
+}
+
+// MessageMiddleware counts message handler invocations
+func MessageMiddleware() bot.MessageHandler {
+	return bot.MessageHandlerFunc(func(ctx *bot.MessageContext) {
+		messageHandlers.WithLabelValues(strings.Join(ctx.Path, " ")).Inc()
+		ctx.Next()
+	})
+}
@@ -0,0 +1,117 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+)
+
+// Discord asks for a heartbeat every ~41 seconds, missing a few acks means the gateway connection is gone
+const maxHeartbeatAge = 2 * time.Minute
+
+// Server serves metrics, liveness and readiness of the bot over HTTP
+type Server struct {
+	http   *http.Server
+	shards []*discord.Session
+	router *bot.Router
+}
+
+// NewServer returns nil if the server is disabled in the config. Methods of nil server do nothing
+func NewServer(config Config, shards []*discord.Session, router *bot.Router) *Server {
+	if config.Listen == "" {
+		return nil
+	}
+
+	s := &Server{shards: shards, router: router}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	s.http = &http.Server{
+		Addr:              config.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Start listens on the configured address and serves requests in the background
+func (s *Server) Start() error {
+	if s == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("Monitoring server started", "address", listener.Addr().String())
+	go func() {
+		if err := s.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Monitoring server failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the server, waiting for active requests until the context is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	return s.http.Shutdown(ctx)
+}
+
+type healthStatus struct {
+	Connected bool          `json:"connected"`
+	Shards    []shardStatus `json:"shards"`
+}
+
+type shardStatus struct {
+	ID            int        `json:"id"`
+	Connected     bool       `json:"connected"`
+	LastHeartbeat *time.Time `json:"lastHeartbeat,omitempty"`
+}
+
+// healthz reports whether gateway connections of all shards are alive
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{Connected: true, Shards: make([]shardStatus, 0, len(s.shards))}
+	for _, shard := range s.shards {
+		shard.RLock()
+		ready, ack := shard.DataReady, shard.LastHeartbeatAck
+		shard.RUnlock()
+
+		shardStatus := shardStatus{ID: shard.ShardID, Connected: ready && !ack.IsZero() && time.Since(ack) < maxHeartbeatAge}
+		if !ack.IsZero() {
+			shardStatus.LastHeartbeat = &ack
+		}
+		status.Connected = status.Connected && shardStatus.Connected
+		status.Shards = append(status.Shards, shardStatus)
+	}
+	writeStatus(w, status.Connected, status)
+}
+
+type readyStatus struct {
+	CommandsSynced bool `json:"commandsSynced"`
+}
+
+// readyz reports whether commands are synced with Discord, so the bot can handle interactions
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	status := readyStatus{CommandsSynced: s.router.Synced()}
+	writeStatus(w, status.CommandsSynced, status)
+}
+
+func writeStatus(w http.ResponseWriter, ok bool, status any) {
+	w.Header().Set("Content-Type", "application/json")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
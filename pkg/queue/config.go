@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Config struct {
+	// Requests to providers that run at once, others wait in the queue. Queue is disabled if 0
+	Workers int `yaml:"workers"`
+	// Chat requests with at most this many prompt tokens are short, they skip ahead of long ones
+	ShortRequestTokens int `yaml:"shortRequestTokens"`
+}
+
+const defaultShortRequestTokens = 1000
+
+// ShortRequest reports whether a chat request with the number of prompt tokens is short
+func (c *Config) ShortRequest(tokens int) bool {
+	limit := c.ShortRequestTokens
+	if limit == 0 {
+		limit = defaultShortRequestTokens
+	}
+	return tokens <= limit
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Workers < 0 {
+		errs = append(errs, fmt.Errorf("workers: %d must not be negative", c.Workers))
+	}
+	if c.ShortRequestTokens < 0 {
+		errs = append(errs, fmt.Errorf("shortRequestTokens: %d must not be negative", c.ShortRequestTokens))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Short requests skip ahead of long ones at most this many times in a row, so long ones are not starved
+const maxShortStreak = 3
+
+// Queue runs requests to providers with a limited number of workers. Users take turns, so a single user
+// cannot hold the queue, and short requests of a user go before long ones of the others.
+// All methods of a nil queue run requests right away
+type Queue struct {
+	mu     sync.Mutex
+	config Config
+
+	running int
+	// Users with waiting requests in round-robin order, the next turn is of the first one
+	users []string
+	// Waiting requests of every user in submission order
+	waiting     map[string][]*job
+	shortStreak int
+}
+
+// Request describes who sends the request, and how the caller wants to know its position
+type Request struct {
+	UserID string
+	Short  bool
+	// OnPosition is called with 1-based position in the queue every time it changes while the request waits,
+	// and with 0 once the request starts if it had to wait. Called from the goroutine of Do
+	OnPosition func(position int)
+}
+
+type job struct {
+	Request
+	start    chan struct{}
+	position chan int
+	// Last position sent to the caller
+	sent int
+}
+
+func New(config Config) *Queue {
+	return &Queue{
+		config:  config,
+		waiting: make(map[string][]*job),
+	}
+}
+
+// Update changes the config, waiting requests start right away if there are more workers now
+func (q *Queue) Update(config Config) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	q.config = config
+	q.dispatch()
+	q.mu.Unlock()
+}
+
+// ShortRequest reports whether a chat request with the number of prompt tokens is short
+func (q *Queue) ShortRequest(tokens int) bool {
+	if q == nil {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.config.ShortRequest(tokens)
+}
+
+// Do waits for a turn of the request and runs it. Waiting stops when context is done
+func (q *Queue) Do(ctx context.Context, request Request, run func() error) error {
+	if q == nil {
+		return run()
+	}
+
+	_, span := tracing.Start(ctx, "queue.wait", attribute.Bool("queue.short", request.Short))
+	j := &job{Request: request, start: make(chan struct{}), position: make(chan int, 1)}
+	q.mu.Lock()
+	q.push(j)
+	q.dispatch()
+	q.mu.Unlock()
+
+	waited := false
+wait:
+	for {
+		select {
+		case <-j.start:
+			break wait
+		case position := <-j.position:
+			waited = true
+			if request.OnPosition != nil {
+				request.OnPosition(position)
+			}
+		case <-ctx.Done():
+			q.mu.Lock()
+			removed := q.remove(j)
+			q.updatePositions()
+			q.mu.Unlock()
+			if removed {
+				tracing.End(span, ctx.Err())
+				return ctx.Err()
+			}
+			// the request was started at the same time
+			<-j.start
+			break wait
+		}
+	}
+	span.SetAttributes(attribute.Bool("queue.waited", waited))
+	span.End()
+	if waited && request.OnPosition != nil {
+		request.OnPosition(0)
+	}
+
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		q.dispatch()
+		q.mu.Unlock()
+	}()
+
+	return run()
+}
+
+func (q *Queue) push(j *job) {
+	if len(q.waiting[j.UserID]) == 0 {
+		q.users = append(q.users, j.UserID)
+	}
+	q.waiting[j.UserID] = append(q.waiting[j.UserID], j)
+}
+
+func (q *Queue) remove(j *job) bool {
+	jobs := q.waiting[j.UserID]
+	i := slices.Index(jobs, j)
+	if i < 0 {
+		return false
+	}
+	jobs = slices.Delete(jobs, i, i+1)
+	if len(jobs) == 0 {
+		delete(q.waiting, j.UserID)
+		q.users = slices.DeleteFunc(q.users, func(user string) bool { return user == j.UserID })
+	} else {
+		q.waiting[j.UserID] = jobs
+	}
+	return true
+}
+
+// dispatch starts waiting requests while there are free workers, and tells the rest their positions
+func (q *Queue) dispatch() {
+	for q.config.Workers <= 0 || q.running < q.config.Workers {
+		j := q.next(q.users, q.waiting, &q.shortStreak)
+		if j == nil {
+			break
+		}
+		q.remove(j)
+		// the user goes to the end of the line
+		if len(q.waiting[j.UserID]) > 0 {
+			q.users = append(slices.DeleteFunc(q.users, func(user string) bool { return user == j.UserID }), j.UserID)
+		}
+		q.running++
+		close(j.start)
+	}
+	q.updatePositions()
+}
+
+// next picks the request of the user whose turn it is. A user with a short request goes first,
+// unless short requests skipped ahead too many times in a row
+func (q *Queue) next(users []string, waiting map[string][]*job, shortStreak *int) *job {
+	if len(users) == 0 {
+		return nil
+	}
+	if *shortStreak < maxShortStreak {
+		for _, user := range users {
+			if j := waiting[user][0]; j.Short {
+				if user != users[0] {
+					*shortStreak++
+				}
+				return j
+			}
+		}
+	}
+	*shortStreak = 0
+	return waiting[users[0]][0]
+}
+
+// updatePositions simulates the order of waiting requests and sends their positions
+func (q *Queue) updatePositions() {
+	users := slices.Clone(q.users)
+	waiting := make(map[string][]*job, len(q.waiting))
+	for user, jobs := range q.waiting {
+		waiting[user] = slices.Clone(jobs)
+	}
+	shortStreak := q.shortStreak
+
+	for position := 1; ; position++ {
+		j := q.next(users, waiting, &shortStreak)
+		if j == nil {
+			return
+		}
+		if j.sent != position {
+			// latest position replaces the one that was not received yet
+			select {
+			case <-j.position:
+			default:
+			}
+			j.position <- position
+			j.sent = position
+		}
+
+		waiting[j.UserID] = waiting[j.UserID][1:]
+		users = slices.DeleteFunc(users, func(user string) bool { return user == j.UserID })
+		if len(waiting[j.UserID]) > 0 {
+			users = append(users, j.UserID)
+		} else {
+			delete(waiting, j.UserID)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: up to Requests requests at once, refilled evenly over Period
+type Limit struct {
+	Requests int           `yaml:"requests"`
+	Period   time.Duration `yaml:"period"`
+}
+
+type Config struct {
+	// Requests to providers in progress for all users, unlimited if 0
+	MaxConcurrent int `yaml:"maxConcurrent"`
+	// Requests to providers in progress for a single user, unlimited if 0
+	MaxConcurrentPerUser int `yaml:"maxConcurrentPerUser"`
+	// Limits per user, guild and command, keyed by command path, e.g. "chat gpt" or "image".
+	// Limit of a command applies to its subcommands without their own limit
+	Commands map[string]Limit `yaml:"commands"`
+}
+
+// limit returns the limit of the longest configured command path that matches
+func (c *Config) limit(path []string) (string, Limit, bool) {
+	for i := len(path); i > 0; i-- {
+		command := strings.Join(path[:i], " ")
+		if limit, ok := c.Commands[command]; ok {
+			return command, limit, true
+		}
+	}
+	return "", Limit{}, false
+}
+
+// Validate checks limits. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	if c.MaxConcurrent < 0 {
+		errs = append(errs, fmt.Errorf("maxConcurrent: %d must not be negative", c.MaxConcurrent))
+	}
+	if c.MaxConcurrentPerUser < 0 {
+		errs = append(errs, fmt.Errorf("maxConcurrentPerUser: %d must not be negative", c.MaxConcurrentPerUser))
+	}
+
+	commands := make([]string, 0, len(c.Commands))
+	for command := range c.Commands {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		limit := c.Commands[command]
+		if strings.TrimSpace(command) == "" {
+			errs = append(errs, errors.New("commands: command path is required"))
+		}
+		if limit.Requests <= 0 {
+			errs = append(errs, fmt.Errorf("commands.%s.requests: %d must be positive", command, limit.Requests))
+		}
+		if limit.Period <= 0 {
+			errs = append(errs, fmt.Errorf("commands.%s.period: %s must be positive", command, limit.Period))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets are swept once there are this many of them, full ones are dropped
+const bucketsSweepThreshold = 10000
+
+var (
+	errBusy     = errors.New("too many requests in progress")
+	errUserBusy = errors.New("too many requests of the user in progress")
+)
+
+// Limiter limits how often users can use commands, and how many requests are in progress at once.
+// All methods of a nil limiter allow everything
+type Limiter struct {
+	mu           sync.Mutex
+	config       Config
+	buckets      map[string]*bucket
+	inFlight     int
+	userInFlight map[string]int
+
+	now func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func New(config Config) *Limiter {
+	return &Limiter{
+		config:       config,
+		buckets:      make(map[string]*bucket),
+		userInFlight: make(map[string]int),
+		now:          time.Now,
+	}
+}
+
+// Update replaces limits. Buckets start over, requests in progress keep counting
+func (l *Limiter) Update(config Config) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.buckets = make(map[string]*bucket)
+}
+
+// take takes a token from the bucket of the user, guild and command. If the bucket is empty,
+// returns how long to wait for the next token
+func (l *Limiter) take(userID string, guildID string, path []string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	command, limit, ok := l.config.limit(path)
+	if !ok {
+		return 0
+	}
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+	now := l.now()
+
+	if len(l.buckets) >= bucketsSweepThreshold {
+		l.sweep(now)
+	}
+
+	key := strings.Join([]string{userID, guildID, command}, "/")
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(limit.Requests), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// sweep drops buckets that are full again, they are the same as new ones
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		command := key[strings.LastIndex(key, "/")+1:]
+		limit := l.config.Commands[command]
+		rate := float64(limit.Requests) / limit.Period.Seconds()
+		if b.tokens+now.Sub(b.updated).Seconds()*rate >= float64(limit.Requests) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// acquire counts a request of the user as in progress, unless caps are reached
+func (l *Limiter) acquire(userID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.MaxConcurrent > 0 && l.inFlight >= l.config.MaxConcurrent {
+		return errBusy
+	}
+	if l.config.MaxConcurrentPerUser > 0 && l.userInFlight[userID] >= l.config.MaxConcurrentPerUser {
+		return errUserBusy
+	}
+	l.inFlight++
+	l.userInFlight[userID]++
+	return nil
+}
+
+func (l *Limiter) release(userID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	if l.userInFlight[userID]--; l.userInFlight[userID] <= 0 {
+		delete(l.userInFlight, userID)
+	}
+}
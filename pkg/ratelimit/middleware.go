@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+)
+
+// HandleCommand makes limiter a command middleware. Interactions over limits are responded
+// with an ephemeral message that tells when to try again
+func (l *Limiter) HandleCommand(ctx *bot.Context) {
+	if l == nil {
+		ctx.Next()
+		return
+	}
+
+	user := ctx.Interaction.User
+	if ctx.Interaction.Member != nil {
+		user = ctx.Interaction.Member.User
+	}
+
+	reason := l.check(user.ID, ctx.Interaction.GuildID, ctx.Path)
+	if reason != "" {
+		ctx.Logger.Info("Interaction was rate limited", "reason", reason)
+		err := ctx.Respond(&discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Flags: discord.MessageFlagsEphemeral,
+				Embeds: []*discord.MessageEmbed{
+					{
+						Title:       "⏳ Slow down",
+						Description: reason,
+						Color:       0xff0000,
+					},
+				},
+			},
+		})
+		if err != nil {
+			ctx.Logger.Error("Failed to respond to interaction", "error", err)
+		}
+		return
+	}
+	defer l.release(user.ID)
+
+	ctx.Next()
+}
+
+// HandleMessageCommand makes limiter a message middleware. Only messages in threads started by the bot
+// are limited, as message handlers ignore the rest anyway. Messages over limits are replied with
+// a message that tells when to try again
+func (l *Limiter) HandleMessageCommand(ctx *bot.MessageContext) {
+	if l == nil || ctx.Message.Author == nil || ctx.Message.Author.Bot || !isBotThread(ctx) {
+		ctx.Next()
+		return
+	}
+
+	reason := l.check(ctx.Message.Author.ID, ctx.Message.GuildID, ctx.Path)
+	if reason != "" {
+		ctx.Logger.Info("Message was rate limited", "reason", reason)
+		_, err := ctx.Reply("⏳ " + reason)
+		if err != nil {
+			ctx.Logger.Error("Failed to reply", "error", err)
+		}
+		return
+	}
+	defer l.release(ctx.Message.Author.ID)
+
+	ctx.Next()
+}
+
+// check acquires a request in progress and takes a token. Returns an explanation for the user if
+// the request is over limits, nothing is acquired then
+func (l *Limiter) check(userID string, guildID string, path []string) string {
+	if err := l.acquire(userID); err != nil {
+		if errors.Is(err, errUserBusy) {
+			return "You have too many requests in progress, please wait for them to finish"
+		}
+		return "The bot is busy with other requests, please try again in a moment"
+	}
+
+	if retryAfter := l.take(userID, guildID, path); retryAfter > 0 {
+		l.release(userID)
+		retryAt := l.now().Add(retryAfter).Add(time.Second - 1).Truncate(time.Second)
+		return fmt.Sprintf("You are using `/%s` too often, please try again <t:%d:R>", strings.Join(path, " "), retryAt.Unix())
+	}
+	return ""
+}
+
+func isBotThread(ctx *bot.MessageContext) bool {
+	ch, err := ctx.Session.State.Channel(ctx.Message.ChannelID)
+	return err == nil && ch.IsThread() && ch.OwnerID == ctx.Session.State.User.ID
+}
@@ -0,0 +1,115 @@
+package rules
+
+import (
+	"log/slog"
+
+	discord "github.com/bwmarrin/discordgo"
+	"github.com/raikerian/go-remai-bot-discord/pkg/bot"
+)
+
+// Source returns rules of the guild
+type Source func(guildID string) []Rule
+
+// Middleware stops interactions that rules of the guild do not allow, and explains why with an ephemeral message
+func Middleware(source Source) bot.Handler {
+	return bot.HandlerFunc(func(ctx *bot.Context) {
+		if ctx.Interaction.GuildID == "" || ctx.Interaction.Member == nil {
+			// rules are per guild, DMs are not restricted
+			ctx.Next()
+			return
+		}
+
+		rules := applicable(source(ctx.Interaction.GuildID), ctx.Path)
+		if len(rules) == 0 {
+			ctx.Next()
+			return
+		}
+
+		subject := newSubject(ctx.Session, ctx.Interaction.ChannelID, ctx.Interaction.Member.User.ID, ctx.Interaction.Member.Roles)
+		reason := Check(rules, ctx.Path, subject)
+		if reason == "" {
+			ctx.Next()
+			return
+		}
+
+		ctx.Logger.Info("Interaction is not allowed by guild rules")
+		err := ctx.Respond(&discord.InteractionResponse{
+			Type: discord.InteractionResponseChannelMessageWithSource,
+			Data: &discord.InteractionResponseData{
+				Flags: discord.MessageFlagsEphemeral,
+				Embeds: []*discord.MessageEmbed{
+					{
+						Title:       "❌ Error",
+						Description: reason,
+						Color:       0xff0000,
+					},
+				},
+			},
+		})
+		if err != nil {
+			ctx.Logger.Error("Failed to respond to interaction", "error", err)
+		}
+	})
+}
+
+// MessageMiddleware silently ignores messages that rules of the guild do not allow, e.g. in threads
+// of a command that is not allowed in the parent channel anymore
+func MessageMiddleware(source Source) bot.MessageHandler {
+	return bot.MessageHandlerFunc(func(ctx *bot.MessageContext) {
+		if ctx.Message.GuildID == "" || ctx.Message.Author == nil {
+			ctx.Next()
+			return
+		}
+
+		rules := applicable(source(ctx.Message.GuildID), ctx.Path)
+		if len(rules) == 0 {
+			ctx.Next()
+			return
+		}
+
+		var roles []string
+		if ctx.Message.Member != nil {
+			roles = ctx.Message.Member.Roles
+		}
+		subject := newSubject(ctx.Session, ctx.Message.ChannelID, ctx.Message.Author.ID, roles)
+		if reason := Check(rules, ctx.Path, subject); reason != "" {
+			return
+		}
+
+		ctx.Next()
+	})
+}
+
+// applicable filters rules of the command, so channels are only resolved when there is something to check
+func applicable(rules []Rule, path []string) (list []Rule) {
+	for _, rule := range rules {
+		if rule.Applies(path) {
+			list = append(list, rule)
+		}
+	}
+	return
+}
+
+// newSubject resolves the parent channel and category, so they can be restricted too
+func newSubject(s *discord.Session, channelID string, userID string, roles []string) *Subject {
+	subject := &Subject{
+		UserID:     userID,
+		Roles:      roles,
+		ChannelIDs: []string{channelID},
+	}
+	for id := channelID; id != ""; {
+		ch, err := s.State.Channel(id)
+		if err != nil {
+			ch, err = s.Channel(id)
+			if err != nil {
+				slog.Error("Failed to get channel info", "channel", id, "error", err)
+				break
+			}
+		}
+		id = ch.ParentID
+		if id != "" {
+			subject.ChannelIDs = append(subject.ChannelIDs, id)
+		}
+	}
+	return subject
+}
@@ -0,0 +1,123 @@
+package rules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Rule restricts a command and all of its subcommands. Empty allowlists allow everything
+type Rule struct {
+	// Command path, e.g. "chat" or "image dalle"
+	Command string `json:"command"`
+	// Channels or categories where the command can be used
+	AllowChannels []string `json:"allowChannels,omitempty"`
+	// Channels or categories where the command cannot be used
+	DenyChannels []string `json:"denyChannels,omitempty"`
+	// Roles that can use the command, member needs any of them
+	AllowRoles []string `json:"allowRoles,omitempty"`
+	// Roles that cannot use the command
+	DenyRoles []string `json:"denyRoles,omitempty"`
+	// Users that cannot use the command
+	DenyUsers []string `json:"denyUsers,omitempty"`
+}
+
+// Subject describes who uses the command and where
+type Subject struct {
+	UserID string
+	Roles  []string
+	// Channel of the interaction or message, thread parent channel and category of the channel,
+	// any of them can be restricted
+	ChannelIDs []string
+}
+
+// Applies reports whether the rule restricts the command path or its parent command
+func (r *Rule) Applies(path []string) bool {
+	command := strings.Fields(r.Command)
+	return len(command) <= len(path) && slices.Equal(command, path[:len(command)])
+}
+
+// Check returns an explanation why the subject cannot use the command, or empty string if it can
+func (r *Rule) Check(subject *Subject) string {
+	command := "/" + r.Command
+	switch {
+	case slices.Contains(r.DenyUsers, subject.UserID):
+		return fmt.Sprintf("You are not allowed to use `%s`", command)
+	case containsAny(r.DenyChannels, subject.ChannelIDs):
+		return fmt.Sprintf("`%s` cannot be used in this channel", command)
+	case len(r.AllowChannels) > 0 && !containsAny(r.AllowChannels, subject.ChannelIDs):
+		return fmt.Sprintf("`%s` can only be used in %s", command, mentions("<#", r.AllowChannels))
+	case containsAny(r.DenyRoles, subject.Roles):
+		return fmt.Sprintf("`%s` cannot be used with your roles", command)
+	case len(r.AllowRoles) > 0 && !containsAny(r.AllowRoles, subject.Roles):
+		return fmt.Sprintf("`%s` can only be used by %s", command, mentions("<@&", r.AllowRoles))
+	}
+	return ""
+}
+
+// IsEmpty reports whether the rule restricts nothing
+func (r *Rule) IsEmpty() bool {
+	return len(r.AllowChannels) == 0 && len(r.DenyChannels) == 0 && len(r.AllowRoles) == 0 &&
+		len(r.DenyRoles) == 0 && len(r.DenyUsers) == 0
+}
+
+// String describes the rule with Discord mentions
+func (r *Rule) String() string {
+	var lines []string
+	if len(r.AllowChannels) > 0 {
+		lines = append(lines, "Allowed channels: "+mentions("<#", r.AllowChannels))
+	}
+	if len(r.DenyChannels) > 0 {
+		lines = append(lines, "Denied channels: "+mentions("<#", r.DenyChannels))
+	}
+	if len(r.AllowRoles) > 0 {
+		lines = append(lines, "Allowed roles: "+mentions("<@&", r.AllowRoles))
+	}
+	if len(r.DenyRoles) > 0 {
+		lines = append(lines, "Denied roles: "+mentions("<@&", r.DenyRoles))
+	}
+	if len(r.DenyUsers) > 0 {
+		lines = append(lines, "Denied users: "+mentions("<@", r.DenyUsers))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r Rule) Clone() Rule {
+	r.AllowChannels = slices.Clone(r.AllowChannels)
+	r.DenyChannels = slices.Clone(r.DenyChannels)
+	r.AllowRoles = slices.Clone(r.AllowRoles)
+	r.DenyRoles = slices.Clone(r.DenyRoles)
+	r.DenyUsers = slices.Clone(r.DenyUsers)
+	return r
+}
+
+// Check returns an explanation why the subject cannot use the command at the path, or empty string if it can.
+// All rules of the command and its parent commands must pass
+func Check(rules []Rule, path []string, subject *Subject) string {
+	for i := range rules {
+		if !rules[i].Applies(path) {
+			continue
+		}
+		if reason := rules[i].Check(subject); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+func containsAny(list []string, values []string) bool {
+	for _, value := range values {
+		if value != "" && slices.Contains(list, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func mentions(prefix string, ids []string) string {
+	list := make([]string, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, prefix+id+">")
+	}
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,239 @@
+package settings
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/config"
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+)
+
+// Key is a single guild setting that can be read, changed and reset by guild admins
+type Key struct {
+	Name        string
+	Description string
+
+	get   func(g *Guild) string
+	set   func(g *Guild, value string, completionModels []string) error
+	reset func(g *Guild)
+}
+
+// Get formats value of the setting, empty string means it is not set
+func (k *Key) Get(g *Guild) string {
+	return k.get(g)
+}
+
+// Set parses and validates the value. Completion models are the configured ones, model settings must be their subset
+func (k *Key) Set(g *Guild, value string, completionModels []string) error {
+	return k.set(g, strings.TrimSpace(value), completionModels)
+}
+
+func (k *Key) Reset(g *Guild) {
+	k.reset(g)
+}
+
+var Keys = []*Key{
+	{
+		Name:        "default-model",
+		Description: "Chat model used when none is specified",
+		get:         func(g *Guild) string { return g.DefaultModel },
+		set: func(g *Guild, value string, completionModels []string) error {
+			if err := validateModels([]string{value}, g.Models(completionModels)); err != nil {
+				return err
+			}
+			g.DefaultModel = value
+			return nil
+		},
+		reset: func(g *Guild) { g.DefaultModel = "" },
+	},
+	{
+		Name:        "allowed-models",
+		Description: "Comma-separated chat models allowed in the server",
+		get:         func(g *Guild) string { return strings.Join(g.AllowedModels, ", ") },
+		set: func(g *Guild, value string, completionModels []string) error {
+			models := splitList(value)
+			if len(models) == 0 {
+				return fmt.Errorf("at least one model is required")
+			}
+			if err := validateModels(models, completionModels); err != nil {
+				return err
+			}
+			g.AllowedModels = models
+			return nil
+		},
+		reset: func(g *Guild) { g.AllowedModels = nil },
+	},
+	{
+		Name:        "chat-channels",
+		Description: "Comma-separated channels where chat conversations can be started",
+		get: func(g *Guild) string {
+			channels := make([]string, 0, len(g.ChatChannels))
+			for _, channel := range g.ChatChannels {
+				channels = append(channels, "<#"+channel+">")
+			}
+			return strings.Join(channels, ", ")
+		},
+		set: func(g *Guild, value string, _ []string) error {
+			var channels []string
+			for _, channel := range splitList(value) {
+				id, err := parseChannel(channel)
+				if err != nil {
+					return err
+				}
+				if !slices.Contains(channels, id) {
+					channels = append(channels, id)
+				}
+			}
+			if len(channels) == 0 {
+				return fmt.Errorf("at least one channel is required")
+			}
+			g.ChatChannels = channels
+			return nil
+		},
+		reset: func(g *Guild) { g.ChatChannels = nil },
+	},
+	{
+		Name:        "thread-archive",
+		Description: "Minutes of inactivity before chat threads are archived: 60, 1440, 4320 or 10080",
+		get:         func(g *Guild) string { return formatInt(g.ThreadAutoArchiveDuration) },
+		set: func(g *Guild, value string, _ []string) error {
+			minutes, err := strconv.Atoi(value)
+			if err != nil || !slices.Contains(threadAutoArchiveDurations, minutes) {
+				return fmt.Errorf("%q is not one of 60, 1440, 4320 or 10080 minutes", value)
+			}
+			g.ThreadAutoArchiveDuration = minutes
+			return nil
+		},
+		reset: func(g *Guild) { g.ThreadAutoArchiveDuration = 0 },
+	},
+	{
+		Name:        "images-enabled",
+		Description: "Whether image commands can be used: true or false",
+		get: func(g *Guild) string {
+			if !g.ImagesDisabled {
+				return ""
+			}
+			return "false"
+		},
+		set: func(g *Guild, value string, _ []string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%q is not true or false", value)
+			}
+			g.ImagesDisabled = !enabled
+			return nil
+		},
+		reset: func(g *Guild) { g.ImagesDisabled = false },
+	},
+	{
+		Name:        "moderation-action",
+		Description: "What to do with flagged content: block, warn or log",
+		get:         func(g *Guild) string { return string(g.Moderation.Action) },
+		set: func(g *Guild, value string, _ []string) error {
+			action := moderation.Action(strings.ToLower(value))
+			switch action {
+			case moderation.ActionBlock, moderation.ActionWarn, moderation.ActionLog:
+			default:
+				return fmt.Errorf("%q is not one of block, warn or log", value)
+			}
+			g.Moderation.Action = action
+			return nil
+		},
+		reset: func(g *Guild) { g.Moderation.Action = "" },
+	},
+	{
+		Name:        "moderation-check-outputs",
+		Description: "Whether model outputs are moderated too: true or false",
+		get:         func(g *Guild) string { return formatBool(g.Moderation.CheckOutputs) },
+		set: func(g *Guild, value string, _ []string) (err error) {
+			g.Moderation.CheckOutputs, err = parseBool(value)
+			return
+		},
+		reset: func(g *Guild) { g.Moderation.CheckOutputs = nil },
+	},
+	{
+		Name:        "moderation-log-channel",
+		Description: "Channel that receives flagged content",
+		get: func(g *Guild) string {
+			if g.Moderation.LogChannel == "" {
+				return ""
+			}
+			return "<#" + g.Moderation.LogChannel + ">"
+		},
+		set: func(g *Guild, value string, _ []string) (err error) {
+			g.Moderation.LogChannel, err = parseChannel(value)
+			return
+		},
+		reset: func(g *Guild) { g.Moderation.LogChannel = "" },
+	},
+	{
+		Name:        "moderation-fail-closed",
+		Description: "Whether requests are blocked when moderation is unavailable: true or false",
+		get:         func(g *Guild) string { return formatBool(g.Moderation.FailClosed) },
+		set: func(g *Guild, value string, _ []string) (err error) {
+			g.Moderation.FailClosed, err = parseBool(value)
+			return
+		},
+		reset: func(g *Guild) { g.Moderation.FailClosed = nil },
+	},
+}
+
+func FindKey(name string) *Key {
+	for _, key := range Keys {
+		if key.Name == name {
+			return key
+		}
+	}
+	return nil
+}
+
+func validateModels(models []string, allowed []string) error {
+	for _, model := range models {
+		if !slices.Contains(allowed, model) {
+			return fmt.Errorf("model `%s` is not available. Available models: `%s`", model, strings.Join(allowed, "`, `"))
+		}
+	}
+	return nil
+}
+
+func splitList(value string) (list []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return
+}
+
+// parseChannel accepts channel mentions, e.g. <#123>, as well as plain IDs
+func parseChannel(value string) (string, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(value, "<#"), ">")
+	if !config.IsSnowflake(id) {
+		return "", fmt.Errorf("%q is not a channel", value)
+	}
+	return id, nil
+}
+
+func parseBool(value string) (*bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not true or false", value)
+	}
+	return &b, nil
+}
+
+func formatBool(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+func formatInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
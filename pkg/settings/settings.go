@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"slices"
+
+	"github.com/raikerian/go-remai-bot-discord/pkg/moderation"
+	"github.com/raikerian/go-remai-bot-discord/pkg/rules"
+)
+
+// Discord expects the auto_archive_duration to be one of the following values: 60, 1440, 4320, or 10080,
+// which represent the number of minutes before a thread is automatically archived
+// (1 hour, 1 day, 3 days, or 7 days, respectively).
+var threadAutoArchiveDurations = []int{60, 1440, 4320, 10080}
+
+const DefaultThreadAutoArchiveDuration = 60
+
+// Guild holds settings of a single guild. Zero values mean "not set", global configuration applies then
+type Guild struct {
+	// Chat model used when none is specified. Must be one of the allowed models
+	DefaultModel string `json:"defaultModel,omitempty"`
+	// Chat models allowed in the guild, subset of configured completion models
+	AllowedModels []string `json:"allowedModels,omitempty"`
+	// Channels where chat conversations can be started, any channel if empty
+	ChatChannels []string `json:"chatChannels,omitempty"`
+	// Minutes of inactivity before chat threads are archived
+	ThreadAutoArchiveDuration int `json:"threadAutoArchiveDuration,omitempty"`
+	// Image commands are enabled by default
+	ImagesDisabled bool `json:"imagesDisabled,omitempty"`
+	// Overrides of the moderation policy configured for the guild
+	Moderation ModerationPolicy `json:"moderation,omitempty"`
+	// Channel and role restrictions of commands, managed with /admin rules
+	Rules []rules.Rule `json:"rules,omitempty"`
+}
+
+type ModerationPolicy struct {
+	Action       moderation.Action `json:"action,omitempty"`
+	CheckOutputs *bool             `json:"checkOutputs,omitempty"`
+	LogChannel   string            `json:"logChannel,omitempty"`
+	FailClosed   *bool             `json:"failClosed,omitempty"`
+}
+
+// Models returns configured completion models allowed in the guild, in the configured order
+func (g *Guild) Models(completionModels []string) []string {
+	if len(g.AllowedModels) == 0 {
+		return completionModels
+	}
+	var models []string
+	for _, model := range completionModels {
+		if slices.Contains(g.AllowedModels, model) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// Model returns default chat model of the guild. First allowed model is used if guild has no default one,
+// and fallback if there are no configured models at all
+func (g *Guild) Model(completionModels []string, fallback string) string {
+	models := g.Models(completionModels)
+	if g.DefaultModel != "" && slices.Contains(models, g.DefaultModel) {
+		return g.DefaultModel
+	}
+	if len(models) > 0 {
+		return models[0]
+	}
+	return fallback
+}
+
+// ChatAllowedIn reports whether chat conversations can be started in the channel
+func (g *Guild) ChatAllowedIn(channelID string) bool {
+	return len(g.ChatChannels) == 0 || slices.Contains(g.ChatChannels, channelID)
+}
+
+func (g *Guild) ThreadArchiveDuration() int {
+	if g.ThreadAutoArchiveDuration == 0 {
+		return DefaultThreadAutoArchiveDuration
+	}
+	return g.ThreadAutoArchiveDuration
+}
+
+func (g *Guild) ImagesEnabled() bool {
+	return !g.ImagesDisabled
+}
+
+// ApplyModeration overrides fields of the configured moderation policy that are set for the guild
+func (g *Guild) ApplyModeration(policy moderation.Policy) moderation.Policy {
+	if g.Moderation.Action != "" {
+		policy.Action = g.Moderation.Action
+	}
+	if g.Moderation.CheckOutputs != nil {
+		policy.CheckOutputs = *g.Moderation.CheckOutputs
+	}
+	if g.Moderation.LogChannel != "" {
+		policy.LogChannel = g.Moderation.LogChannel
+	}
+	if g.Moderation.FailClosed != nil {
+		policy.FailClosed = *g.Moderation.FailClosed
+	}
+	return policy
+}
+
+func (g Guild) clone() Guild {
+	g.AllowedModels = slices.Clone(g.AllowedModels)
+	g.ChatChannels = slices.Clone(g.ChatChannels)
+	if g.Rules != nil {
+		list := make([]rules.Rule, 0, len(g.Rules))
+		for _, rule := range g.Rules {
+			list = append(list, rule.Clone())
+		}
+		g.Rules = list
+	}
+	return g
+}
+
+// Rule returns restrictions of the command path, creating them if there are none
+func (g *Guild) Rule(command string) *rules.Rule {
+	for i := range g.Rules {
+		if g.Rules[i].Command == command {
+			return &g.Rules[i]
+		}
+	}
+	g.Rules = append(g.Rules, rules.Rule{Command: command})
+	return &g.Rules[len(g.Rules)-1]
+}
+
+// RemoveRules removes restrictions of the command path, or all of them if command is empty
+func (g *Guild) RemoveRules(command string) {
+	g.Rules = slices.DeleteFunc(g.Rules, func(rule rules.Rule) bool {
+		return command == "" || rule.Command == command || rule.IsEmpty()
+	})
+	if len(g.Rules) == 0 {
+		g.Rules = nil
+	}
+}
@@ -0,0 +1,108 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store keeps settings of all guilds in memory and persists them to a JSON file on every change
+type Store struct {
+	mu     sync.RWMutex
+	file   string
+	guilds map[string]Guild
+}
+
+// NewStore loads settings from the file. Missing file means no guild has settings yet
+func NewStore(file string) (*Store, error) {
+	s := &Store{file: file, guilds: make(map[string]Guild)}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &s.guilds); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return s, nil
+}
+
+// Get returns settings of the guild. Nil store returns empty settings
+func (s *Store) Get(guildID string) Guild {
+	if s == nil {
+		return Guild{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.guilds[guildID].clone()
+}
+
+// Update changes settings of the guild with the function and persists them. Nothing is changed
+// if the function or persisting fails
+func (s *Store) Update(guildID string, update func(g *Guild) error) error {
+	if s == nil {
+		return errors.New("settings are not available")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.guilds[guildID]
+	g := old.clone()
+	if err := update(&g); err != nil {
+		return err
+	}
+
+	if isZero(&g) {
+		delete(s.guilds, guildID)
+	} else {
+		s.guilds[guildID] = g
+	}
+	if err := s.save(); err != nil {
+		if ok {
+			s.guilds[guildID] = old
+		} else {
+			delete(s.guilds, guildID)
+		}
+		return err
+	}
+	return nil
+}
+
+// save writes settings to a temporary file first, so the file is never left half-written
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(s.guilds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func isZero(g *Guild) bool {
+	return g.DefaultModel == "" && len(g.AllowedModels) == 0 && len(g.ChatChannels) == 0 &&
+		g.ThreadAutoArchiveDuration == 0 && !g.ImagesDisabled && g.Moderation == ModerationPolicy{} && len(g.Rules) == 0
+}
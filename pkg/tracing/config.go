@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	// stdout or otlp. Tracing is disabled if empty
+	Exporter string `yaml:"exporter"`
+	// OTLP/HTTP collector address, e.g. "localhost:4318". OTEL_EXPORTER_OTLP_* variables apply if empty
+	Endpoint string `yaml:"endpoint"`
+	// Send spans to the collector over plain HTTP
+	Insecure bool `yaml:"insecure"`
+	// Fraction of interactions and messages that are traced, from 0 to 1. All of them are traced if not set
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// Validate checks the config. Errors are prefixed with YAML field paths
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.Exporter {
+	case "", ExporterStdout, ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("exporter: unknown exporter %q, expected %s or %s", c.Exporter, ExporterStdout, ExporterOTLP))
+	}
+	if c.Endpoint != "" && c.Exporter != ExporterOTLP {
+		errs = append(errs, fmt.Errorf("endpoint: only used by %s exporter", ExporterOTLP))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("sampleRatio: %g must be between 0 and 1", c.SampleRatio))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport makes a span of every HTTP request whose context already carries a span, e.g. Discord REST
+// calls made with discordgo.WithContext or provider calls made while handling an interaction. Requests
+// without a span, like gateway heartbeats, are not traced
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(req.Context()).IsValid() {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := Start(req.Context(), fmt.Sprintf("%s %s", req.Method, req.URL.Host),
+		semconv.HTTPRequestMethodKey.String(req.Method),
+		semconv.ServerAddress(req.URL.Host),
+		semconv.URLPath(redactPath(req.URL.Path)),
+	)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		End(span, err)
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	span.End()
+	return resp, nil
+}
+
+// redactPath replaces webhook and interaction tokens of Discord REST paths with a placeholder, e.g.
+// /api/v9/webhooks/{id}/{token}/messages/@original. Tokens let anyone answer the interaction, so they
+// must not reach exporters
+func redactPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 0; i+2 < len(segments); i++ {
+		if segments[i] == "webhooks" || segments[i] == "interactions" {
+			segments[i+2] = "{token}"
+			i += 2
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Client returns a copy of the client that traces its requests, see Transport
+func Client(client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	traced := *client
+	traced.Transport = Transport(client.Transport)
+	return &traced
+}
@@ -0,0 +1,22 @@
+package tracing
+
+import "testing"
+
+func TestRedactPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/v9/webhooks/123/secret/messages/@original", want: "/api/v9/webhooks/123/{token}/messages/@original"},
+		{path: "/api/v9/webhooks/123/secret", want: "/api/v9/webhooks/123/{token}"},
+		{path: "/api/v9/interactions/456/secret/callback", want: "/api/v9/interactions/456/{token}/callback"},
+		{path: "/api/v9/channels/789/webhooks", want: "/api/v9/channels/789/webhooks"},
+		{path: "/api/v9/channels/789/messages", want: "/api/v9/channels/789/messages"},
+		{path: "/v1/chat/completions", want: "/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		if got := redactPath(tt.path); got != tt.want {
+			t.Errorf("redactPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
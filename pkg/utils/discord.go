@@ -1,32 +1,32 @@
 package utils
 
 import (
-	"log"
+	"log/slog"
 
 	discord "github.com/bwmarrin/discordgo"
 )
 
 // ToggleThreadLock locks or unlocks a Discord thread, based on the 'locked' parameter.
-func ToggleDiscordThreadLock(s *discord.Session, channelID string, locked bool) {
+func ToggleDiscordThreadLock(s *discord.Session, channelID string, locked bool, options ...discord.RequestOption) {
 	_, err := s.ChannelEditComplex(channelID, &discord.ChannelEdit{
 		Locked: &locked,
-	})
+	}, options...)
 	if err != nil {
-		log.Printf("[CHID: %s] Failed to lock/unlock Thread with the error: %v\n", channelID, err)
+		slog.Error("Failed to lock/unlock thread", "thread", channelID, "locked", locked, "error", err)
 	}
 }
 
 // Sends a message to a specified Discord channel, either as a reply to another message if a message reference is provided or as a standalone message if the message reference is nil
-func DiscordChannelMessageSend(s *discord.Session, channelID string, content string, messageReference *discord.MessageReference) (m *discord.Message, err error) {
+func DiscordChannelMessageSend(s *discord.Session, channelID string, content string, messageReference *discord.MessageReference, options ...discord.RequestOption) (m *discord.Message, err error) {
 	if messageReference != nil {
-		m, err = s.ChannelMessageSendReply(channelID, content, messageReference)
+		m, err = s.ChannelMessageSendReply(channelID, content, messageReference, options...)
 	} else {
-		m, err = s.ChannelMessageSend(channelID, content)
+		m, err = s.ChannelMessageSend(channelID, content, options...)
 	}
 	return
 }
 
-func DiscordChannelMessageEdit(s *discord.Session, messageID string, channelID string, content *string, embeds *[]*discord.MessageEmbed) error {
+func DiscordChannelMessageEdit(s *discord.Session, messageID string, channelID string, content *string, embeds *[]*discord.MessageEmbed, options ...discord.RequestOption) error {
 	_, err := s.ChannelMessageEditComplex(
 		&discord.MessageEdit{
 			Content: content,
@@ -34,6 +34,7 @@ func DiscordChannelMessageEdit(s *discord.Session, messageID string, channelID s
 			ID:      messageID,
 			Channel: channelID,
 		},
+		options...,
 	)
 	return err
 }